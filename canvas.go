@@ -0,0 +1,228 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"math"
+	"strings"
+	"syscall/js"
+)
+
+// A Renderer drawing on an HTML5 canvas, through its 2D context.  Every call into the browser is slow from
+// WebAssembly, so paths are built up in Go as SVG path data, and only passed to the canvas (as a Path2D) when they're
+// filled, stroked, or clipped to.  The drawing state is only changed when it's different
+type canvasRenderer struct {
+	ctx      js.Value
+	path     strings.Builder
+	open     bool // Whether a sub path has been started
+	state    canvasState
+	saved    []canvasState
+	patterns map[string]canvasPattern // Repeating fills of the texture images drawn so far, by image name
+	calls    int                      // Calls made into the browser
+}
+
+// A texture image repeated across the canvas, along with the size of the image in pixels
+type canvasPattern struct {
+	pattern js.Value
+	width   float64
+	height  float64
+}
+
+// The drawing state last set on the canvas
+type canvasState struct {
+	fillStyle   string
+	strokeStyle string
+	font        string
+	lineWidth   float64
+	globalAlpha float64
+}
+
+// The texture images asked for so far, by name.  They're loaded by the web page's loadTexture() function, so they
+// can come from files dropped onto the page as well as URLs
+var textureImages = make(map[string]js.Value)
+
+// Returns a renderer drawing on the canvas 2D context
+func newCanvasRenderer(ctx js.Value) *canvasRenderer {
+	return &canvasRenderer{ctx: ctx, patterns: make(map[string]canvasPattern)}
+}
+
+func (c *canvasRenderer) Arc(x float64, y float64, radius float64, startAngle float64, endAngle float64) {
+	// SVG arcs go between two points, so the arc is split into pieces of at most half a circle
+	point := func(a float64) string {
+		return svgNum(x+radius*math.Cos(a)) + " " + svgNum(y+radius*math.Sin(a))
+	}
+	if c.open {
+		c.path.WriteString("L" + point(startAngle))
+	} else {
+		c.path.WriteString("M" + point(startAngle))
+		c.open = true
+	}
+	sweep := endAngle - startAngle
+	pieces := int(math.Ceil(math.Abs(sweep) / math.Pi))
+	flag := "1"
+	if sweep < 0 {
+		flag = "0"
+	}
+	r := svgNum(radius)
+	for i := 1; i <= pieces; i++ {
+		c.path.WriteString("A" + r + " " + r + " 0 0 " + flag + " " + point(startAngle+sweep*float64(i)/float64(pieces)))
+	}
+}
+
+func (c *canvasRenderer) BeginPath() {
+	c.path.Reset()
+	c.open = false
+}
+
+func (c *canvasRenderer) Clip() {
+	c.usePath("clip")
+}
+
+func (c *canvasRenderer) ClosePath() {
+	if c.open {
+		c.path.WriteString("Z")
+	}
+}
+
+func (c *canvasRenderer) Fill() {
+	c.usePath("fill")
+}
+
+func (c *canvasRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	c.calls++
+	c.ctx.Call("fillRect", x, y, w, h)
+}
+
+func (c *canvasRenderer) FillText(text string, x float64, y float64) {
+	c.calls++
+	c.ctx.Call("fillText", text, x, y)
+}
+
+func (c *canvasRenderer) LineTo(x float64, y float64) {
+	if !c.open {
+		c.MoveTo(x, y)
+		return
+	}
+	c.path.WriteString("L" + svgNum(x) + " " + svgNum(y))
+}
+
+func (c *canvasRenderer) MoveTo(x float64, y float64) {
+	c.path.WriteString("M" + svgNum(x) + " " + svgNum(y))
+	c.open = true
+}
+
+func (c *canvasRenderer) Restore() {
+	if n := len(c.saved); n > 0 {
+		c.state = c.saved[n-1]
+		c.saved = c.saved[:n-1]
+	}
+	c.calls++
+	c.ctx.Call("restore")
+}
+
+func (c *canvasRenderer) Save() {
+	c.saved = append(c.saved, c.state)
+	c.calls++
+	c.ctx.Call("save")
+}
+
+func (c *canvasRenderer) Scale(x float64, y float64) {
+	c.calls++
+	c.ctx.Call("scale", x, y)
+}
+
+func (c *canvasRenderer) SetFillStyle(colour string) {
+	if colour != c.state.fillStyle {
+		c.state.fillStyle = colour
+		c.calls++
+		c.ctx.Set("fillStyle", colour)
+	}
+}
+
+func (c *canvasRenderer) SetFillGradient(x0 float64, y0 float64, x1 float64, y1 float64, from string, to string) {
+	g := c.ctx.Call("createLinearGradient", x0, y0, x1, y1)
+	g.Call("addColorStop", 0, from)
+	g.Call("addColorStop", 1, to)
+	c.calls += 4
+	c.ctx.Set("fillStyle", g)
+
+	// The next fill colour always needs setting, even if it's the one used before the gradient
+	c.state.fillStyle = ""
+}
+
+func (c *canvasRenderer) SetFillImage(image string, transform [6]float64) {
+	p, ok := c.patterns[image]
+	if !ok {
+		img, ok := textureImages[image]
+		if !ok {
+			img = js.Global().Call("loadTexture", image)
+			textureImages[image] = img
+			c.calls++
+		}
+		c.calls += 2
+		if !img.Get("complete").Bool() || img.Get("naturalWidth").Int() == 0 {
+			// Not loaded yet, or it couldn't be
+			return
+		}
+		p = canvasPattern{
+			pattern: c.ctx.Call("createPattern", img, "repeat"),
+			width:   img.Get("naturalWidth").Float(),
+			height:  img.Get("naturalHeight").Float(),
+		}
+		c.patterns[image] = p
+		c.calls += 3
+	}
+
+	// The pattern is in image pixels, so they're scaled down to the image being 1 across first
+	t := transform
+	m := js.Global().Get("DOMMatrix").New([]interface{}{t[0] / p.width, t[1] / p.width, t[2] / p.height, t[3] / p.height, t[4], t[5]})
+	p.pattern.Call("setTransform", m)
+	c.ctx.Set("fillStyle", p.pattern)
+	c.calls += 4
+
+	// The next fill colour always needs setting, even if it's the one used before the image
+	c.state.fillStyle = ""
+}
+
+func (c *canvasRenderer) SetFont(font string) {
+	if font != c.state.font {
+		c.state.font = font
+		c.calls++
+		c.ctx.Set("font", font)
+	}
+}
+
+func (c *canvasRenderer) SetGlobalAlpha(alpha float64) {
+	if alpha != c.state.globalAlpha {
+		c.state.globalAlpha = alpha
+		c.calls++
+		c.ctx.Set("globalAlpha", alpha)
+	}
+}
+
+func (c *canvasRenderer) SetLineWidth(w float64) {
+	if w != c.state.lineWidth {
+		c.state.lineWidth = w
+		c.calls++
+		c.ctx.Set("lineWidth", w)
+	}
+}
+
+func (c *canvasRenderer) SetStrokeStyle(colour string) {
+	if colour != c.state.strokeStyle {
+		c.state.strokeStyle = colour
+		c.calls++
+		c.ctx.Set("strokeStyle", colour)
+	}
+}
+
+func (c *canvasRenderer) Stroke() {
+	c.usePath("stroke")
+}
+
+// Passes the current path to the canvas as a Path2D, then calls the given canvas method with it
+func (c *canvasRenderer) usePath(method string) {
+	c.calls += 2
+	c.ctx.Call(method, js.Global().Get("Path2D").New(c.path.String()))
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// How close to a plane a point needs to be to count as on it, when splitting polygons
+const csgEpsilon = 1e-5
+
+// Where a point or polygon is compared to a plane
+const (
+	CSG_COPLANAR = 0
+	CSG_FRONT    = 1
+	CSG_BACK     = 2
+	CSG_SPANNING = 3 // Polygons only, with points both in front of and behind the plane
+)
+
+// The boolean operations between solid objects, by the name used in scene files
+var csgOperations = map[string]func(a Object, b Object) (Object, error){
+	"union":        csgUnion,
+	"difference":   csgDifference,
+	"intersection": csgIntersection,
+}
+
+// A plane, made up of the points p where vecDot(n, p) == w
+type csgPlane struct {
+	n Point // Unit normal, facing out of the solid
+	w float64
+}
+
+// A flat convex polygon, with its corners anticlockwise when looked at from the front
+type csgPolygon struct {
+	pts    []Point
+	plane  csgPlane
+	colour string // Colour of the surface the polygon came from, or "" for the colour of the result
+}
+
+// A node of a binary space partitioning tree.  The polygons lying in the node's plane are kept at the node, with the
+// ones in front of and behind it going down the front and back branches
+type csgNode struct {
+	plane *csgPlane
+	front *csgNode
+	back  *csgNode
+	polys []csgPolygon
+}
+
+// Returns a with the parts inside b cut out of it.  Both objects need to be closed solids, with their surfaces facing
+// outwards
+func csgDifference(a Object, b Object) (Object, error) {
+	na, nb, err := csgTrees(a, b)
+	if err != nil {
+		return Object{}, err
+	}
+	na.invert()
+	na.clipTo(nb)
+	nb.clipTo(na)
+	nb.invert()
+	nb.clipTo(na)
+	nb.invert()
+	na.build(nb.allPolygons())
+	na.invert()
+	return csgObject(na.allPolygons(), a.C), nil
+}
+
+// Returns the parts of two objects which are inside both of them.  Both objects need to be closed solids, with their
+// surfaces facing outwards
+func csgIntersection(a Object, b Object) (Object, error) {
+	na, nb, err := csgTrees(a, b)
+	if err != nil {
+		return Object{}, err
+	}
+	na.invert()
+	nb.clipTo(na)
+	nb.invert()
+	na.clipTo(nb)
+	nb.clipTo(na)
+	na.build(nb.allPolygons())
+	na.invert()
+	return csgObject(na.allPolygons(), a.C), nil
+}
+
+// Returns the two objects joined together into one, leaving out the parts of each inside the other.  Both objects
+// need to be closed solids, with their surfaces facing outwards
+func csgUnion(a Object, b Object) (Object, error) {
+	na, nb, err := csgTrees(a, b)
+	if err != nil {
+		return Object{}, err
+	}
+	na.clipTo(nb)
+	nb.clipTo(na)
+	nb.invert()
+	nb.clipTo(na)
+	nb.invert()
+	na.build(nb.allPolygons())
+	return csgObject(na.allPolygons(), a.C), nil
+}
+
+// Returns all the polygons in the tree
+func (n *csgNode) allPolygons() []csgPolygon {
+	polys := append([]csgPolygon(nil), n.polys...)
+	if n.front != nil {
+		polys = append(polys, n.front.allPolygons()...)
+	}
+	if n.back != nil {
+		polys = append(polys, n.back.allPolygons()...)
+	}
+	return polys
+}
+
+// Adds polygons to the tree, splitting them by the planes they cross.  The first polygon added to an empty node gives
+// its plane
+func (n *csgNode) build(polys []csgPolygon) {
+	if len(polys) == 0 {
+		return
+	}
+	if n.plane == nil {
+		p := polys[0].plane
+		n.plane = &p
+	}
+	var front, back []csgPolygon
+	for _, p := range polys {
+		n.plane.split(p, &n.polys, &n.polys, &front, &back)
+	}
+	if len(front) > 0 {
+		if n.front == nil {
+			n.front = &csgNode{}
+		}
+		n.front.build(front)
+	}
+	if len(back) > 0 {
+		if n.back == nil {
+			n.back = &csgNode{}
+		}
+		n.back.build(back)
+	}
+}
+
+// Returns the parts of the polygons which are outside the solid the tree holds
+func (n *csgNode) clipPolygons(polys []csgPolygon) []csgPolygon {
+	if n.plane == nil {
+		return append([]csgPolygon(nil), polys...)
+	}
+	var front, back []csgPolygon
+	for _, p := range polys {
+		n.plane.split(p, &front, &back, &front, &back)
+	}
+	if n.front != nil {
+		front = n.front.clipPolygons(front)
+	}
+	if n.back != nil {
+		back = n.back.clipPolygons(back)
+	} else {
+		// Behind a leaf is inside the solid
+		back = nil
+	}
+	return append(front, back...)
+}
+
+// Removes the parts of the polygons in this tree which are inside the solid held by the other tree
+func (n *csgNode) clipTo(other *csgNode) {
+	n.polys = other.clipPolygons(n.polys)
+	if n.front != nil {
+		n.front.clipTo(other)
+	}
+	if n.back != nil {
+		n.back.clipTo(other)
+	}
+}
+
+// Turns the solid held by the tree inside out
+func (n *csgNode) invert() {
+	for i := range n.polys {
+		n.polys[i].flip()
+	}
+	if n.plane != nil {
+		n.plane.n = vecScale(n.plane.n, -1)
+		n.plane.w = -n.plane.w
+	}
+	if n.front != nil {
+		n.front.invert()
+	}
+	if n.back != nil {
+		n.back.invert()
+	}
+	n.front, n.back = n.back, n.front
+}
+
+// Turns a polygon around to face the other way
+func (p *csgPolygon) flip() {
+	// The points are copied, as other polygons can share them
+	p.pts = append([]Point(nil), p.pts...)
+	reversePoints(p.pts)
+	p.plane.n = vecScale(p.plane.n, -1)
+	p.plane.w = -p.plane.w
+}
+
+// Sorts a polygon by which side of the plane it's on, splitting it in two if it crosses the plane.  Polygons lying
+// in the plane go in coplanarFront or coplanarBack, depending on whether they face the same way as the plane
+func (pl csgPlane) split(p csgPolygon, coplanarFront, coplanarBack, front, back *[]csgPolygon) {
+	polyType := CSG_COPLANAR
+	types := make([]int, len(p.pts))
+	for i, pt := range p.pts {
+		t := vecDot(pl.n, pt) - pl.w
+		switch {
+		case t < -csgEpsilon:
+			types[i] = CSG_BACK
+		case t > csgEpsilon:
+			types[i] = CSG_FRONT
+		}
+		polyType |= types[i]
+	}
+
+	switch polyType {
+	case CSG_COPLANAR:
+		if vecDot(pl.n, p.plane.n) > 0 {
+			*coplanarFront = append(*coplanarFront, p)
+		} else {
+			*coplanarBack = append(*coplanarBack, p)
+		}
+	case CSG_FRONT:
+		*front = append(*front, p)
+	case CSG_BACK:
+		*back = append(*back, p)
+	case CSG_SPANNING:
+		var f, b []Point
+		for i, a := range p.pts {
+			j := (i + 1) % len(p.pts)
+			ti, tj := types[i], types[j]
+			if ti != CSG_BACK {
+				f = append(f, a)
+			}
+			if ti != CSG_FRONT {
+				b = append(b, a)
+			}
+			if ti|tj == CSG_SPANNING {
+				// The side crosses the plane, so both halves get the point where it does
+				c := p.pts[j]
+				t := (pl.w - vecDot(pl.n, a)) / vecDot(pl.n, vecSub(c, a))
+				mid := lerpPoint(a, c, t)
+				f = append(f, mid)
+				b = append(b, mid)
+			}
+		}
+		if len(f) >= 3 {
+			*front = append(*front, csgPolygon{pts: f, plane: p.plane, colour: p.colour})
+		}
+		if len(b) >= 3 {
+			*back = append(*back, csgPolygon{pts: b, plane: p.plane, colour: p.colour})
+		}
+	}
+}
+
+// Returns an object made from the polygons left by a boolean operation.  Corners in the same place are joined, and
+// corners of neighbouring polygons lying along a side are added to it, so the surfaces of the object meet properly.
+// The object's edges are its feature edges, as splitting leaves many surfaces lying flat against each other
+func csgObject(polys []csgPolygon, colour string) Object {
+	ob := Object{C: colour}
+	index := make(map[[3]int64]int)
+	var colours []string
+	for _, poly := range polys {
+		colours = append(colours, poly.colour)
+		var s Surface
+		for _, p := range poly.pts {
+			key := [3]int64{
+				int64(math.Round(p.X / csgEpsilon)),
+				int64(math.Round(p.Y / csgEpsilon)),
+				int64(math.Round(p.Z / csgEpsilon)),
+			}
+			n, ok := index[key]
+			if !ok {
+				n = len(ob.P)
+				index[key] = n
+				ob.P = append(ob.P, p)
+			}
+			s = append(s, n)
+		}
+		ob.S = append(ob.S, s)
+	}
+
+	// Fill in the corners lying along each side of each surface
+	for i, s := range ob.S {
+		var filled Surface
+		for j, a := range s {
+			filled = append(filled, a)
+			b := s[(j+1)%len(s)]
+			pa, pb := ob.P[a], ob.P[b]
+			side := vecSub(pb, pa)
+			length := vecDot(side, side)
+			if length == 0 {
+				continue
+			}
+			type along struct {
+				n int
+				t float64
+			}
+			var on []along
+			for n, p := range ob.P {
+				if n == a || n == b {
+					continue
+				}
+				t := vecDot(vecSub(p, pa), side) / length
+				if t <= 0 || t >= 1 {
+					continue
+				}
+				if vecLength(vecSub(p, vecAdd(pa, vecScale(side, t)))) < csgEpsilon {
+					on = append(on, along{n, t})
+				}
+			}
+			sort.Slice(on, func(x, y int) bool { return on[x].t < on[y].t })
+			for _, o := range on {
+				filled = append(filled, o.n)
+			}
+		}
+		ob.S[i] = filled
+	}
+
+	ob.SC = colours
+	ob = dropDegenerateSurfaces(ob)
+	ob.E = featureEdges(ob.P, ob.S, defaultCreaseAngle)
+	ob.Mid = midPoint(ob.P)
+	return ob
+}
+
+// Returns the BSP trees of two objects, checking they're closed solids first.  The polygons keep the colours of their
+// surfaces, with those of b also keeping b's colour if it's different to a's
+func csgTrees(a Object, b Object) (*csgNode, *csgNode, error) {
+	var trees [2]*csgNode
+	for i, ob := range []Object{a, b} {
+		if len(ob.S) == 0 {
+			return nil, nil, errors.New("csg: objects need surfaces")
+		}
+		if len(boundaryEdges(ob.S)) != 0 {
+			return nil, nil, errors.New("csg: objects need to be closed, with no gaps between their surfaces")
+		}
+		var polys []csgPolygon
+		tri := triangulateObject(ob)
+		for j, s := range tri.S {
+			pts := []Point{ob.P[s[0]], ob.P[s[1]], ob.P[s[2]]}
+			n := vecNormalise(vecCross(vecSub(pts[1], pts[0]), vecSub(pts[2], pts[0])))
+			if vecLength(n) == 0 {
+				continue
+			}
+			colour := tri.surfaceColour(j)
+			if colour == "" && i == 1 && b.C != a.C {
+				colour = b.C
+			}
+			polys = append(polys, csgPolygon{pts: pts, plane: csgPlane{n: n, w: vecDot(n, pts[0])}, colour: colour})
+		}
+		trees[i] = &csgNode{}
+		trees[i].build(polys)
+	}
+	return trees[0], trees[1], nil
+}
+
+// Returns the point the fraction t of the way from a to b, including its colour and texture position if both points
+// have them
+func lerpPoint(a Point, b Point, t float64) Point {
+	p := vecAdd(a, vecScale(vecSub(b, a), t))
+	p.C, p.T = a.C, a.T
+	if a.T != nil && b.T != nil {
+		p.T = &UV{U: a.T.U + (b.T.U-a.T.U)*t, V: a.T.V + (b.T.V-a.T.V)*t}
+	}
+	if a.C != nil && b.C != nil {
+		mix := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+		}
+		p.C = &RGB{R: mix(a.C.R, b.C.R), G: mix(a.C.G, b.C.G), B: mix(a.C.B, b.C.B)}
+	}
+	return p
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCSG(t *testing.T) {
+	// Moves and turns an object
+	place := func(o Object, x, y, z float64, rotateX float64) Object {
+		m := translate(rotateAroundX(identityMatrix, rotateX), x, y, z)
+		pts := make([]Point, len(o.P))
+		for i, p := range o.P {
+			pts[i] = transform(m, p)
+		}
+		o.P = pts
+		return o
+	}
+	const segments = 16
+	ring := float64(segments) / 2 * math.Sin(2*math.Pi/segments) // Area of a ring of radius 1
+	hole := place(cylinder(0.5, 3, segments), 0, 0, 0, 90)
+	open := cube(2)
+	open.S = open.S[1:]
+
+	tests := []struct {
+		name   string
+		op     func(a Object, b Object) (Object, error)
+		a, b   Object
+		want   string // Part of the error message, or "" for no error
+		volume float64
+	}{
+		{"union", csgUnion, cube(2), place(cube(2), 1, 1, 1, 0), "", 15},
+		{"difference", csgDifference, cube(2), place(cube(2), 1, 1, 1, 0), "", 7},
+		{"intersection", csgIntersection, cube(2), place(cube(2), 1, 1, 1, 0), "", 1},
+		{"union side by side", csgUnion, cube(2), place(cube(2), 1, 0, 0, 0), "", 12},
+		{"union apart", csgUnion, cube(2), place(cube(2), 5, 0, 0, 0), "", 16},
+		{"difference apart", csgDifference, cube(2), place(cube(2), 5, 0, 0, 0), "", 8},
+		{"difference turned", csgDifference, cube(2), place(cube(2), 1, 0, 0, 45), "", 8 - (8*math.Sqrt2 - 8)},
+		{"drilled hole", csgDifference, cube(2), hole, "", 8 - (0.25 * ring * 2)},
+		{"peg", csgIntersection, cube(2), hole, "", 0.25 * ring * 2},
+		{"sphere and cube", csgUnion, sphere(1, segments, 8), place(cube(1), 0.5, 0.5, 0.5, 0), "", meshVolume(sphere(1, segments, 8)) + 1 - (meshVolume(sphere(1, segments, 8)) / 8)},
+
+		{"open object", csgUnion, open, cube(1), "closed", 0},
+		{"open second object", csgDifference, cube(1), open, "closed", 0},
+		{"no surfaces", csgIntersection, Object{P: []Point{{}, {X: 1}}}, cube(1), "need surfaces", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := tc.op(tc.a, tc.b)
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			if v := checkSolid(t, o); !near(v, tc.volume, 1e-6) {
+				t.Errorf("volume is %v, wanted %v", v, tc.volume)
+			}
+		})
+	}
+}
+
+// Checks the surfaces of the result keep the colours they had, with those of the second object taking its colour
+func TestCSGColours(t *testing.T) {
+	a, b := cube(2), cube(1)
+	a.C, b.C = "red", "blue"
+	a.SC = []string{"green"}
+	o, err := csgDifference(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[string]int)
+	for i := range o.S {
+		count[o.surfaceColour(i)]++
+	}
+	if o.C != "red" || count["blue"] == 0 || count["green"] == 0 || count[""] == 0 {
+		t.Errorf("result has colour %q and surface colours %v", o.C, count)
+	}
+}
@@ -4,18 +4,244 @@ const WASM_URL = 'wasm.wasm';
 
 var wasm;
 
+// Model file details, read by the wasm loadModel() function
+var modelName, modelData, modelMaterials, modelBuffers;
+
+// How the edges of loaded models are found: "all", "boundary", or "feature".  Set from the page query string, and
+// when empty each format's usual way is used
+var modelEdges = "";
+
+// The most faces a loaded model can have before it's simplified.  Set from the page query string, and when empty models
+// are loaded as they are
+var modelSimplify = "";
+
+// Scene file contents, passed to and from the wasm loadScene() and saveScene() functions
+var sceneData;
+
+// SVG document created by the wasm exportSVG() function
+var svgData;
+
+// Canvas holding the image drawn by the wasm exportPNG() function
+var exportCanvas;
+
+// OBJ and MTL files created by the wasm exportOBJ() function
+var objData, mtlData;
+
+// Function passed to the wasm addPlot() function
+var plotFunction;
+
+// Where to load texture images from, by the name models and scenes use for them.  Images dropped onto the page are
+// added by their file name, and ones referenced by fetched models and scenes are found relative to them
+var textureURLs = {};
+
+// Image file formats which can be used as textures, by file extension
+const imageFormats = ["png", "jpg", "jpeg", "gif", "webp"];
+
+// Model file formats understood by the wasm loader, by file extension.  Binary formats are passed through base64
+// encoded
+const modelFormats = {
+  "obj": {id: 1, binary: false},
+  "stl": {id: 2, binary: true},
+  "ply": {id: 3, binary: true},
+  "gltf": {id: 4, binary: false},
+  "glb": {id: 5, binary: true}
+};
+
 // Apply the matrix transformations
 function applyTransformation() {
     wasm.exports.applyTransformation();
 }
 
+// Base64 encode binary data, so it can be passed through to wasm as a string
+function base64Encode(buf) {
+  let bytes = new Uint8Array(buf);
+  let str = "";
+  for (let i = 0; i < bytes.length; i += 0x8000) {
+    str += String.fromCharCode.apply(null, bytes.subarray(i, i + 0x8000));
+  }
+  return btoa(str);
+}
+
 // Pass mouse clicks through to the wasm handler
 function clickHandler(evt) {
   wasm.exports.clickHandler(evt.clientX, evt.clientY);
 }
 
+// Download some data as a file
+function download(data, fileName, type) {
+  let link = document.createElement("a");
+  link.href = URL.createObjectURL(new Blob([data], {type: type}));
+  link.download = fileName;
+  link.click();
+  setTimeout(() => URL.revokeObjectURL(link.href), 1000);
+}
+
+// Load model and scene (.json) files dropped onto the canvas.  Any material libraries (.mtl), glTF buffers (.bin), or
+// texture images the models use need to be dropped along with them
+function dropHandler(evt) {
+  evt.preventDefault();
+  let files = Array.from(evt.dataTransfer.files);
+  files.filter(f => imageFormats.includes(fileExtension(f.name))).forEach(function (f) {
+    textureURLs[f.name] = URL.createObjectURL(f);
+  });
+  let mtlFiles = files.filter(f => fileExtension(f.name) === "mtl");
+  let binFiles = files.filter(f => fileExtension(f.name) === "bin");
+  files.filter(f => fileExtension(f.name) === "json").forEach(f => f.text().then(loadScene));
+  Promise.all([
+    Promise.all(mtlFiles.map(f => f.text())),
+    Promise.all(binFiles.map(f => f.arrayBuffer()))
+  ]).then(function ([materials, bins]) {
+    let buffers = {};
+    binFiles.forEach((f, i) => buffers[f.name] = base64Encode(bins[i]));
+    files.filter(f => modelFormats[fileExtension(f.name)] !== undefined).forEach(function (f) {
+      let read = isBinaryModel(f.name) ? f.arrayBuffer().then(base64Encode) : f.text();
+      read.then(data => loadModel(f.name, data, materials.join("\n"), buffers));
+    });
+  });
+}
+
+// Export the world as OBJ and MTL files, and download them.  If triangles is true, the surfaces are split into
+// triangles
+function exportOBJ(triangles) {
+  wasm.exports.exportOBJ(triangles ? 1 : 0);
+  download(objData, "world.obj", "model/obj");
+  download(mtlData, "world.mtl", "model/mtl");
+}
+
+// Ask for an image size, then export the graph as a PNG file of that size and download it
+function exportPNG() {
+  let size = prompt("PNG image size (width x height):", "1920x1080");
+  if (size === null) {
+    return;
+  }
+  let match = /^\s*(\d+)\s*[xX*, ]\s*(\d+)\s*$/.exec(size);
+  if (match === null) {
+    alert("The size needs to be given as width x height, eg 1920x1080");
+    return;
+  }
+  wasm.exports.exportPNG(parseInt(match[1]), parseInt(match[2]));
+  if (exportCanvas) {
+    exportCanvas.toBlob(blob => download(blob, "view.png", "image/png"), "image/png");
+  }
+}
+
+// Export the current view as an SVG file, and download it
+function exportSVG(labels) {
+  wasm.exports.exportSVG(labels ? 1 : 0);
+  download(svgData, "view.svg", "image/svg+xml");
+}
+
+// Fetch a model from a URL, along with any material libraries or glTF buffers it references.  The texture images it
+// references are found relative to it
+function fetchModel(url) {
+  let base = new URL(url, location.href);
+  let fetchText = u => fetch(new URL(u, base)).then(resp => resp.text());
+  let fetchBinary = u => fetch(new URL(u, base)).then(resp => resp.arrayBuffer()).then(base64Encode);
+  if (isBinaryModel(url)) {
+    fetchBinary(url).then(data => loadModel(url, data, "", {}))
+      .catch(err => console.log("Fetching " + url + " failed: " + err));
+    return;
+  }
+  fetchText(url).then(function (data) {
+    let libs = [];
+    let buffers = {};
+    if (fileExtension(url) === "gltf") {
+      let doc = JSON.parse(data);
+      (doc.images || []).filter(i => i.uri && !i.uri.startsWith("data:")).forEach(function (i) {
+        textureURLs[i.uri] = new URL(i.uri, base).href;
+      });
+      (doc.buffers || []).filter(b => b.uri && !b.uri.startsWith("data:")).forEach(function (b) {
+        libs.push(fetchBinary(b.uri).then(function (d) {
+          buffers[b.uri] = d;
+          return "";
+        }));
+      });
+    } else {
+      let re = /^mtllib\s+(.+?)\s*$/gm;
+      let match;
+      while ((match = re.exec(data)) !== null) {
+        libs.push(fetchText(match[1]).then(function (mtl) {
+          let maps = /^map_Kd\s+(?:.*\s)?(\S+)\s*$/gm;
+          let m;
+          while ((m = maps.exec(mtl)) !== null) {
+            textureURLs[m[1]] = new URL(m[1], base).href;
+          }
+          return mtl;
+        }).catch(() => ""));
+      }
+    }
+    Promise.all(libs).then(materials => loadModel(url, data, materials.join("\n"), buffers));
+  }).catch(err => console.log("Fetching " + url + " failed: " + err));
+}
+
+// Fetch a scene file from a URL, replacing the world with it.  The texture images its objects use are found relative to
+// it
+function fetchScene(url) {
+  let base = new URL(url, location.href);
+  fetch(url).then(resp => resp.text()).then(function (data) {
+    try {
+      (JSON.parse(data).objects || []).filter(o => o.texture && !o.texture.startsWith("data:")).forEach(function (o) {
+        textureURLs[o.texture] = new URL(o.texture, base).href;
+      });
+    } catch (err) {
+      // Leave reporting the problem to the wasm loader
+    }
+    loadScene(data);
+  }).catch(err => console.log("Fetching " + url + " failed: " + err));
+}
+
+// Returns the lower case extension of a file name or URL
+function fileExtension(fileName) {
+  return fileName.split('?')[0].split('.').pop().toLowerCase();
+}
+
+// Pass changes to the page URL hash through to the wasm handler, so pasted links change the view
+function hashChangeHandler() {
+  wasm.exports.hashChangeHandler();
+}
+
+// Returns true if the model file is in a binary format
+function isBinaryModel(fileName) {
+  let format = modelFormats[fileExtension(fileName)];
+  return format !== undefined && format.binary;
+}
+
 // Pass key presses through to the wasm handler
 function keyPressHandler(evt) {
+  // Ctrl+S saves the scene, rather than the web page.  Ctrl+E exports the view as SVG, with labels if Shift is held,
+  // Ctrl+P exports it as PNG, and Ctrl+O exports the world as OBJ, split into triangles if Shift is held
+  if (evt.ctrlKey || evt.metaKey) {
+    switch (evt.key) {
+      case "s":
+      case "S":
+        evt.preventDefault();
+        saveScene();
+        return;
+      case "e":
+      case "E":
+        evt.preventDefault();
+        exportSVG(evt.shiftKey);
+        return;
+      case "p":
+      case "P":
+        evt.preventDefault();
+        exportPNG();
+        return;
+      case "o":
+      case "O":
+        evt.preventDefault();
+        exportOBJ(evt.shiftKey);
+        return;
+    }
+  }
+
+  // F plots a function
+  if ((evt.key === "f" || evt.key === "F") && !evt.ctrlKey && !evt.metaKey && !evt.altKey) {
+    evt.preventDefault();
+    plot();
+    return;
+  }
+
   let key = 0;
   switch(evt.key) {
     // Move keys
@@ -78,6 +304,24 @@ function keyPressHandler(evt) {
       key = 14;
       break;
 
+    // Edge mode key
+    case "e":
+    case "E":
+      key = 15;
+      break;
+
+    // Convex hull key
+    case "h":
+    case "H":
+      key = 16;
+      break;
+
+    // Render mode key
+    case "r":
+    case "R":
+      key = 17;
+      break;
+
     // Unknown key press, don't pass it through
     default:
       return;
@@ -87,6 +331,45 @@ function keyPressHandler(evt) {
   wasm.exports.keyPressHandler(key);
 }
 
+// Pass a model file through to the wasm loader
+function loadModel(fileName, data, materials, buffers) {
+  let format = modelFormats[fileExtension(fileName)];
+  if (format === undefined) {
+    console.log("Unknown model format: " + fileName);
+    return;
+  }
+  modelName = fileName;
+  modelData = data;
+  modelMaterials = materials || "";
+  modelBuffers = buffers || {};
+  wasm.exports.loadModel(format.id);
+}
+
+// Pass a scene file through to the wasm loader
+function loadScene(data) {
+  sceneData = data;
+  wasm.exports.loadScene();
+}
+
+// Start loading a texture image, returning the image element for the wasm canvas renderer to draw once it's loaded.
+// Names not given a URL are looked up by their file name, in case the image was dropped onto the page, and are
+// otherwise used as a URL themselves
+function loadTexture(name) {
+  let img = new Image();
+  img.src = textureURLs[name] || textureURLs[name.split(/[\\/]/).pop()] || name;
+  return img;
+}
+
+// Ask for a function of x, y, and t, then plot it
+function plot() {
+  let fn = prompt("Plot z = f(x, y, t), eg sin(x) * cos(y + t):", plotFunction || "sin(x) * cos(y + t)");
+  if (fn === null || fn.trim() === "") {
+    return;
+  }
+  plotFunction = fn;
+  wasm.exports.addPlot();
+}
+
 // Pass mouse movement events through to its wasm handler
 function moveHandler(evt) {
   // console.log(evt);
@@ -98,6 +381,12 @@ function renderFrame() {
     wasm.exports.renderFrame();
 }
 
+// Save the world as a scene file, and download it
+function saveScene() {
+  wasm.exports.saveScene();
+  download(sceneData, "scene.json", "application/json");
+}
+
 // Pass mouse wheel events through to its wasm handler
 function wheelHandler(evt) {
     wasm.exports.wheelHandler(evt.deltaY);
@@ -116,6 +405,24 @@ function init() {
       document.getElementById("mycanvas").addEventListener("mousedown", clickHandler);
       document.getElementById("mycanvas").addEventListener("mousemove", moveHandler);
       document.getElementById("mycanvas").addEventListener("wheel", wheelHandler);
+      document.getElementById("mycanvas").addEventListener("dragover", evt => evt.preventDefault());
+      document.getElementById("mycanvas").addEventListener("drop", dropHandler);
+      window.addEventListener("hashchange", hashChangeHandler);
+
+      // Load the scene and model given in the page query string (eg ?scene=scene.json&model=teapot.obj&edges=feature&simplify=5000)
+      let params = new URLSearchParams(location.search);
+      if (params.has("scene")) {
+        fetchScene(params.get("scene"));
+      }
+      if (params.has("edges")) {
+        modelEdges = params.get("edges");
+      }
+      if (params.has("simplify")) {
+        modelSimplify = params.get("simplify");
+      }
+      if (params.has("model")) {
+        fetchModel(params.get("model"));
+      }
 
       // Set up basic render loop
       setInterval(function() {
@@ -135,6 +442,24 @@ function init() {
         document.getElementById("mycanvas").addEventListener("keydown", keyPressHandler);
         document.getElementById("mycanvas").addEventListener("mousemove", moveHandler);
         document.getElementById("mycanvas").addEventListener("wheel", wheelHandler);
+        document.getElementById("mycanvas").addEventListener("dragover", evt => evt.preventDefault());
+        document.getElementById("mycanvas").addEventListener("drop", dropHandler);
+        window.addEventListener("hashchange", hashChangeHandler);
+
+        // Load the scene and model given in the page query string (eg ?scene=scene.json&model=teapot.obj&edges=feature&simplify=5000)
+        let params = new URLSearchParams(location.search);
+        if (params.has("scene")) {
+          fetchScene(params.get("scene"));
+        }
+        if (params.has("edges")) {
+          modelEdges = params.get("edges");
+        }
+        if (params.has("simplify")) {
+          modelSimplify = params.get("simplify");
+        }
+        if (params.has("model")) {
+          fetchModel(params.get("model"));
+        }
 
         // Set up basic render loop
         setInterval(function() {
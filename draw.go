@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The kinds of drawing command
+type drawKind int
+
+const (
+	DRAW_GRID    drawKind = iota // A grid line
+	DRAW_SURFACE                 // A filled polygon
+	DRAW_EDGE                    // A line between two points
+	DRAW_POINT                   // A filled circle
+	DRAW_LABEL                   // Some text
+)
+
+// How an object is drawn
+type RenderMode int
+
+const (
+	RENDER_DEFAULT     RenderMode = iota // Use the mode chosen for the whole world
+	RENDER_SOLID_EDGES                   // Surfaces, then edges, then points
+	RENDER_WIREFRAME                     // Edges and points
+	RENDER_SOLID                         // Surfaces only
+	RENDER_POINTS                        // Points only
+	RENDER_HIDDEN_LINE                   // Edges, leaving out the parts hidden behind surfaces
+)
+
+// The names of the render modes, as used in scene files
+var renderModeNames = []string{"default", "solid-edges", "wireframe", "solid", "points", "hidden-line"}
+
+// The colour of the grid lines
+const gridColour = "rgb(220, 220, 220)"
+
+// The colour of the edges of the selected object
+const selectColour = "orange"
+
+// A position in the graph area, in pixels
+type screenPoint struct {
+	X float64
+	Y float64
+}
+
+// One thing to draw in the graph area
+type drawCmd struct {
+	kind     drawKind
+	pts      []screenPoint // The surface corners, the ends of the edge or grid line, or the position of the point or label
+	colour   string
+	gradient *drawGradient // Fill for surfaces whose corners have different colours, instead of colour
+	texture  *drawTexture  // Image filling textured triangles, instead of colour
+	alpha    float64       // Opacity from 0 to 1 for see-through objects, or 0 for fully solid ones
+	radius   float64       // Size of points
+	text     string        // Text of labels
+}
+
+// An image fill, placed by the affine transform (a, b, c, d, e, f) taking the image's top left corner at (0, 0) and
+// bottom right at (1, 1) to where they're shown.  A point (x, y) of the image is shown at (ax + cy + e, bx + dy + f)
+type drawTexture struct {
+	image     string
+	transform [6]float64
+}
+
+// A linear gradient fill, going from one colour to another between two positions
+type drawGradient struct {
+	from       screenPoint
+	to         screenPoint
+	fromColour string
+	toColour   string
+}
+
+type paintOrder struct {
+	midZ float64 // Z depth of an object's mid point
+	name string
+}
+
+type paintOrderSlice []paintOrder
+
+func (p paintOrder) String() string {
+	return "Name: " + p.name + ", Mid point: " + strconv.FormatFloat(p.midZ, 'f', 1, 64)
+}
+
+func (p paintOrderSlice) Len() int {
+	return len(p)
+}
+
+func (p paintOrderSlice) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (p paintOrderSlice) Less(i, j int) bool {
+	return p[i].midZ < p[j].midZ
+}
+
+// Returns the drawing commands for a graph area of the given size.  These are the grid lines, then the surfaces, edges,
+// and points of each object in Z depth order, as chosen by the object's render mode.  Objects with the default render
+// mode use the given one.  Step is the number of pixels per world space unit, which is also the grid spacing.  If
+// labels is true, the name of each object is added last, at its mid point.  The selected object (if any) has its
+// edges highlighted and is always labelled
+func drawList(world map[string]Object, graphWidth float64, graphHeight float64, step float64, labels bool, selected string, mode RenderMode) (cmds []drawCmd) {
+	border := float64(2)
+	gap := float64(3)
+	left := border + gap
+	top := border + gap
+	toScreen := func(p Point) screenPoint {
+		return screenPos(p, graphWidth, graphHeight, step)
+	}
+
+	// Grid lines
+	for i := left; i < graphWidth-step; i += step {
+		// Vertical dashed lines
+		cmds = append(cmds, drawCmd{kind: DRAW_GRID, colour: gridColour, pts: []screenPoint{{i + step, top}, {i + step, graphHeight}}})
+	}
+	for i := top; i < graphHeight-step; i += step {
+		// Horizontal dashed lines
+		cmds = append(cmds, drawCmd{kind: DRAW_GRID, colour: gridColour, pts: []screenPoint{{left, i + step}, {graphWidth - border, i + step}}})
+	}
+
+	// Sort the objects by mid point Z depth order
+	var order paintOrderSlice
+	for i, j := range world {
+		order = append(order, paintOrder{name: i, midZ: j.Mid.Z})
+	}
+	sort.Sort(paintOrderSlice(order))
+
+	// Hidden line objects need to know where every drawn surface is, to work out which parts of their edges to leave out
+	modes := make(map[string]RenderMode, len(world))
+	needHidden := false
+	for name, o := range world {
+		modes[name] = o.M.use(mode)
+		needHidden = needHidden || modes[name] == RENDER_HIDDEN_LINE
+	}
+	var hidden *occlusionGrid
+	if needHidden {
+		hidden = newOcclusionGrid(world, modes, toScreen, graphWidth, graphHeight, step)
+	}
+
+	var labelCmds []drawCmd
+	for _, po := range order {
+		o := world[po.name]
+		m := modes[po.name]
+		if len(o.S) == 0 && (m == RENDER_SOLID || m == RENDER_SOLID_EDGES || m == RENDER_HIDDEN_LINE) {
+			// There are no surfaces to draw or to hide anything behind, so the edges and points are drawn instead
+			m = RENDER_WIREFRAME
+		}
+
+		// See-through objects have everything drawn partly transparent, with their surfaces drawn back to front so
+		// the nearer ones blend over the further ones
+		var alpha float64
+		if o.opacity() < 1 {
+			alpha = o.opacity()
+		}
+		surfaces := make([]int, len(o.S))
+		for i := range surfaces {
+			surfaces[i] = i
+		}
+		if alpha != 0 {
+			depth := make([]float64, len(o.S))
+			for i, l := range o.S {
+				for _, n := range l {
+					depth[i] += o.P[n].Z / float64(len(l))
+				}
+			}
+			sort.SliceStable(surfaces, func(a, b int) bool { return depth[surfaces[a]] < depth[surfaces[b]] })
+		}
+
+		// The surfaces.  A surface's own colour is used if it has one, otherwise surfaces whose points all have
+		// their own colour are filled with a gradient between them.  Surfaces of textured objects whose points all
+		// have UVs are drawn as triangles filled with the texture, which is mapped across each one separately.  The
+		// colour is still worked out, for when the texture isn't available
+		for _, i := range surfaces {
+			if m != RENDER_SOLID && m != RENDER_SOLID_EDGES {
+				break
+			}
+			l := o.S[i]
+			pts := make([]screenPoint, 0, len(l))
+			for _, n := range l {
+				pts = append(pts, toScreen(o.P[n]))
+			}
+			cmd := drawCmd{kind: DRAW_SURFACE, colour: o.C, alpha: alpha, pts: pts}
+			if c := o.surfaceColour(i); c != "" {
+				cmd.colour = c
+			} else if c, ok := averageColour(o.P, l); ok {
+				cmd.colour = c.String()
+				cmd.gradient = surfaceGradient(o.P, l, pts)
+			}
+			if o.T == "" || !hasUVs(o.P, l) {
+				cmds = append(cmds, cmd)
+				continue
+			}
+			for _, tri := range triangulateSurface(o.P, l) {
+				t := cmd
+				t.pts = []screenPoint{toScreen(o.P[tri[0]]), toScreen(o.P[tri[1]]), toScreen(o.P[tri[2]])}
+				t.texture = triangleTexture(o.T, o.P, tri, t.pts)
+				if t.texture != nil {
+					t.gradient = nil
+				}
+				cmds = append(cmds, t)
+			}
+		}
+
+		// The edges
+		edgeColour := "black"
+		if po.name == selected {
+			edgeColour = selectColour
+		}
+		for _, l := range o.E {
+			switch m {
+			case RENDER_SOLID_EDGES, RENDER_WIREFRAME:
+				cmds = append(cmds, drawCmd{kind: DRAW_EDGE, colour: edgeColour, alpha: alpha, pts: []screenPoint{toScreen(o.P[l[0]]), toScreen(o.P[l[1]])}})
+			case RENDER_HIDDEN_LINE:
+				for _, part := range hidden.visibleParts(o.P[l[0]], o.P[l[1]]) {
+					cmds = append(cmds, drawCmd{kind: DRAW_EDGE, colour: edgeColour, alpha: alpha, pts: part})
+				}
+			}
+		}
+
+		// The points, in their own colour if they have one.  Objects which are only points (eg point clouds) have them
+		// drawn larger, so they're easier to see
+		radius := float64(1)
+		if (len(o.S) == 0 && len(o.E) == 0) || m == RENDER_POINTS {
+			radius = 2
+		}
+		for _, l := range o.P {
+			if m != RENDER_SOLID_EDGES && m != RENDER_WIREFRAME && m != RENDER_POINTS {
+				break
+			}
+			fill := "black"
+			if l.C != nil {
+				fill = l.C.String()
+			}
+			cmds = append(cmds, drawCmd{kind: DRAW_POINT, colour: fill, alpha: alpha, radius: radius, pts: []screenPoint{toScreen(l)}})
+		}
+
+		if labels || po.name == selected {
+			labelCmds = append(labelCmds, drawCmd{kind: DRAW_LABEL, colour: "black", text: po.name, pts: []screenPoint{toScreen(o.Mid)}})
+		}
+	}
+	return append(cmds, labelCmds...)
+}
+
+// Returns the name of the object whose mid point is shown closest to the given position in the graph area, or an empty
+// string if none are within a few pixels of it
+func objectAt(world map[string]Object, x float64, y float64, graphWidth float64, graphHeight float64, step float64) (name string) {
+	best := float64(20) // Furthest distance away in pixels
+	for _, n := range sortedNames(world) {
+		p := screenPos(world[n].Mid, graphWidth, graphHeight, step)
+		if d := math.Hypot(p.X-x, p.Y-y); d < best {
+			best = d
+			name = n
+		}
+	}
+	return
+}
+
+// Returns the position in the graph area a point in world space is shown at
+func screenPos(p Point, graphWidth float64, graphHeight float64, step float64) screenPoint {
+	return screenPoint{X: (graphWidth / 2) + (p.X * step), Y: (graphHeight / 2) + ((p.Y * step) * -1)}
+}
+
+// Returns a gradient for filling a surface whose points all have their own colour, going between the two corners
+// whose colours differ the most.  Returns nil if the corners are all the same colour, or those two corners are shown
+// in the same place
+func surfaceGradient(pts []Point, s Surface, screen []screenPoint) *drawGradient {
+	a, b, best := 0, 0, 0
+	for i := range s {
+		for j := i + 1; j < len(s); j++ {
+			ci, cj := pts[s[i]].C, pts[s[j]].C
+			d := colourDistance(*ci, *cj)
+			if d > best {
+				a, b, best = i, j, d
+			}
+		}
+	}
+	if best == 0 || screen[a] == screen[b] {
+		return nil
+	}
+	return &drawGradient{from: screen[a], to: screen[b], fromColour: pts[s[a]].C.String(), toColour: pts[s[b]].C.String()}
+}
+
+// Returns whether every point of a surface has a position on the texture image
+func hasUVs(pts []Point, s Surface) bool {
+	for _, n := range s {
+		if pts[n].T == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the fill mapping a texture image onto a triangle shown at the given screen positions, going by the UVs of
+// its points.  Returns nil if the UVs are all in a line, as no part of the image would fit
+func triangleTexture(image string, pts []Point, tri Surface, screen []screenPoint) *drawTexture {
+	// Image positions go down from the top left corner, while V goes up from the bottom
+	var img [3]screenPoint
+	for i, n := range tri {
+		img[i] = screenPoint{X: pts[n].T.U, Y: 1 - pts[n].T.V}
+	}
+	i1 := screenPoint{img[1].X - img[0].X, img[1].Y - img[0].Y}
+	i2 := screenPoint{img[2].X - img[0].X, img[2].Y - img[0].Y}
+	s1 := screenPoint{screen[1].X - screen[0].X, screen[1].Y - screen[0].Y}
+	s2 := screenPoint{screen[2].X - screen[0].X, screen[2].Y - screen[0].Y}
+	det := i1.X*i2.Y - i2.X*i1.Y
+	if math.Abs(det) < 1e-12 {
+		return nil
+	}
+
+	// The transform takes the sides of the triangle on the image to its sides on the screen
+	a := (s1.X*i2.Y - s2.X*i1.Y) / det
+	b := (s1.Y*i2.Y - s2.Y*i1.Y) / det
+	c := (s2.X*i1.X - s1.X*i2.X) / det
+	d := (s2.Y*i1.X - s1.Y*i2.X) / det
+	e := screen[0].X - a*img[0].X - c*img[0].Y
+	f := screen[0].Y - b*img[0].X - d*img[0].Y
+	return &drawTexture{image: image, transform: [6]float64{a, b, c, d, e, f}}
+}
+
+// Returns the render mode with the given name
+func parseRenderMode(name string) (RenderMode, error) {
+	for i, n := range renderModeNames {
+		if name == n {
+			return RenderMode(i), nil
+		}
+	}
+	return RENDER_DEFAULT, errors.New("unknown render mode '" + name + "'.  It needs to be one of " + strings.Join(renderModeNames, ", "))
+}
+
+// Returns the name of the render mode, for scene files
+func (m RenderMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Returns the name of the render mode
+func (m RenderMode) String() string {
+	if m < 0 || int(m) >= len(renderModeNames) {
+		return "unknown"
+	}
+	return renderModeNames[m]
+}
+
+// Reads the render mode from its name, in scene files
+func (m *RenderMode) UnmarshalText(text []byte) (err error) {
+	*m, err = parseRenderMode(string(text))
+	return err
+}
+
+// Returns the render mode to use, which is the given one if this is the default
+func (m RenderMode) use(def RenderMode) RenderMode {
+	if m == RENDER_DEFAULT {
+		return def
+	}
+	return m
+}
@@ -0,0 +1,164 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reads a scene file, returning its objects as they'd first be shown in the web page.  The scene's camera zoom is
+// applied, but not its spin.  Objects with the default render mode are given the scene's one
+func readSceneFile(scenePath string) (map[string]Object, error) {
+	f, err := os.Open(scenePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := readScene(f)
+	if err != nil {
+		return nil, err
+	}
+	world, err := s.objects()
+	if err != nil {
+		return nil, err
+	}
+	for name, o := range world {
+		o.M = o.M.use(s.RenderMode)
+		world[name] = o
+	}
+	if zoom := s.Camera.Zoom; zoom != 0 && zoom != 1 {
+		m := scale(identityMatrix, zoom, zoom, zoom)
+		for name, o := range world {
+			for i, p := range o.P {
+				o.P[i] = transform(m, p)
+			}
+			o.Mid = transform(m, o.Mid)
+			world[name] = o
+		}
+	}
+	return world, nil
+}
+
+// Reads the texture images used by the objects, by name.  Textures are either base64 data URIs or image files, with
+// relative file names being found from the given directory
+func loadTextures(world map[string]Object, dir string) (map[string]image.Image, error) {
+	textures := make(map[string]image.Image)
+	for _, name := range sortedNames(world) {
+		t := world[name].T
+		if _, ok := textures[t]; t == "" || ok {
+			continue
+		}
+		var data []byte
+		var err error
+		if strings.HasPrefix(t, "data:") {
+			comma := strings.IndexByte(t, ',')
+			if comma < 0 || !strings.HasSuffix(t[:comma], ";base64") {
+				return nil, errors.New("texture: only base64 data URIs are understood")
+			}
+			data, err = base64.StdEncoding.DecodeString(t[comma+1:])
+		} else {
+			file := filepath.FromSlash(t)
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return nil, errors.New("texture '" + shortTextureName(t) + "': " + err.Error())
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("texture '" + shortTextureName(t) + "': " + err.Error())
+		}
+		textures[t] = img
+	}
+	return textures, nil
+}
+
+// Returns a texture name short enough for an error message, as data URIs can be very long
+func shortTextureName(name string) string {
+	if len(name) > 40 {
+		return name[:37] + "..."
+	}
+	return name
+}
+
+// Creates a file, and writes its contents using the given function
+func writeFile(filePath string, write func(w io.Writer) error) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Writes the objects to an OBJ file, along with an MTL file of the same name holding their colours.  If triangles is
+// true, the surfaces are split into triangles
+func writeOBJFile(world map[string]Object, objPath string, triangles bool) error {
+	if triangles {
+		world = triangulateWorld(world)
+	}
+	mtlPath := strings.TrimSuffix(objPath, filepath.Ext(objPath)) + ".mtl"
+	err := writeFile(objPath, func(w io.Writer) error {
+		return writeOBJ(w, world, filepath.Base(mtlPath))
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(mtlPath, func(w io.Writer) error {
+		return writeMTL(w, world)
+	})
+}
+
+// Draws the objects to an SVG file of the given size
+func writeSVGFile(world map[string]Object, svgPath string, width int, height int, labels bool) error {
+	step := math.Min(float64(width), float64(height)) / float64(30)
+	cmds := drawList(world, float64(width), float64(height), step, labels, "", RENDER_SOLID_EDGES)
+	return writeFile(svgPath, func(w io.Writer) error {
+		return writeSVG(w, cmds, float64(width), float64(height))
+	})
+}
+
+// Draws the objects to a PNG image of the given size, looking the same as the web page would in a window of that size,
+// with the side panel included.  Texture files are found from the given directory
+func writePNGFile(world map[string]Object, pngPath string, width int, height int, textureDir string) error {
+	textures, err := loadTextures(world, textureDir)
+	if err != nil {
+		return err
+	}
+	img := renderImage(world, width, height, textures)
+	return writeFile(pngPath, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// Returns an image of the whole page, as the web page would show the objects, using the given texture images
+func renderImage(world map[string]Object, width int, height int, textures map[string]image.Image) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawFrame(newImageRenderer(img, textures), frameInfo{
+		world:       world,
+		width:       float64(width),
+		height:      float64(height),
+		graphWidth:  float64(width) * 0.75,
+		graphHeight: float64(height) - 1,
+		step:        math.Min(float64(width), float64(height)) / float64(30),
+		mode:        RENDER_SOLID_EDGES,
+	})
+	return img
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A compiled expression.  It's called with the values of its variables, in the order they were given to parseExpr()
+type exprFunc func(vars []float64) float64
+
+// The functions which can be used in expressions
+var exprFunctions = map[string]func(float64) float64{
+	"abs":  math.Abs,
+	"cos":  math.Cos,
+	"exp":  math.Exp,
+	"log":  math.Log,
+	"sin":  math.Sin,
+	"sqrt": math.Sqrt,
+	"tan":  math.Tan,
+}
+
+// The named constants which can be used in expressions
+var exprConstants = map[string]float64{
+	"e":  math.E,
+	"pi": math.Pi,
+}
+
+// Parses expressions such as "sin(x) * cos(y + t) ^ 2"
+type exprParser struct {
+	src  string
+	pos  int
+	vars []string
+	used []bool // Whether each variable is used by the expression
+}
+
+// Compiles a mathematical expression using the given variable names.  Expressions can use numbers, the variables,
+// +, -, *, /, ^ (power), brackets, the constants pi and e, and the functions abs, cos, exp, log, sin, sqrt, and tan.
+// Also returned is whether each variable is used by the expression
+func parseExpr(src string, vars ...string) (exprFunc, []bool, error) {
+	p := exprParser{src: src, vars: vars, used: make([]bool, len(vars))}
+	f, err := p.sum()
+	if err == nil && p.skipSpace() < len(p.src) {
+		err = p.error("unexpected '" + string(p.src[p.pos]) + "'")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, p.used, nil
+}
+
+// Returns an error for the current position in the expression
+func (p *exprParser) error(msg string) error {
+	return errors.New("expression: " + msg + " at position " + strconv.Itoa(p.pos+1) + " of '" + p.src + "'")
+}
+
+// Parses a number, variable, constant, function call, or bracketed expression
+func (p *exprParser) primary() (exprFunc, error) {
+	if p.skipSpace() >= len(p.src) {
+		return nil, p.error("unexpected end")
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		f, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace() >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.error("missing ')'")
+		}
+		p.pos++
+		return f, nil
+
+	case (c >= '0' && c <= '9') || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && ((p.src[p.pos] >= '0' && p.src[p.pos] <= '9') || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponents, eg 1.5e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && p.src[end] >= '0' && p.src[end] <= '9' {
+				for end < len(p.src) && p.src[end] >= '0' && p.src[end] <= '9' {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		text := p.src[start:p.pos]
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.error("bad number '" + text + "'")
+		}
+		return func([]float64) float64 { return n }, nil
+
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_':
+		start := p.pos
+		for p.pos < len(p.src) {
+			c = p.src[p.pos]
+			if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_') {
+				break
+			}
+			p.pos++
+		}
+		name := strings.ToLower(p.src[start:p.pos])
+		for i, v := range p.vars {
+			if name == v {
+				p.used[i] = true
+				return func(vars []float64) float64 { return vars[i] }, nil
+			}
+		}
+		if n, ok := exprConstants[name]; ok {
+			return func([]float64) float64 { return n }, nil
+		}
+		fn, ok := exprFunctions[name]
+		if !ok {
+			p.pos = start
+			return nil, p.error("unknown name '" + name + "'")
+		}
+		if p.skipSpace() >= len(p.src) || p.src[p.pos] != '(' {
+			return nil, p.error("missing '(' after " + name)
+		}
+		arg, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		return func(vars []float64) float64 { return fn(arg(vars)) }, nil
+	}
+	return nil, p.error("unexpected '" + string(c) + "'")
+}
+
+// Parses a power, which binds tighter than a sign in front of it and is right associative, so -2^2 is -4 and 2^3^2
+// is 2^9
+func (p *exprParser) power() (exprFunc, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace() >= len(p.src) || p.src[p.pos] != '^' {
+		return base, nil
+	}
+	p.pos++
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(vars []float64) float64 { return math.Pow(base(vars), exp(vars)) }, nil
+}
+
+// Skips any white space, returning the new position
+func (p *exprParser) skipSpace() int {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+	return p.pos
+}
+
+// Parses terms added or subtracted together
+func (p *exprParser) sum() (exprFunc, error) {
+	f, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace() < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+		op := p.src[p.pos]
+		p.pos++
+		g, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		a := f
+		if op == '+' {
+			f = func(vars []float64) float64 { return a(vars) + g(vars) }
+		} else {
+			f = func(vars []float64) float64 { return a(vars) - g(vars) }
+		}
+	}
+	return f, nil
+}
+
+// Parses values multiplied or divided together
+func (p *exprParser) term() (exprFunc, error) {
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace() < len(p.src) && (p.src[p.pos] == '*' || p.src[p.pos] == '/') {
+		op := p.src[p.pos]
+		p.pos++
+		g, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		a := f
+		if op == '*' {
+			f = func(vars []float64) float64 { return a(vars) * g(vars) }
+		} else {
+			f = func(vars []float64) float64 { return a(vars) / g(vars) }
+		}
+	}
+	return f, nil
+}
+
+// Parses a value with an optional + or - sign in front of it
+func (p *exprParser) unary() (exprFunc, error) {
+	if p.skipSpace() < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] == '+') {
+		op := p.src[p.pos]
+		p.pos++
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == '+' {
+			return f, nil
+		}
+		return func(vars []float64) float64 { return -f(vars) }, nil
+	}
+	return p.power()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		src  string
+		x, y float64
+		want float64
+		used [2]bool // Whether x and y are used
+	}{
+		{"1 + 2 * 3", 0, 0, 7, [2]bool{}},
+		{"(1 + 2) * 3", 0, 0, 9, [2]bool{}},
+		{"8 / 4 / 2", 0, 0, 1, [2]bool{}},
+		{"10 - 4 - 3", 0, 0, 3, [2]bool{}},
+		{"-2^2", 0, 0, -4, [2]bool{}},
+		{"2^3^2", 0, 0, 512, [2]bool{}},
+		{"2^-1", 0, 0, 0.5, [2]bool{}},
+		{"--3", 0, 0, 3, [2]bool{}},
+		{"+x", 5, 0, 5, [2]bool{true, false}},
+		{"1.5e-3", 0, 0, 0.0015, [2]bool{}},
+		{"2E+2", 0, 0, 200, [2]bool{}},
+		{".5", 0, 0, 0.5, [2]bool{}},
+		{"x * y", 3, 4, 12, [2]bool{true, true}},
+		{"X + Y", 3, 4, 7, [2]bool{true, true}},
+		{"pi", 0, 0, math.Pi, [2]bool{}},
+		{"e ^ 2", 0, 0, math.E * math.E, [2]bool{}},
+		{"sqrt(y)", 0, 16, 4, [2]bool{false, true}},
+		{"abs(-x)", 2, 0, 2, [2]bool{true, false}},
+		{"sin(pi / 2) + cos(0)", 0, 0, 2, [2]bool{}},
+		{"exp(log(x))", 3, 0, 3, [2]bool{true, false}},
+		{"tan(0)", 0, 0, 0, [2]bool{}},
+		{"sin (x)^2", 1, 0, math.Pow(math.Sin(1), 2), [2]bool{true, false}},
+		{" \tx\n", 1, 0, 1, [2]bool{true, false}},
+	}
+	for _, tc := range tests {
+		f, used, err := parseExpr(tc.src, "x", "y")
+		if err != nil {
+			t.Errorf("%q: %v", tc.src, err)
+			continue
+		}
+		if got := f([]float64{tc.x, tc.y}); !near(got, tc.want, 1e-12) {
+			t.Errorf("%q is %v, wanted %v", tc.src, got, tc.want)
+		}
+		if [2]bool{used[0], used[1]} != tc.used {
+			t.Errorf("%q uses %v, wanted %v", tc.src, used, tc.used)
+		}
+	}
+
+	bad := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected end at position 1"},
+		{"sqrt 4", "missing '(' after sqrt at position 6"},
+		{"1e", "unexpected 'e' at position 2"},
+		{"((1)", "missing ')' at position 5"},
+		{"x y", "unexpected 'y' at position 3"},
+		{"1 +", "unexpected end at position 4"},
+		{"1..2", "bad number '1..2' at position 1"},
+		{"z", "unknown name 'z' at position 1"},
+		{"foo(1)", "unknown name 'foo'"},
+		{"2 * # 3", "unexpected '#' at position 5"},
+		{"1)", "unexpected ')' at position 2"},
+	}
+	for _, tc := range bad {
+		f, _, err := parseExpr(tc.src, "x", "y")
+		checkError(t, err, tc.want)
+		if f != nil {
+			t.Errorf("%q gave a function along with its error", tc.src)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The parts of a glTF 2.0 document needed for loading meshes
+type gltfDoc struct {
+	Scene       *int             `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Textures    []gltfTexture    `json:"textures"`
+	Images      []gltfImage      `json:"images"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name"`
+	Mesh        *int      `json:"mesh"`
+	Children    []int     `json:"children"`
+	Matrix      []float64 `json:"matrix"` // Column major
+	Translation []float64 `json:"translation"`
+	Rotation    []float64 `json:"rotation"` // Quaternion, as X, Y, Z, W
+	Scale       []float64 `json:"scale"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfMaterial struct {
+	Name string `json:"name"`
+	PBR  struct {
+		BaseColorFactor  []float64 `json:"baseColorFactor"`
+		BaseColorTexture *struct {
+			Index    int `json:"index"`
+			TexCoord int `json:"texCoord"` // Which of the TEXCOORD_n attributes to use
+		} `json:"baseColorTexture"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+// An image, either at a URI or stored in a buffer view
+type gltfImage struct {
+	URI        string `json:"uri"`
+	MimeType   string `json:"mimeType"`
+	BufferView *int   `json:"bufferView"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+// Walks the node tree of a glTF document, turning the meshes into objects
+type gltfLoader struct {
+	doc     *gltfDoc
+	buffers [][]byte
+	objects map[string]Object
+	name    string
+}
+
+// glTF primitive modes
+const (
+	GLTF_POINTS         = 0
+	GLTF_LINES          = 1
+	GLTF_TRIANGLES      = 4
+	GLTF_TRIANGLE_STRIP = 5
+	GLTF_TRIANGLE_FAN   = 6
+)
+
+// Parses a glTF 2.0 file (either .gltf JSON or .glb binary) into objects, one per mesh primitive in the default scene.
+// Each node's translation, rotation, and scale are applied to its meshes, and the base colour of each material is used
+// for the object colour.  The base colour texture becomes the object's texture, with images stored in the file turned
+// into data URIs.  External buffers are looked up by their URI in the buffers map
+func parseGLTF(data []byte, name string, buffers map[string][]byte) (map[string]Object, error) {
+	// Split binary files into their JSON and binary chunks
+	var bin []byte
+	if len(data) >= 12 && string(data[0:4]) == "glTF" {
+		if v := binary.LittleEndian.Uint32(data[4:8]); v != 2 {
+			return nil, errors.New("gltf: unsupported glb version " + strconv.Itoa(int(v)))
+		}
+		var jsonChunk []byte
+		for pos := 12; pos+8 <= len(data); {
+			// The length is checked as a uint64, as it may not fit in an int
+			length := binary.LittleEndian.Uint32(data[pos : pos+4])
+			chunkType := string(data[pos+4 : pos+8])
+			if uint64(pos)+8+uint64(length) > uint64(len(data)) {
+				return nil, errors.New("gltf: glb chunk is truncated")
+			}
+			switch chunkType {
+			case "JSON":
+				jsonChunk = data[pos+8 : pos+8+int(length)]
+			case "BIN\x00":
+				bin = data[pos+8 : pos+8+int(length)]
+			}
+			pos += 8 + int(length)
+		}
+		if jsonChunk == nil {
+			return nil, errors.New("gltf: glb file has no JSON chunk")
+		}
+		data = jsonChunk
+	}
+
+	var doc gltfDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.New("gltf: " + err.Error())
+	}
+
+	// Load the buffers
+	bufData := make([][]byte, len(doc.Buffers))
+	for i, b := range doc.Buffers {
+		switch {
+		case b.URI == "":
+			// The binary chunk of a glb file
+			bufData[i] = bin
+		case strings.HasPrefix(b.URI, "data:"):
+			comma := strings.IndexByte(b.URI, ',')
+			if comma < 0 || !strings.HasSuffix(b.URI[:comma], ";base64") {
+				return nil, errors.New("gltf: buffer " + strconv.Itoa(i) + " has an unsupported data URI")
+			}
+			d, err := base64.StdEncoding.DecodeString(b.URI[comma+1:])
+			if err != nil {
+				return nil, errors.New("gltf: buffer " + strconv.Itoa(i) + ": " + err.Error())
+			}
+			bufData[i] = d
+		default:
+			d, ok := buffers[b.URI]
+			if !ok {
+				d, ok = buffers[path.Base(b.URI)]
+			}
+			if !ok {
+				return nil, errors.New("gltf: external buffer '" + b.URI + "' wasn't provided")
+			}
+			bufData[i] = d
+		}
+		if len(bufData[i]) < b.ByteLength {
+			return nil, errors.New("gltf: buffer " + strconv.Itoa(i) + " is shorter than its byteLength")
+		}
+	}
+
+	// Work out which nodes to start from
+	if doc.Scene != nil && (*doc.Scene < 0 || *doc.Scene >= len(doc.Scenes)) {
+		return nil, errors.New("gltf: scene " + strconv.Itoa(*doc.Scene) + " doesn't exist")
+	}
+	var roots []int
+	switch {
+	case doc.Scene != nil:
+		roots = doc.Scenes[*doc.Scene].Nodes
+	case len(doc.Scenes) > 0:
+		roots = doc.Scenes[0].Nodes
+	default:
+		// No scenes, so use every node which isn't the child of another
+		isChild := make(map[int]bool)
+		for _, n := range doc.Nodes {
+			for _, c := range n.Children {
+				isChild[c] = true
+			}
+		}
+		for i := range doc.Nodes {
+			if !isChild[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	l := gltfLoader{doc: &doc, buffers: bufData, objects: make(map[string]Object), name: name}
+	for _, n := range roots {
+		if err := l.addNode(n, identityMatrix, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(l.objects) == 0 {
+		return nil, errors.New("gltf: no meshes found")
+	}
+	return l.objects, nil
+}
+
+// Returns the values of an accessor, with each element (eg a VEC3) as one slice.  Accessors without a buffer view are
+// all zeros, and can have at most zeroLimit elements, so a small file can't ask for a huge amount of memory
+func (l *gltfLoader) accessor(n int, zeroLimit int) ([][]float64, error) {
+	if n < 0 || n >= len(l.doc.Accessors) {
+		return nil, errors.New("gltf: accessor " + strconv.Itoa(n) + " doesn't exist")
+	}
+	a := l.doc.Accessors[n]
+	numComps := map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT2": 4, "MAT3": 9, "MAT4": 16}[a.Type]
+	compSize := map[int]int{5120: 1, 5121: 1, 5122: 2, 5123: 2, 5125: 4, 5126: 4}[a.ComponentType]
+	if numComps == 0 || compSize == 0 {
+		return nil, errors.New("gltf: accessor " + strconv.Itoa(n) + " has an unsupported type")
+	}
+	if a.Count < 0 || a.ByteOffset < 0 {
+		return nil, errors.New("gltf: accessor " + strconv.Itoa(n) + " has a negative count or offset")
+	}
+	if a.BufferView == nil {
+		if a.Count > zeroLimit {
+			return nil, errors.New("gltf: accessor " + strconv.Itoa(n) + " has " + strconv.Itoa(a.Count) +
+				" elements, but no buffer view holding them")
+		}
+		values := make([][]float64, a.Count)
+		for i := range values {
+			values[i] = make([]float64, numComps)
+		}
+		return values, nil
+	}
+	buf, stride, err := l.bufferView(*a.BufferView)
+	if err != nil {
+		return nil, err
+	}
+	size := numComps * compSize
+	if stride == 0 {
+		stride = size
+	}
+
+	// Check the last element fits in the view, without letting the sums overflow
+	start := a.ByteOffset
+	if a.Count > 0 && (start > len(buf)-size || a.Count-1 > (len(buf)-size-start)/stride) {
+		return nil, errors.New("gltf: accessor " + strconv.Itoa(n) + " runs past the end of its buffer view")
+	}
+	values := make([][]float64, a.Count)
+	for i := range values {
+		values[i] = make([]float64, numComps)
+		for c := 0; c < numComps; c++ {
+			b := buf[start+(i*stride)+(c*compSize):]
+			var v float64
+			switch a.ComponentType {
+			case 5120:
+				v = float64(int8(b[0]))
+				if a.Normalized {
+					v = math.Max(v/127, -1)
+				}
+			case 5121:
+				v = float64(b[0])
+				if a.Normalized {
+					v /= 255
+				}
+			case 5122:
+				v = float64(int16(binary.LittleEndian.Uint16(b)))
+				if a.Normalized {
+					v = math.Max(v/32767, -1)
+				}
+			case 5123:
+				v = float64(binary.LittleEndian.Uint16(b))
+				if a.Normalized {
+					v /= 65535
+				}
+			case 5125:
+				v = float64(binary.LittleEndian.Uint32(b))
+			case 5126:
+				v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			}
+			values[i][c] = v
+		}
+	}
+	return values, nil
+}
+
+// Returns the bytes of a buffer view and its stride, checking the view lies inside its buffer
+func (l *gltfLoader) bufferView(n int) ([]byte, int, error) {
+	if n < 0 || n >= len(l.doc.BufferViews) {
+		return nil, 0, errors.New("gltf: buffer view " + strconv.Itoa(n) + " doesn't exist")
+	}
+	view := l.doc.BufferViews[n]
+	if view.Buffer < 0 || view.Buffer >= len(l.buffers) {
+		return nil, 0, errors.New("gltf: buffer " + strconv.Itoa(view.Buffer) + " doesn't exist")
+	}
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return nil, 0, errors.New("gltf: buffer view " + strconv.Itoa(n) + " has a negative offset, length or stride")
+	}
+	buf := l.buffers[view.Buffer]
+	if view.ByteOffset > len(buf) || view.ByteLength > len(buf)-view.ByteOffset {
+		return nil, 0, errors.New("gltf: buffer view " + strconv.Itoa(n) + " runs past the end of its buffer")
+	}
+	return buf[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+// Returns the URI of a texture's image, which is a data URI for images stored in a buffer view.  Returns "" if the
+// texture has no image
+func (l *gltfLoader) texture(n int) (string, error) {
+	if n < 0 || n >= len(l.doc.Textures) {
+		return "", errors.New("gltf: texture " + strconv.Itoa(n) + " doesn't exist")
+	}
+	src := l.doc.Textures[n].Source
+	if src == nil {
+		return "", nil
+	}
+	if *src < 0 || *src >= len(l.doc.Images) {
+		return "", errors.New("gltf: image " + strconv.Itoa(*src) + " doesn't exist")
+	}
+	img := l.doc.Images[*src]
+	if img.BufferView == nil {
+		return img.URI, nil
+	}
+	data, _, err := l.bufferView(*img.BufferView)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + img.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Adds the meshes of a node and its children to the objects, transformed by the node's parent transform
+func (l *gltfLoader) addNode(n int, parent matrix, depth int) error {
+	if n < 0 || n >= len(l.doc.Nodes) {
+		return errors.New("gltf: node " + strconv.Itoa(n) + " doesn't exist")
+	}
+	if depth > len(l.doc.Nodes) {
+		return errors.New("gltf: node hierarchy has a loop")
+	}
+	node := l.doc.Nodes[n]
+	m := matrixMult(parent, gltfNodeMatrix(node))
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(l.doc.Meshes) {
+			return errors.New("gltf: mesh " + strconv.Itoa(*node.Mesh) + " doesn't exist")
+		}
+		mesh := l.doc.Meshes[*node.Mesh]
+		obName := node.Name
+		if obName == "" {
+			obName = mesh.Name
+		}
+		if obName == "" {
+			obName = l.name
+		}
+		for _, prim := range mesh.Primitives {
+			ob, err := l.primitive(prim, m)
+			if err != nil {
+				return err
+			}
+			if len(ob.P) > 0 {
+				name := uniqueName(l.objects, obName)
+				if err := ob.Validate(); err != nil {
+					return errors.New("gltf: object '" + name + "': " + err.Error())
+				}
+				l.objects[name] = ob
+			}
+		}
+	}
+	for _, c := range node.Children {
+		if err := l.addNode(c, m, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Converts a mesh primitive into an object, with its points transformed by the given matrix
+func (l *gltfLoader) primitive(prim gltfPrimitive, m matrix) (ob Object, err error) {
+	pos, ok := prim.Attributes["POSITION"]
+	if !ok {
+		return ob, nil
+	}
+	// Positions and indices of all zeros would only give degenerate surfaces, so they need a buffer view.  Other
+	// attributes are per point, so can't have more elements than there are positions
+	positions, err := l.accessor(pos, 0)
+	if err != nil {
+		return ob, err
+	}
+	var colours [][]float64
+	if c, ok := prim.Attributes["COLOR_0"]; ok {
+		if colours, err = l.accessor(c, len(positions)); err != nil {
+			return ob, err
+		}
+	}
+	var material *gltfMaterial
+	if prim.Material != nil && *prim.Material >= 0 && *prim.Material < len(l.doc.Materials) {
+		material = &l.doc.Materials[*prim.Material]
+	}
+	var uvs [][]float64
+	if material != nil && material.PBR.BaseColorTexture != nil {
+		tex := material.PBR.BaseColorTexture
+		if ob.T, err = l.texture(tex.Index); err != nil {
+			return ob, err
+		}
+		if t, ok := prim.Attributes["TEXCOORD_"+strconv.Itoa(tex.TexCoord)]; ok && ob.T != "" {
+			if uvs, err = l.accessor(t, len(positions)); err != nil {
+				return ob, err
+			}
+		}
+	}
+	for i, v := range positions {
+		if len(v) < 3 {
+			return ob, errors.New("gltf: positions must be VEC3")
+		}
+		p := transform(m, Point{X: v[0], Y: v[1], Z: v[2]})
+		if i < len(colours) && len(colours[i]) >= 3 {
+			p.C = &RGB{R: gltfColourValue(colours[i][0]), G: gltfColourValue(colours[i][1]), B: gltfColourValue(colours[i][2])}
+		}
+		if i < len(uvs) && len(uvs[i]) >= 2 {
+			// glTF texture coordinates go down from the top of the image
+			p.T = &UV{U: uvs[i][0], V: 1 - uvs[i][1]}
+		}
+		ob.P = append(ob.P, p)
+	}
+
+	// Without indices, the points are used in order
+	var idx []int
+	if prim.Indices != nil {
+		values, err := l.accessor(*prim.Indices, 0)
+		if err != nil {
+			return ob, err
+		}
+		for _, v := range values {
+			if int(v[0]) < 0 || int(v[0]) >= len(ob.P) {
+				return ob, errors.New("gltf: index " + strconv.Itoa(int(v[0])) + " is out of range")
+			}
+			idx = append(idx, int(v[0]))
+		}
+	} else {
+		for i := range ob.P {
+			idx = append(idx, i)
+		}
+	}
+
+	mode := GLTF_TRIANGLES
+	if prim.Mode != nil {
+		mode = *prim.Mode
+	}
+	switch mode {
+	case GLTF_POINTS:
+		// Nothing to connect, so the points are drawn by themselves
+	case GLTF_LINES:
+		for i := 0; i+1 < len(idx); i += 2 {
+			if idx[i] != idx[i+1] {
+				ob.E = append(ob.E, Edge{idx[i], idx[i+1]})
+			}
+		}
+	case GLTF_TRIANGLES:
+		for i := 0; i+2 < len(idx); i += 3 {
+			ob.S = append(ob.S, Surface{idx[i], idx[i+1], idx[i+2]})
+		}
+	case GLTF_TRIANGLE_STRIP:
+		for i := 0; i+2 < len(idx); i++ {
+			// Every second triangle has its winding flipped, to keep them all facing the same way
+			if i%2 == 0 {
+				ob.S = append(ob.S, Surface{idx[i], idx[i+1], idx[i+2]})
+			} else {
+				ob.S = append(ob.S, Surface{idx[i+1], idx[i], idx[i+2]})
+			}
+		}
+	case GLTF_TRIANGLE_FAN:
+		for i := 1; i+1 < len(idx); i++ {
+			ob.S = append(ob.S, Surface{idx[0], idx[i], idx[i+1]})
+		}
+	default:
+		return Object{}, nil
+	}
+	if len(ob.S) > 0 {
+		ob.S = dropDegenerate(ob.P, ob.S)
+		ob.E = surfaceEdges(ob.S)
+	}
+
+	ob.C = defaultColour
+	if material != nil {
+		if f := material.PBR.BaseColorFactor; len(f) >= 3 {
+			ob.C = cssColour(f[0], f[1], f[2])
+		}
+	}
+	ob.Mid = midPoint(ob.P)
+	return ob, nil
+}
+
+// Returns a colour value from 0 to 255, from a glTF colour value from 0 to 1
+func gltfColourValue(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+// Returns the local transform matrix of a node, either from its matrix or its translation, rotation, and scale
+func gltfNodeMatrix(node gltfNode) matrix {
+	if len(node.Matrix) == 16 {
+		// glTF matrices are column major, whereas ours are row major
+		m := make(matrix, 16)
+		for r := 0; r < 4; r++ {
+			for c := 0; c < 4; c++ {
+				m[(r*4)+c] = node.Matrix[(c*4)+r]
+			}
+		}
+		return m
+	}
+
+	// Scale first, then rotate, then translate
+	m := identityMatrix
+	if len(node.Scale) == 3 {
+		m = scale(m, node.Scale[0], node.Scale[1], node.Scale[2])
+	}
+	if len(node.Rotation) == 4 {
+		x, y, z, w := node.Rotation[0], node.Rotation[1], node.Rotation[2], node.Rotation[3]
+		rotateMatrix := matrix{
+			1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w), 0,
+			2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w), 0,
+			2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y), 0,
+			0, 0, 0, 1,
+		}
+		m = matrixMult(rotateMatrix, m)
+	}
+	if len(node.Translation) == 3 {
+		m = translate(m, node.Translation[0], node.Translation[1], node.Translation[2])
+	}
+	return m
+}
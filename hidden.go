@@ -0,0 +1,162 @@
+package main
+
+import "math"
+
+// The size in pixels of the cells surfaces are sorted into, so only the ones near a point need checking
+const occlusionCellSize = 32
+
+// The length in pixels of the pieces edges are split into when finding the parts hidden behind surfaces
+const occlusionStep = 2
+
+// A triangle of a surface as shown in the graph area, along with its Z depth at each corner
+type occluder struct {
+	pts  [3]screenPoint
+	z    [3]float64
+	minX float64
+	maxX float64
+	minY float64
+	maxY float64
+}
+
+// The surfaces of the world as shown in the graph area, sorted into cells so the ones in front of a point can be
+// found quickly
+type occlusionGrid struct {
+	tris     []occluder
+	cells    map[[2]int][]int
+	toScreen func(p Point) screenPoint
+	width    float64 // Size of the graph area, which is all that gets drawn
+	height   float64
+	depthTol float64 // How far in front of a point a surface has to be to hide it, in world space units
+}
+
+// Returns the occlusion grid for the surfaces of the objects whose render modes show surfaces or hide edges behind
+// them.  The toScreen function gives the position each point is shown at, and step is the number of pixels per world
+// space unit.  Only the parts of surfaces inside the graph area are kept, as nothing outside it is drawn
+func newOcclusionGrid(world map[string]Object, modes map[string]RenderMode, toScreen func(p Point) screenPoint, graphWidth float64, graphHeight float64, step float64) *occlusionGrid {
+	g := &occlusionGrid{cells: make(map[[2]int][]int), toScreen: toScreen, width: graphWidth, height: graphHeight,
+		depthTol: 0.5 / step}
+	for name, o := range world {
+		switch modes[name] {
+		case RENDER_SOLID, RENDER_SOLID_EDGES, RENDER_HIDDEN_LINE:
+		default:
+			continue
+		}
+		for _, s := range o.S {
+			for _, t := range triangulateSurface(o.P, s) {
+				var tri occluder
+				for i, n := range t {
+					tri.pts[i] = toScreen(o.P[n])
+					tri.z[i] = o.P[n].Z
+				}
+				tri.minX = math.Min(tri.pts[0].X, math.Min(tri.pts[1].X, tri.pts[2].X))
+				tri.maxX = math.Max(tri.pts[0].X, math.Max(tri.pts[1].X, tri.pts[2].X))
+				tri.minY = math.Min(tri.pts[0].Y, math.Min(tri.pts[1].Y, tri.pts[2].Y))
+				tri.maxY = math.Max(tri.pts[0].Y, math.Max(tri.pts[1].Y, tri.pts[2].Y))
+				if !(tri.maxX >= 0 && tri.minX <= g.width && tri.maxY >= 0 && tri.minY <= g.height) {
+					// Outside the graph area, or not somewhere which can be drawn at all
+					continue
+				}
+				index := len(g.tris)
+				g.tris = append(g.tris, tri)
+				lastX, lastY := occlusionCell(math.Min(tri.maxX, g.width)), occlusionCell(math.Min(tri.maxY, g.height))
+				for cx := occlusionCell(math.Max(tri.minX, 0)); cx <= lastX; cx++ {
+					for cy := occlusionCell(math.Max(tri.minY, 0)); cy <= lastY; cy++ {
+						g.cells[[2]int{cx, cy}] = append(g.cells[[2]int{cx, cy}], index)
+					}
+				}
+			}
+		}
+	}
+	return g
+}
+
+// Returns whether a point shown at the given position with the given Z depth is hidden behind any of the surfaces
+func (g *occlusionGrid) hides(p screenPoint, z float64) bool {
+	for _, i := range g.cells[[2]int{occlusionCell(p.X), occlusionCell(p.Y)}] {
+		t := &g.tris[i]
+		if p.X < t.minX || p.X > t.maxX || p.Y < t.minY || p.Y > t.maxY {
+			continue
+		}
+
+		// Find how far the point is towards each corner, which is only inside the triangle if all are positive
+		a, b, c := t.pts[0], t.pts[1], t.pts[2]
+		area := (b.X-a.X)*(c.Y-a.Y) - (c.X-a.X)*(b.Y-a.Y)
+		if area == 0 {
+			continue
+		}
+		wb := ((p.X-a.X)*(c.Y-a.Y) - (c.X-a.X)*(p.Y-a.Y)) / area
+		wc := ((b.X-a.X)*(p.Y-a.Y) - (p.X-a.X)*(b.Y-a.Y)) / area
+		wa := 1 - wb - wc
+		if wa < 0 || wb < 0 || wc < 0 {
+			continue
+		}
+
+		// Larger Z depths are nearer the viewer
+		if wa*t.z[0]+wb*t.z[1]+wc*t.z[2] > z+g.depthTol {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the parts of the line between two points which aren't hidden behind a surface, as the ends of each part in
+// the graph area.  Parts of the line outside the graph area are left out
+func (g *occlusionGrid) visibleParts(a Point, b Point) (parts [][]screenPoint) {
+	sa, sb := g.toScreen(a), g.toScreen(b)
+	dx, dy := sb.X-sa.X, sb.Y-sa.Y
+
+	// Cut the line down to the graph area, as the fractions of the way along it the part inside starts and ends at.
+	// This is the Liang-Barsky method, which checks the line against each side of the area in turn
+	from, to := float64(0), float64(1)
+	for _, side := range [4][2]float64{{-dx, sa.X}, {dx, g.width - sa.X}, {-dy, sa.Y}, {dy, g.height - sa.Y}} {
+		p, q := side[0], side[1]
+		if p == 0 {
+			if q < 0 {
+				return nil // Parallel to the side, and outside it
+			}
+			continue
+		}
+		if r := q / p; p < 0 {
+			from = math.Max(from, r)
+		} else {
+			to = math.Min(to, r)
+		}
+	}
+	if !(from < to) {
+		return nil
+	}
+
+	pieces := int(math.Ceil(math.Hypot(dx, dy) * (to - from) / occlusionStep))
+	if pieces < 1 {
+		pieces = 1
+	}
+	along := func(i float64) float64 {
+		return from + (to-from)*i/float64(pieces)
+	}
+	at := func(f float64) screenPoint {
+		return screenPoint{sa.X + dx*f, sa.Y + dy*f}
+	}
+
+	// Check the middle of each piece, joining neighbouring visible pieces together
+	start := -1
+	for i := 0; i <= pieces; i++ {
+		visible := false
+		if i < pieces {
+			f := along(float64(i) + 0.5)
+			visible = !g.hides(at(f), a.Z+(b.Z-a.Z)*f)
+		}
+		if visible && start < 0 {
+			start = i
+		}
+		if !visible && start >= 0 {
+			parts = append(parts, []screenPoint{at(along(float64(start))), at(along(float64(i)))})
+			start = -1
+		}
+	}
+	return parts
+}
+
+// Returns the occlusion grid cell a position is in, along either axis
+func occlusionCell(v float64) int {
+	return int(math.Floor(v / occlusionCellSize))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Checks surfaces and edges are only looked at inside the graph area, however far outside it they go
+func TestOcclusionGridClipping(t *testing.T) {
+	const width, height = 320, 240
+	nan := math.NaN()
+	toScreen := func(p Point) screenPoint {
+		return screenPoint{p.X, p.Y}
+	}
+	world := map[string]Object{
+		// A triangle far bigger than the graph area, in front of everything else
+		"huge": {P: []Point{{X: -1e9, Y: -1e9, Z: 1}, {X: 1e9, Y: -1e9, Z: 1}, {X: 0, Y: 1e9, Z: 1}}, S: []Surface{{0, 1, 2}}},
+		"away": {P: []Point{{X: 1000, Y: 0}, {X: 1100, Y: 0}, {X: 1000, Y: 100}}, S: []Surface{{0, 1, 2}}},
+		"nan":  {P: []Point{{X: nan, Y: 0}, {X: 10, Y: 0}, {X: 0, Y: 10}}, S: []Surface{{0, 1, 2}}},
+	}
+	modes := map[string]RenderMode{"huge": RENDER_SOLID, "away": RENDER_SOLID, "nan": RENDER_SOLID}
+	g := newOcclusionGrid(world, modes, toScreen, width, height, 1)
+	if len(g.tris) != 1 {
+		t.Errorf("%d triangles kept, wanted only the one over the graph area", len(g.tris))
+	}
+	if want := (width/occlusionCellSize + 1) * (height/occlusionCellSize + 1); len(g.cells) > want {
+		t.Errorf("triangles are sorted into %d cells, wanted at most the %d over the graph area", len(g.cells), want)
+	}
+
+	tests := []struct {
+		name  string
+		a, b  Point
+		parts int
+	}{
+		{"behind the triangle", Point{X: 10, Y: 10}, Point{X: 100, Y: 100}, 0},
+		{"in front of the triangle", Point{X: 10, Y: 10, Z: 2}, Point{X: 100, Y: 100, Z: 2}, 1},
+		{"crossing the graph area", Point{X: -1e12, Y: 100, Z: 2}, Point{X: 1e12, Y: 100, Z: 2}, 1},
+		{"coming out of the triangle", Point{X: 160, Y: -1e12, Z: 0}, Point{X: 160, Y: 1e12, Z: 2}, 1},
+		{"outside", Point{X: -100, Y: -100, Z: 2}, Point{X: -1e12, Y: 1e12, Z: 2}, 0},
+		{"along a side outside", Point{X: 400, Y: 0, Z: 2}, Point{X: 400, Y: 100, Z: 2}, 0},
+		{"a single point", Point{X: 5, Y: 5, Z: 2}, Point{X: 5, Y: 5, Z: 2}, 1},
+		{"not a number", Point{X: nan, Y: 5, Z: 2}, Point{X: 5, Y: 5, Z: 2}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := g.visibleParts(tc.a, tc.b)
+			if len(parts) != tc.parts {
+				t.Fatalf("%d visible parts, wanted %d", len(parts), tc.parts)
+			}
+			for _, part := range parts {
+				for _, p := range part {
+					if p.X < -1e-6 || p.X > width+1e-6 || p.Y < -1e-6 || p.Y > height+1e-6 {
+						t.Errorf("part %v goes outside the graph area", part)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// The angle in degrees below which neighbouring surfaces of a hull count as flat, so the edge between them isn't drawn
+const hullFlatAngle = 0.5
+
+// A triangle of a convex hull being built, along with the points which are still outside it
+type hullFace struct {
+	v       [3]int // Anticlockwise when looked at from outside
+	n       Point  // Unit normal, facing outwards
+	d       float64
+	outside []int
+	alive   bool
+}
+
+// Returns the convex hull of a set of points, which is the smallest convex solid holding all of them.  It's found with
+// the quickhull method, from "The Quickhull Algorithm for Convex Hulls" by C. Bradford Barber, David Dobkin, and Hannu
+// Huhdanpaa.  The hull's surfaces are triangles facing outwards, and its edges are the ones between surfaces which
+// aren't flat against each other.  The points keep their colours.  An error is returned if the points all lie on a
+// plane, as they have no solid hull
+func convexHull(pts []Point) (Object, error) {
+	if len(pts) < 4 {
+		return Object{}, errors.New("hull: at least 4 points are needed")
+	}
+
+	// Distances are compared against a tolerance based on the size of the point set
+	var lo, hi Point
+	for i, p := range pts {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsNaN(p.Z) {
+			return Object{}, errors.New("hull: point " + strconv.Itoa(i) + " isn't a number")
+		}
+		if i == 0 {
+			lo, hi = p, p
+		}
+		lo = Point{X: math.Min(lo.X, p.X), Y: math.Min(lo.Y, p.Y), Z: math.Min(lo.Z, p.Z)}
+		hi = Point{X: math.Max(hi.X, p.X), Y: math.Max(hi.Y, p.Y), Z: math.Max(hi.Z, p.Z)}
+	}
+	eps := 1e-9 * math.Max(vecLength(vecSub(hi, lo)), 1)
+
+	// Start with a tetrahedron, made from the two points furthest apart along an axis, the point furthest from the
+	// line between them, and the point furthest from the plane through those three
+	a, b := hullExtremes(pts)
+	line := vecSub(pts[b], pts[a])
+	c, best := -1, eps
+	for i, p := range pts {
+		if dist := vecLength(vecCross(line, vecSub(p, pts[a]))) / vecLength(line); dist > best {
+			c, best = i, dist
+		}
+	}
+	if c < 0 {
+		return Object{}, errors.New("hull: the points all lie on a line")
+	}
+	normal := vecNormalise(vecCross(line, vecSub(pts[c], pts[a])))
+	d, best := -1, eps
+	for i, p := range pts {
+		if dist := math.Abs(vecDot(normal, vecSub(p, pts[a]))); dist > best {
+			d, best = i, dist
+		}
+	}
+	if d < 0 {
+		return Object{}, errors.New("hull: the points all lie on a plane")
+	}
+	if vecDot(normal, vecSub(pts[d], pts[a])) > 0 {
+		// Keep the first face facing away from the fourth point
+		b, c = c, b
+	}
+	var faces []hullFace
+	addFace := func(v0, v1, v2 int) int {
+		n := vecNormalise(vecCross(vecSub(pts[v1], pts[v0]), vecSub(pts[v2], pts[v0])))
+		faces = append(faces, hullFace{v: [3]int{v0, v1, v2}, n: n, d: vecDot(n, pts[v0]), alive: true})
+		return len(faces) - 1
+	}
+	addFace(a, b, c)
+	addFace(a, d, b)
+	addFace(b, d, c)
+	addFace(c, d, a)
+
+	// Give each point to the first face it's outside of.  Points inside every face are inside the hull
+	assign := func(candidates []int, points []int) {
+		for _, p := range points {
+			for _, fi := range candidates {
+				f := &faces[fi]
+				if vecDot(f.n, pts[p])-f.d > eps {
+					f.outside = append(f.outside, p)
+					break
+				}
+			}
+		}
+	}
+	all := make([]int, 0, len(pts))
+	for i := range pts {
+		if i != a && i != b && i != c && i != d {
+			all = append(all, i)
+		}
+	}
+	assign([]int{0, 1, 2, 3}, all)
+
+	// Repeatedly add the furthest point outside a face, replacing the faces it can see with ones joining it to the
+	// edge around them.  New faces go on the end, so they're looked at later on
+	for fi := 0; fi < len(faces); fi++ {
+		if !faces[fi].alive || len(faces[fi].outside) == 0 {
+			continue
+		}
+		f := faces[fi]
+		eye, best := -1, 0.0
+		for _, p := range f.outside {
+			if dist := vecDot(f.n, pts[p]) - f.d; dist > best {
+				eye, best = p, dist
+			}
+		}
+
+		// Find the faces seen from the point, and the sides of them which aren't shared with another seen face
+		var visible []int
+		sides := make(map[[2]int]bool)
+		for i := range faces {
+			g := &faces[i]
+			if g.alive && vecDot(g.n, pts[eye])-g.d > eps {
+				visible = append(visible, i)
+				for j := range g.v {
+					sides[[2]int{g.v[j], g.v[(j+1)%3]}] = true
+				}
+			}
+		}
+		var orphans []int
+		for _, i := range visible {
+			faces[i].alive = false
+			for _, p := range faces[i].outside {
+				if p != eye {
+					orphans = append(orphans, p)
+				}
+			}
+			faces[i].outside = nil
+		}
+		var added []int
+		for _, i := range visible {
+			v := faces[i].v
+			for j := range v {
+				s, e := v[j], v[(j+1)%3]
+				if !sides[[2]int{e, s}] {
+					added = append(added, addFace(s, e, eye))
+				}
+			}
+		}
+		assign(added, orphans)
+	}
+
+	// Build the object from the faces left, using only the points on the hull
+	var ob Object
+	index := make(map[int]int)
+	for _, f := range faces {
+		if !f.alive {
+			continue
+		}
+		var s Surface
+		for _, v := range f.v {
+			n, ok := index[v]
+			if !ok {
+				n = len(ob.P)
+				index[v] = n
+				ob.P = append(ob.P, pts[v])
+			}
+			s = append(s, n)
+		}
+		ob.S = append(ob.S, s)
+	}
+	ob.E = featureEdges(ob.P, ob.S, hullFlatAngle)
+	ob.Mid = midPoint(ob.P)
+	return ob, nil
+}
+
+// Returns the two points furthest apart along whichever of the X, Y, or Z axes they spread out the most on
+func hullExtremes(pts []Point) (int, int) {
+	var a, b int
+	spread := -1.0
+	for axis := 0; axis < 3; axis++ {
+		lo, hi := 0, 0
+		for i, p := range pts {
+			if pointAxis(p, axis) < pointAxis(pts[lo], axis) {
+				lo = i
+			}
+			if pointAxis(p, axis) > pointAxis(pts[hi], axis) {
+				hi = i
+			}
+		}
+		if s := pointAxis(pts[hi], axis) - pointAxis(pts[lo], axis); s > spread {
+			a, b, spread = lo, hi, s
+		}
+	}
+	return a, b
+}
+
+// Returns the X, Y, or Z value of a point, for axis 0, 1, or 2
+func pointAxis(p Point, axis int) float64 {
+	switch axis {
+	case 0:
+		return p.X
+	case 1:
+		return p.Y
+	}
+	return p.Z
+}
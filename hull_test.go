@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConvexHull(t *testing.T) {
+	corners := cube(2).P
+	withInside := append(append([]Point(nil), corners...), Point{}, Point{X: 0.5, Y: -0.2, Z: 0.9}, Point{X: 1, Y: 0, Z: 0})
+	doubled := append(append([]Point(nil), corners...), corners...)
+	ball := sphere(1, 16, 8)
+
+	// Random points inside a cube, plus its corners so the hull is known
+	rnd := rand.New(rand.NewSource(1))
+	scattered := append([]Point(nil), corners...)
+	for i := 0; i < 500; i++ {
+		scattered = append(scattered, Point{X: rnd.Float64()*2 - 1, Y: rnd.Float64()*2 - 1, Z: rnd.Float64()*2 - 1})
+	}
+	var cloud []Point
+	for i := 0; i < 200; i++ {
+		cloud = append(cloud, Point{X: rnd.NormFloat64(), Y: rnd.NormFloat64(), Z: rnd.NormFloat64()})
+	}
+
+	tests := []struct {
+		name   string
+		pts    []Point
+		want   string  // Part of the error message, or "" for no error
+		volume float64 // Volume wanted, or 0 to skip checking it
+		edges  int     // Edges wanted, or 0 to skip checking them
+	}{
+		{"tetrahedron", []Point{{}, {X: 1}, {Y: 1}, {Z: 1}}, "", 1.0 / 6, 6},
+		{"cube", corners, "", 8, 12},
+		{"cube with points inside", withInside, "", 8, 12},
+		{"cube with every point twice", doubled, "", 8, 12},
+		{"scattered in a cube", scattered, "", 8, 12},
+		{"sphere", ball.P, "", meshVolume(ball), 0},
+		{"cloud", cloud, "", 0, 0},
+
+		{"too few points", corners[:3], "at least 4 points", 0, 0},
+		{"on a line", []Point{{}, {X: 1}, {X: 2}, {X: 3}, {X: 4}}, "on a line", 0, 0},
+		{"on a plane", plane(2, 3).P, "plane", 0, 0},
+		{"all the same", []Point{{X: 1}, {X: 1}, {X: 1}, {X: 1}}, "hull:", 0, 0},
+		{"not a number", []Point{{}, {X: 1}, {Y: math.NaN()}, {Z: 1}}, "point 2 isn't a number", 0, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := convexHull(tc.pts)
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			v := checkSolid(t, h)
+			if tc.volume != 0 && !near(v, tc.volume, 1e-9) {
+				t.Errorf("volume is %v, wanted %v", v, tc.volume)
+			}
+			if tc.edges != 0 && len(h.E) != tc.edges {
+				t.Errorf("hull has %d edges, wanted %d", len(h.E), tc.edges)
+			}
+
+			// Every point should be inside or on the hull, so behind or on the plane of every surface
+			for _, s := range h.S {
+				n := vecNormalise(surfaceNormal(h.P, s))
+				for i, p := range tc.pts {
+					if d := vecDot(n, vecSub(p, h.P[s[0]])); d > 1e-9 {
+						t.Fatalf("point %d is %v in front of surface %v", i, d, s)
+					}
+				}
+			}
+
+			// The hull should be convex, so no surface is in front of another's plane
+			for _, s := range h.S {
+				n := vecNormalise(surfaceNormal(h.P, s))
+				for _, p := range h.P {
+					if d := vecDot(n, vecSub(p, h.P[s[0]])); d > 1e-9 {
+						t.Fatalf("hull isn't convex, a point is %v in front of surface %v", d, s)
+					}
+				}
+			}
+		})
+	}
+}
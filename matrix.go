@@ -0,0 +1,166 @@
+package main
+
+import "math"
+
+type matrix []float64
+
+// The 4x4 identity matrix
+var identityMatrix = matrix{
+	1, 0, 0, 0,
+	0, 1, 0, 0,
+	0, 0, 1, 0,
+	0, 0, 0, 1,
+}
+
+// Multiplies one matrix by another
+func matrixMult(opMatrix matrix, m matrix) (resultMatrix matrix) {
+	top0 := m[0]
+	top1 := m[1]
+	top2 := m[2]
+	top3 := m[3]
+	upperMid0 := m[4]
+	upperMid1 := m[5]
+	upperMid2 := m[6]
+	upperMid3 := m[7]
+	lowerMid0 := m[8]
+	lowerMid1 := m[9]
+	lowerMid2 := m[10]
+	lowerMid3 := m[11]
+	bot0 := m[12]
+	bot1 := m[13]
+	bot2 := m[14]
+	bot3 := m[15]
+
+	resultMatrix = matrix{
+		(opMatrix[0] * top0) + (opMatrix[1] * upperMid0) + (opMatrix[2] * lowerMid0) + (opMatrix[3] * bot0), // 1st col, top
+		(opMatrix[0] * top1) + (opMatrix[1] * upperMid1) + (opMatrix[2] * lowerMid1) + (opMatrix[3] * bot1), // 2nd col, top
+		(opMatrix[0] * top2) + (opMatrix[1] * upperMid2) + (opMatrix[2] * lowerMid2) + (opMatrix[3] * bot2), // 3rd col, top
+		(opMatrix[0] * top3) + (opMatrix[1] * upperMid3) + (opMatrix[2] * lowerMid3) + (opMatrix[3] * bot3), // 4th col, top
+
+		(opMatrix[4] * top0) + (opMatrix[5] * upperMid0) + (opMatrix[6] * lowerMid0) + (opMatrix[7] * bot0), // 1st col, upper middle
+		(opMatrix[4] * top1) + (opMatrix[5] * upperMid1) + (opMatrix[6] * lowerMid1) + (opMatrix[7] * bot1), // 2nd col, upper middle
+		(opMatrix[4] * top2) + (opMatrix[5] * upperMid2) + (opMatrix[6] * lowerMid2) + (opMatrix[7] * bot2), // 3rd col, upper middle
+		(opMatrix[4] * top3) + (opMatrix[5] * upperMid3) + (opMatrix[6] * lowerMid3) + (opMatrix[7] * bot3), // 4th col, upper middle
+
+		(opMatrix[8] * top0) + (opMatrix[9] * upperMid0) + (opMatrix[10] * lowerMid0) + (opMatrix[11] * bot0), // 1st col, lower middle
+		(opMatrix[8] * top1) + (opMatrix[9] * upperMid1) + (opMatrix[10] * lowerMid1) + (opMatrix[11] * bot1), // 2nd col, lower middle
+		(opMatrix[8] * top2) + (opMatrix[9] * upperMid2) + (opMatrix[10] * lowerMid2) + (opMatrix[11] * bot2), // 3rd col, lower middle
+		(opMatrix[8] * top3) + (opMatrix[9] * upperMid3) + (opMatrix[10] * lowerMid3) + (opMatrix[11] * bot3), // 4th col, lower middle
+
+		(opMatrix[12] * top0) + (opMatrix[13] * upperMid0) + (opMatrix[14] * lowerMid0) + (opMatrix[15] * bot0), // 1st col, bottom
+		(opMatrix[12] * top1) + (opMatrix[13] * upperMid1) + (opMatrix[14] * lowerMid1) + (opMatrix[15] * bot1), // 2nd col, bottom
+		(opMatrix[12] * top2) + (opMatrix[13] * upperMid2) + (opMatrix[14] * lowerMid2) + (opMatrix[15] * bot2), // 3rd col, bottom
+		(opMatrix[12] * top3) + (opMatrix[13] * upperMid3) + (opMatrix[14] * lowerMid3) + (opMatrix[15] * bot3), // 4th col, bottom
+	}
+	return resultMatrix
+}
+
+// Rotates a transformation matrix around the X axis by the given degrees
+func rotateAroundX(m matrix, degrees float64) matrix {
+	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
+	rotateXMatrix := matrix{
+		1, 0, 0, 0,
+		0, math.Cos(rad), -math.Sin(rad), 0,
+		0, math.Sin(rad), math.Cos(rad), 0,
+		0, 0, 0, 1,
+	}
+	return matrixMult(rotateXMatrix, m)
+}
+
+// Rotates a transformation matrix around the Y axis by the given degrees
+func rotateAroundY(m matrix, degrees float64) matrix {
+	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
+	rotateYMatrix := matrix{
+		math.Cos(rad), 0, math.Sin(rad), 0,
+		0, 1, 0, 0,
+		-math.Sin(rad), 0, math.Cos(rad), 0,
+		0, 0, 0, 1,
+	}
+	return matrixMult(rotateYMatrix, m)
+}
+
+// Rotates a transformation matrix around the Z axis by the given degrees
+func rotateAroundZ(m matrix, degrees float64) matrix {
+	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
+	rotateZMatrix := matrix{
+		math.Cos(rad), -math.Sin(rad), 0, 0,
+		math.Sin(rad), math.Cos(rad), 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+	return matrixMult(rotateZMatrix, m)
+}
+
+// Scales a transformation matrix by the given X, Y, and Z values
+func scale(m matrix, x float64, y float64, z float64) matrix {
+	scaleMatrix := matrix{
+		x, 0, 0, 0,
+		0, y, 0, 0,
+		0, 0, z, 0,
+		0, 0, 0, 1,
+	}
+	return matrixMult(scaleMatrix, m)
+}
+
+// Transform the XYZ co-ordinates using the values from the transformation matrix
+func transform(m matrix, p Point) (t Point) {
+	top0 := m[0]
+	top1 := m[1]
+	top2 := m[2]
+	top3 := m[3]
+	upperMid0 := m[4]
+	upperMid1 := m[5]
+	upperMid2 := m[6]
+	upperMid3 := m[7]
+	lowerMid0 := m[8]
+	lowerMid1 := m[9]
+	lowerMid2 := m[10]
+	lowerMid3 := m[11]
+	//bot0 := m[12] // The fourth row values can be ignored for 3D matrices
+	//bot1 := m[13]
+	//bot2 := m[14]
+	//bot3 := m[15]
+
+	t = p // Keep the point number and colour
+	t.X = (top0 * p.X) + (top1 * p.Y) + (top2 * p.Z) + top3
+	t.Y = (upperMid0 * p.X) + (upperMid1 * p.Y) + (upperMid2 * p.Z) + upperMid3
+	t.Z = (lowerMid0 * p.X) + (lowerMid1 * p.Y) + (lowerMid2 * p.Z) + lowerMid3
+	return
+}
+
+// Translates (moves) a transformation matrix by the given X, Y and Z values
+func translate(m matrix, translateX float64, translateY float64, translateZ float64) matrix {
+	translateMatrix := matrix{
+		1, 0, 0, translateX,
+		0, 1, 0, translateY,
+		0, 0, 1, translateZ,
+		0, 0, 0, 1,
+	}
+	return matrixMult(translateMatrix, m)
+}
+
+// Returns the inverse of a transformation matrix made from rotations, scales, and translations.  False is returned if
+// the matrix can't be inverted, eg because it scales by zero
+func invertMatrix(m matrix) (matrix, bool) {
+	// Invert the rotation and scale part using its cofactors
+	c00 := m[5]*m[10] - m[6]*m[9]
+	c01 := m[6]*m[8] - m[4]*m[10]
+	c02 := m[4]*m[9] - m[5]*m[8]
+	det := m[0]*c00 + m[1]*c01 + m[2]*c02
+	if math.Abs(det) < 1e-12 {
+		return nil, false
+	}
+	a := [9]float64{
+		c00 / det, (m[2]*m[9] - m[1]*m[10]) / det, (m[1]*m[6] - m[2]*m[5]) / det,
+		c01 / det, (m[0]*m[10] - m[2]*m[8]) / det, (m[2]*m[4] - m[0]*m[6]) / det,
+		c02 / det, (m[1]*m[8] - m[0]*m[9]) / det, (m[0]*m[5] - m[1]*m[4]) / det,
+	}
+
+	// The inverse translation undoes the original one, after the inverse rotation and scale
+	return matrix{
+		a[0], a[1], a[2], -(a[0]*m[3] + a[1]*m[7] + a[2]*m[11]),
+		a[3], a[4], a[5], -(a[3]*m[3] + a[4]*m[7] + a[5]*m[11]),
+		a[6], a[7], a[8], -(a[6]*m[3] + a[7]*m[7] + a[8]*m[11]),
+		0, 0, 0, 1,
+	}, true
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The angle in degrees surfaces need to meet at for the edge between them to be a feature edge, when none is given
+const defaultCreaseAngle = 30
+
+// How an object's edges are found
+type EdgeMode int
+
+const (
+	EDGES_GIVEN    EdgeMode = iota // The edges listed by the object itself
+	EDGES_ALL                      // Every side of every surface
+	EDGES_BOUNDARY                 // Only sides used by a single surface, eg around the outside of a plane
+	EDGES_FEATURE                  // Boundary sides, plus the sides between surfaces meeting at more than the crease angle
+)
+
+// The names of the edge modes, as used in scene files and the page query string
+var edgeModeNames = []string{"given", "all", "boundary", "feature"}
+
+// Returns the average colour of the points of a surface.  If any of the points don't have their own colour, false is
+// returned instead
+func averageColour(pts []Point, s Surface) (RGB, bool) {
+	if len(s) == 0 {
+		return RGB{}, false
+	}
+	var r, g, b int
+	for _, n := range s {
+		c := pts[n].C
+		if c == nil {
+			return RGB{}, false
+		}
+		r += int(c.R)
+		g += int(c.G)
+		b += int(c.B)
+	}
+	return RGB{R: uint8(r / len(s)), G: uint8(g / len(s)), B: uint8(b / len(s))}, true
+}
+
+// Returns the key used to look up an edge, which is the same whichever way round the edge's points are given
+func edgeKey(a, b int) [2]int {
+	if a > b {
+		return [2]int{b, a}
+	}
+	return [2]int{a, b}
+}
+
+// Returns the surfaces with any corners in the same place as an earlier corner left out, then drops those left with
+// less than three corners.  Surfaces which have collapsed like this are common in imported meshes, eg from triangle
+// strips
+func dropDegenerate(pts []Point, surfaces []Surface) []Surface {
+	kept := surfaces[:0]
+	for _, s := range surfaces {
+		var corners Surface
+		for _, n := range s {
+			if n < 0 || n >= len(pts) {
+				// Leave it for Validate() to report
+				corners = s
+				break
+			}
+			dup := false
+			for _, c := range corners {
+				if pts[c].X == pts[n].X && pts[c].Y == pts[n].Y && pts[c].Z == pts[n].Z {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				corners = append(corners, n)
+			}
+		}
+		if len(corners) >= 3 {
+			kept = append(kept, corners)
+		}
+	}
+	return kept
+}
+
+// Returns the object with the surfaces dropDegenerate() leaves out removed, keeping the surface colours lined up with
+// the surfaces left
+func dropDegenerateSurfaces(o Object) Object {
+	var kept []Surface
+	var colours []string
+	for i, s := range o.S {
+		if d := dropDegenerate(o.P, []Surface{s}); len(d) == 1 {
+			kept = append(kept, d[0])
+			colours = append(colours, o.surfaceColour(i))
+		}
+	}
+	o.S, o.SC = kept, trimColours(colours)
+	return o
+}
+
+// Returns the sides used by only one surface, which are the edges around the outside of a mesh and any holes in it
+func boundaryEdges(surfaces []Surface) (edges []Edge) {
+	var order [][2]int
+	count := make(map[[2]int]int)
+	sides := make(map[[2]int]Edge) // The first way round each side was seen
+	for _, s := range surfaces {
+		for i := range s {
+			a, b := s[i], s[(i+1)%len(s)]
+			if a == b {
+				continue
+			}
+			key := edgeKey(a, b)
+			if count[key] == 0 {
+				order = append(order, key)
+				sides[key] = Edge{a, b}
+			}
+			count[key]++
+		}
+	}
+	for _, key := range order {
+		if count[key] == 1 {
+			edges = append(edges, sides[key])
+		}
+	}
+	return edges
+}
+
+// Returns the edges of an object found in the given way.  Feature edges use the crease angle, in degrees.  Objects
+// without surfaces keep their own edges whatever the mode, as there's nothing to find them from
+func deriveEdges(ob Object, mode EdgeMode, creaseAngle float64) []Edge {
+	if len(ob.S) == 0 {
+		return ob.E
+	}
+	switch mode {
+	case EDGES_ALL:
+		return surfaceEdges(ob.S)
+	case EDGES_BOUNDARY:
+		return boundaryEdges(ob.S)
+	case EDGES_FEATURE:
+		return featureEdges(ob.P, ob.S, creaseAngle)
+	}
+	return ob.E
+}
+
+// Returns the edges between surfaces which meet at more than the given angle (in degrees), along with any edges on
+// the boundary of the mesh.  Flat or gently curved areas made up of many surfaces then don't have their inner edges
+// drawn
+func featureEdges(pts []Point, surfaces []Surface, creaseAngle float64) (edges []Edge) {
+	var order [][2]int
+	adjacent := make(map[[2]int][]int) // The surfaces on each side of an edge
+	normals := make([]Point, len(surfaces))
+	for i, s := range surfaces {
+		normals[i] = surfaceNormal(pts, s)
+		for j := range s {
+			a, b := s[j], s[(j+1)%len(s)]
+			if a == b {
+				continue
+			}
+			key := edgeKey(a, b)
+			if _, ok := adjacent[key]; !ok {
+				order = append(order, key)
+			}
+			adjacent[key] = append(adjacent[key], i)
+		}
+	}
+
+	// Keep the edges which aren't shared by exactly two surfaces, or whose surfaces meet at a sharp enough angle
+	limit := math.Cos((math.Pi / 180) * creaseAngle)
+	for _, key := range order {
+		if faces := adjacent[key]; len(faces) == 2 {
+			n1, n2 := normals[faces[0]], normals[faces[1]]
+			if (n1.X*n2.X)+(n1.Y*n2.Y)+(n1.Z*n2.Z) >= limit {
+				continue
+			}
+		}
+		edges = append(edges, Edge{key[0], key[1]})
+	}
+	return edges
+}
+
+// Moves a set of objects so their combined bounding box is centred on the origin, then scales them so the longest
+// side of the bounding box is the given size
+func fitObjects(obs map[string]Object, size float64) {
+	m, ok := fitMatrix(obs, size)
+	if !ok {
+		// Nothing to fit
+		return
+	}
+	for name, o := range obs {
+		newPoints := make([]Point, 0, len(o.P))
+		for _, p := range o.P {
+			newPoints = append(newPoints, transform(m, p))
+		}
+		o.P = newPoints
+		o.Mid = transform(m, o.Mid)
+		obs[name] = o
+	}
+}
+
+// Returns the transform used by fitObjects().  False is returned if the objects have no size to fit
+func fitMatrix(obs map[string]Object, size float64) (matrix, bool) {
+	minX, minY, minZ := math.Inf(1), math.Inf(1), math.Inf(1)
+	maxX, maxY, maxZ := math.Inf(-1), math.Inf(-1), math.Inf(-1)
+	for _, o := range obs {
+		for _, p := range o.P {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+			minZ, maxZ = math.Min(minZ, p.Z), math.Max(maxZ, p.Z)
+		}
+	}
+	longest := math.Max(maxX-minX, math.Max(maxY-minY, maxZ-minZ))
+	if math.IsInf(longest, 0) || math.IsNaN(longest) || longest == 0 {
+		return nil, false
+	}
+
+	// Build the transform to centre then scale the objects
+	m := translate(identityMatrix, -(minX+maxX)/2, -(minY+maxY)/2, -(minZ+maxZ)/2)
+	return scale(m, size/longest, size/longest, size/longest), true
+}
+
+// Returns the edges in a, followed by any edges in b which aren't already in a
+func mergeEdges(a []Edge, b []Edge) []Edge {
+	seen := make(map[[2]int]bool, len(a))
+	for _, e := range a {
+		seen[edgeKey(e[0], e[1])] = true
+	}
+	for _, e := range b {
+		key := edgeKey(e[0], e[1])
+		if !seen[key] {
+			seen[key] = true
+			a = append(a, e)
+		}
+	}
+	return a
+}
+
+// Returns the point in the middle of the given points
+func midPoint(pts []Point) (mid Point) {
+	if len(pts) == 0 {
+		return
+	}
+	for _, p := range pts {
+		mid.X += p.X
+		mid.Y += p.Y
+		mid.Z += p.Z
+	}
+	numPts := float64(len(pts))
+	mid.X /= numPts
+	mid.Y /= numPts
+	mid.Z /= numPts
+	return
+}
+
+// Returns the edge mode with the given name
+func parseEdgeMode(name string) (EdgeMode, error) {
+	for i, n := range edgeModeNames {
+		if name == n {
+			return EdgeMode(i), nil
+		}
+	}
+	return EDGES_GIVEN, errors.New("unknown edge mode '" + name + "'.  It needs to be one of " + strings.Join(edgeModeNames, ", "))
+}
+
+// Returns the names of the objects, sorted alphabetically
+func sortedNames(world map[string]Object) []string {
+	names := make([]string, 0, len(world))
+	for name := range world {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the unique edges around the boundary of each surface, in the order they're first seen
+func surfaceEdges(surfaces []Surface) (edges []Edge) {
+	seen := make(map[[2]int]bool)
+	for _, s := range surfaces {
+		for i := range s {
+			a, b := s[i], s[(i+1)%len(s)]
+			if a == b {
+				continue
+			}
+			key := edgeKey(a, b)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, Edge{a, b})
+		}
+	}
+	return edges
+}
+
+// Returns the unit length normal of a surface.  This uses Newell's method, so works for surfaces with more than three
+// points too
+func surfaceNormal(pts []Point, s Surface) (n Point) {
+	for i := range s {
+		cur, next := pts[s[i]], pts[s[(i+1)%len(s)]]
+		n.X += (cur.Y - next.Y) * (cur.Z + next.Z)
+		n.Y += (cur.Z - next.Z) * (cur.X + next.X)
+		n.Z += (cur.X - next.X) * (cur.Y + next.Y)
+	}
+	if l := math.Sqrt((n.X * n.X) + (n.Y * n.Y) + (n.Z * n.Z)); l > 0 {
+		n.X /= l
+		n.Y /= l
+		n.Z /= l
+	}
+	return n
+}
+
+// Returns the given name if it's not already in use, otherwise adds a number to the end of it to make it unique
+func uniqueName(used map[string]Object, name string) string {
+	if _, ok := used[name]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		n := name + " " + strconv.Itoa(i)
+		if _, ok := used[n]; !ok {
+			return n
+		}
+	}
+}
+
+// Returns the name of the edge mode
+func (m EdgeMode) String() string {
+	if m < 0 || int(m) >= len(edgeModeNames) {
+		return "unknown"
+	}
+	return edgeModeNames[m]
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDeriveEdges(t *testing.T) {
+	// Three triangles sharing the side from point 0 to 1, which isn't a boundary but is always a feature
+	fin := Object{
+		P: []Point{{X: 0}, {X: 1}, {Y: 1}, {Y: -1}, {Z: 1}},
+		S: []Surface{{0, 1, 2}, {1, 0, 3}, {0, 1, 4}},
+	}
+	wire := Object{P: []Point{{X: 0}, {X: 1}, {X: 2}}, E: []Edge{{0, 1}, {1, 2}}}
+	withGiven := plane(2, 1)
+	withGiven.E = []Edge{{0, 3}}
+
+	tests := []struct {
+		name  string
+		o     Object
+		mode  EdgeMode
+		angle float64
+		want  int
+	}{
+		{"cube all", cube(2), EDGES_ALL, 0, 12},
+		{"cube boundary", cube(2), EDGES_BOUNDARY, 0, 0},
+		{"cube feature", cube(2), EDGES_FEATURE, defaultCreaseAngle, 12},
+		{"cube feature above its corners", cube(2), EDGES_FEATURE, 91, 0},
+		{"triangulated cube all", triangulateObject(cube(2)), EDGES_ALL, 0, 18},
+		{"triangulated cube feature", triangulateObject(cube(2)), EDGES_FEATURE, defaultCreaseAngle, 12},
+		{"plane all", plane(4, 4), EDGES_ALL, 0, 40},
+		{"plane boundary", plane(4, 4), EDGES_BOUNDARY, 0, 16},
+		{"plane feature", plane(4, 4), EDGES_FEATURE, defaultCreaseAngle, 16},
+		{"plane feature at no angle", plane(4, 4), EDGES_FEATURE, 0, 16},
+		{"cylinder all", cylinder(1, 2, 8), EDGES_ALL, 0, 24},
+		{"cylinder sides sharper than crease", cylinder(1, 2, 8), EDGES_FEATURE, 44, 24},
+		{"cylinder sides smoother than crease", cylinder(1, 2, 8), EDGES_FEATURE, 46, 16},
+		{"cylinder ends at crease", cylinder(1, 2, 8), EDGES_FEATURE, 89, 16},
+		{"cylinder ends smoother than crease", cylinder(1, 2, 8), EDGES_FEATURE, 91, 0},
+		{"fin boundary", fin, EDGES_BOUNDARY, 0, 6},
+		{"fin feature", fin, EDGES_FEATURE, 180, 7},
+		{"given", withGiven, EDGES_GIVEN, 0, 1},
+		{"wireframe all", wire, EDGES_ALL, 0, 2},
+		{"wireframe boundary", wire, EDGES_BOUNDARY, 0, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			edges := deriveEdges(tc.o, tc.mode, tc.angle)
+			if len(edges) != tc.want {
+				t.Fatalf("%d edges, wanted %d", len(edges), tc.want)
+			}
+			seen := make(map[[2]int]bool)
+			for _, e := range edges {
+				if seen[edgeKey(e[0], e[1])] {
+					t.Errorf("edge %v is listed more than once", e)
+				}
+				seen[edgeKey(e[0], e[1])] = true
+			}
+			o := tc.o
+			o.E = edges
+			if err := o.Validate(); err != nil {
+				t.Errorf("object with the edges is invalid: %v", err)
+			}
+		})
+	}
+
+	// The boundary of a plane is all round its outside, following the surfaces' sides
+	square := plane(4, 4)
+	for _, e := range boundaryEdges(square.S) {
+		a, b := square.P[e[0]], square.P[e[1]]
+		if !(a.X == b.X && math.Abs(a.X) == 2) && !(a.Z == b.Z && math.Abs(a.Z) == 2) {
+			t.Errorf("boundary edge from %v to %v isn't on the outside", a, b)
+		}
+	}
+
+	// Sides with both ends at the same point aren't edges
+	pinched := []Surface{{0, 1, 1, 2}}
+	pts := []Point{{X: 0}, {X: 1}, {Y: 1}}
+	if n := len(boundaryEdges(pinched)); n != 3 {
+		t.Errorf("pinched triangle has %d boundary edges, wanted 3", n)
+	}
+	if n := len(featureEdges(pts, pinched, defaultCreaseAngle)); n != 3 {
+		t.Errorf("pinched triangle has %d feature edges, wanted 3", n)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// An object being built up while an OBJ file is parsed
+type objGroup struct {
+	name   string
+	ob     Object
+	lines  []Edge         // Edges given by l statements
+	points map[[2]int]int // Maps the file wide vertex and texture vertex numbers to the point number inside this object
+}
+
+// The parts of an MTL material used for drawing
+type objMaterial struct {
+	colour  string // CSS colour, from the diffuse (Kd) colour
+	texture string // File name of the diffuse texture image (map_Kd), if there is one
+}
+
+// Parses a Wavefront OBJ file into objects, one per o/g group.  Faces are turned into surfaces, with the edges derived
+// from the face boundaries plus any given by lines.  Vertex colours, written as "v x y z r g b", become point colours,
+// and texture vertices (vt) become point UVs.  A vertex used with different texture vertices becomes a separate point
+// for each one.  Materials are as returned by parseMTL().  Each object takes the colour and texture of the material
+// used when it starts, with faces using a different colour after that given their own surface colour.  As objects only
+// have one texture, changing to a material with a different one starts a new object
+func parseOBJ(r io.Reader, name string, materials map[string]objMaterial) (map[string]Object, error) {
+	var verts []Point
+	var uvs []UV
+	var groups []*objGroup
+	var cur *objGroup
+	used := make(map[string]Object)
+	colour, texture, mtlName := defaultColour, "", ""
+
+	lineNum := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, objError(lineNum, "vertex needs X, Y, and Z values")
+			}
+			var p Point
+			var err error
+			if p.X, err = strconv.ParseFloat(fields[1], 64); err == nil {
+				if p.Y, err = strconv.ParseFloat(fields[2], 64); err == nil {
+					p.Z, err = strconv.ParseFloat(fields[3], 64)
+				}
+			}
+			if err != nil {
+				return nil, objError(lineNum, "bad vertex value")
+			}
+			if len(fields) >= 7 {
+				var rgb [3]float64
+				for i := range rgb {
+					if rgb[i], err = strconv.ParseFloat(fields[4+i], 64); err != nil {
+						return nil, objError(lineNum, "bad vertex colour")
+					}
+				}
+				c := floatRGB(rgb[0], rgb[1], rgb[2])
+				p.C = &c
+			}
+			verts = append(verts, p)
+
+		case "vt":
+			if len(fields) < 2 {
+				return nil, objError(lineNum, "texture vertex needs a U value")
+			}
+			var uv UV
+			var err error
+			if uv.U, err = strconv.ParseFloat(fields[1], 64); err == nil && len(fields) > 2 {
+				uv.V, err = strconv.ParseFloat(fields[2], 64)
+			}
+			if err != nil {
+				return nil, objError(lineNum, "bad texture vertex value")
+			}
+			uvs = append(uvs, uv)
+
+		case "f", "l", "p":
+			minVerts := map[string]int{"f": 3, "l": 2, "p": 1}[fields[0]]
+			if len(fields)-1 < minVerts {
+				return nil, objError(lineNum, "'"+fields[0]+"' needs at least "+strconv.Itoa(minVerts)+" vertices")
+			}
+			if cur == nil {
+				// Start a new object for the statements which follow
+				groupName := name
+				if _, ok := used[groupName]; ok && mtlName != "" {
+					groupName += " (" + mtlName + ")"
+				}
+				cur = &objGroup{name: uniqueName(used, groupName), points: make(map[[2]int]int)}
+				cur.ob.C, cur.ob.T = colour, texture
+				used[cur.name] = Object{}
+				groups = append(groups, cur)
+			}
+			var pts []int
+			for _, f := range fields[1:] {
+				// The vertex and texture vertex numbers are used, any normal numbers are ignored
+				nums := strings.Split(f, "/")
+				v, err := objIndex(nums[0], len(verts))
+				if err != nil {
+					return nil, objError(lineNum, "vertex number '"+f+"' "+err.Error())
+				}
+				vt := -1
+				if len(nums) > 1 && nums[1] != "" {
+					if vt, err = objIndex(nums[1], len(uvs)); err != nil {
+						return nil, objError(lineNum, "texture vertex number '"+f+"' "+err.Error())
+					}
+				}
+				key := [2]int{v, vt}
+				n, ok := cur.points[key]
+				if !ok {
+					n = len(cur.ob.P)
+					p := verts[v]
+					if vt >= 0 {
+						uv := uvs[vt]
+						p.T = &uv
+					}
+					cur.ob.P = append(cur.ob.P, p)
+					cur.points[key] = n
+				}
+				pts = append(pts, n)
+			}
+			switch fields[0] {
+			case "f":
+				cur.ob.S = append(cur.ob.S, Surface(pts))
+				if colour != cur.ob.C {
+					for len(cur.ob.SC) < len(cur.ob.S)-1 {
+						cur.ob.SC = append(cur.ob.SC, "")
+					}
+					cur.ob.SC = append(cur.ob.SC, colour)
+				}
+			case "l":
+				for i := 0; i+1 < len(pts); i++ {
+					if pts[i] != pts[i+1] {
+						cur.lines = append(cur.lines, Edge{pts[i], pts[i+1]})
+					}
+				}
+			}
+			// Points (p) only need adding to the object, which has already been done
+
+		case "o", "g":
+			if len(fields) > 1 {
+				name = strings.Join(fields[1:], " ")
+			}
+			cur = nil
+
+		case "usemtl":
+			newName := strings.Join(fields[1:], " ")
+			if newName == mtlName {
+				continue
+			}
+			mtlName = newName
+			colour = defaultColour
+			texture = ""
+			if m, ok := materials[mtlName]; ok {
+				colour, texture = m.colour, m.texture
+			}
+			if cur != nil && len(cur.ob.P) > 0 && texture != cur.ob.T {
+				cur = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("obj: no faces, lines, or points found")
+	}
+
+	// Work out the edges and mid point for each object
+	objects := make(map[string]Object, len(groups))
+	for _, g := range groups {
+		g.ob = dropDegenerateSurfaces(g.ob)
+		g.ob.E = mergeEdges(surfaceEdges(g.ob.S), g.lines)
+		g.ob.Mid = midPoint(g.ob.P)
+		if err := g.ob.Validate(); err != nil {
+			return nil, errors.New("obj: object '" + g.name + "': " + err.Error())
+		}
+		objects[g.name] = g.ob
+	}
+	return objects, nil
+}
+
+// Parses a Wavefront MTL material library, returning a map of material names to their diffuse (Kd) colour and texture
+// image (map_Kd).  Any options given for the texture are ignored
+func parseMTL(r io.Reader) (map[string]objMaterial, error) {
+	materials := make(map[string]objMaterial)
+	var name string
+	lineNum := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "newmtl":
+			name = strings.Join(fields[1:], " ")
+			materials[name] = objMaterial{colour: defaultColour}
+		case "Kd":
+			if len(fields) < 4 {
+				return nil, errors.New("mtl: line " + strconv.Itoa(lineNum) + ": Kd needs red, green, and blue values")
+			}
+			var rgb [3]float64
+			for i := range rgb {
+				v, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, errors.New("mtl: line " + strconv.Itoa(lineNum) + ": bad Kd value")
+				}
+				rgb[i] = v
+			}
+			m := materials[name]
+			m.colour = cssColour(rgb[0], rgb[1], rgb[2])
+			materials[name] = m
+		case "map_Kd":
+			if len(fields) < 2 {
+				return nil, errors.New("mtl: line " + strconv.Itoa(lineNum) + ": map_Kd needs an image file name")
+			}
+			m := materials[name]
+			m.texture = fields[len(fields)-1]
+			materials[name] = m
+		}
+	}
+	return materials, scanner.Err()
+}
+
+// Writes a Wavefront MTL material library to go with writeOBJ(), with one material for each object and surface
+// colour.  Textured objects have their own materials, with the texture image file as the map_Kd
+func writeMTL(w io.Writer, world map[string]Object) error {
+	bw := bufio.NewWriter(w)
+	seen := make(map[string]bool)
+	for _, name := range sortedNames(world) {
+		o := world[name]
+		texture := objTextureFile(o.T)
+		objectColour := o.C
+		if objectColour == "" {
+			objectColour = defaultColour
+		}
+		for _, colour := range append([]string{objectColour}, o.SC...) {
+			mtlName := objMaterialName(colour, texture)
+			if colour == "" || seen[mtlName] {
+				continue
+			}
+			seen[mtlName] = true
+			c, ok := parseCSSColour(colour)
+			if !ok {
+				c, _ = parseCSSColour(defaultColour)
+			}
+			bw.WriteString("newmtl " + mtlName + "\n")
+			bw.WriteString("Kd " + objColour(c) + "\n")
+			if texture != "" {
+				bw.WriteString("map_Kd " + texture + "\n")
+			}
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// Writes the objects out as a Wavefront OBJ file, with an o group for each object.  The points are written where they
+// currently are, so any transforms which have been applied are kept.  Surfaces become faces, edges which aren't on
+// the boundary of a surface become lines, and objects with only points have them written as points.  Point colours use
+// the "v x y z r g b" extension, and point UVs are written as texture vertices.  If mtlLib isn't empty, it's
+// referenced as the material library holding the object and surface colours, as written by writeMTL()
+func writeOBJ(w io.Writer, world map[string]Object, mtlLib string) error {
+	bw := bufio.NewWriter(w)
+	if mtlLib != "" {
+		bw.WriteString("mtllib " + mtlLib + "\n")
+	}
+	base, uvBase := 1, 1 // OBJ vertex and texture vertex numbers are file wide, and start at 1
+	for _, name := range sortedNames(world) {
+		o := world[name]
+		bw.WriteString("\no " + name + "\n")
+		texture := objTextureFile(o.T)
+		objectColour := o.C
+		if objectColour == "" {
+			objectColour = defaultColour
+		}
+		material := objMaterialName(objectColour, texture)
+		if mtlLib != "" {
+			bw.WriteString("usemtl " + material + "\n")
+		}
+		uvNums := make(map[int]int)
+		for n, p := range o.P {
+			bw.WriteString("v " + objNum(p.X) + " " + objNum(p.Y) + " " + objNum(p.Z))
+			if p.C != nil {
+				bw.WriteString(" " + objColour(*p.C))
+			}
+			bw.WriteString("\n")
+			if p.T != nil {
+				uvNums[n] = uvBase + len(uvNums)
+			}
+		}
+		for _, p := range o.P {
+			if p.T != nil {
+				bw.WriteString("vt " + objNum(p.T.U) + " " + objNum(p.T.V) + "\n")
+			}
+		}
+		uvBase += len(uvNums)
+		for i, s := range o.S {
+			if mtlLib != "" {
+				m := objMaterialName(objectColour, texture)
+				if c := o.surfaceColour(i); c != "" {
+					m = objMaterialName(c, texture)
+				}
+				if m != material {
+					material = m
+					bw.WriteString("usemtl " + material + "\n")
+				}
+			}
+			bw.WriteString("f")
+			withUVs := hasUVs(o.P, s)
+			for _, n := range s {
+				bw.WriteString(" " + strconv.Itoa(base+n))
+				if withUVs {
+					bw.WriteString("/" + strconv.Itoa(uvNums[n]))
+				}
+			}
+			bw.WriteString("\n")
+		}
+		onSurface := make(map[[2]int]bool)
+		for _, e := range surfaceEdges(o.S) {
+			onSurface[edgeKey(e[0], e[1])] = true
+		}
+		for _, e := range o.E {
+			if len(e) >= 2 && !onSurface[edgeKey(e[0], e[1])] {
+				bw.WriteString("l " + strconv.Itoa(base+e[0]) + " " + strconv.Itoa(base+e[1]) + "\n")
+			}
+		}
+		if len(o.S) == 0 && len(o.E) == 0 && len(o.P) > 0 {
+			bw.WriteString("p")
+			for n := range o.P {
+				bw.WriteString(" " + strconv.Itoa(base+n))
+			}
+			bw.WriteString("\n")
+		}
+		base += len(o.P)
+	}
+	return bw.Flush()
+}
+
+// Returns an error for the given line of an OBJ file
+func objError(lineNum int, msg string) error {
+	return errors.New("obj: line " + strconv.Itoa(lineNum) + ": " + msg)
+}
+
+// Returns the zero based index given by a vertex or texture vertex number of an OBJ file, when there are count of them
+// so far.  The numbers start at 1, with negative numbers counting backwards from the most recent one
+func objIndex(num string, count int) (int, error) {
+	i, err := strconv.Atoi(num)
+	if err != nil {
+		return 0, errors.New("isn't a number")
+	}
+	if i < 0 {
+		i += count
+	} else {
+		i--
+	}
+	if i < 0 || i >= count {
+		return 0, errors.New("is out of range")
+	}
+	return i, nil
+}
+
+// Removes any trailing comment from a line
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// Formats a colour for an OBJ or MTL file, as red, green, and blue values from 0 to 1
+func objColour(c RGB) string {
+	f := func(v uint8) string {
+		return strconv.FormatFloat(float64(v)/255, 'f', 4, 64)
+	}
+	return f(c.R) + " " + f(c.G) + " " + f(c.B)
+}
+
+// Returns the material name used for an object colour and texture image file, which can't contain spaces
+func objMaterialName(colour string, texture string) string {
+	if texture != "" {
+		colour += " " + path.Base(texture)
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(colour) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	if name := strings.TrimSuffix(b.String(), "_"); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// Returns the file name of a texture for an MTL file, or "" if it's embedded in a data URI instead
+func objTextureFile(texture string) string {
+	if strings.HasPrefix(texture, "data:") {
+		return ""
+	}
+	return texture
+}
+
+// Formats a number for an OBJ or MTL file
+func objNum(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
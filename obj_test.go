@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// A 2 x 2 x 2 cube around the origin, with its faces going anticlockwise seen from outside
+const testCubeOBJ = `# cube
+v -1 -1 -1
+v 1 -1 -1
+v 1 1 -1
+v -1 1 -1
+v -1 -1 1
+v 1 -1 1
+v 1 1 1
+v -1 1 1
+f 1 4 3 2
+f 5 6 7 8
+f 1 2 6 5
+f 4 8 7 3
+f 1 5 8 4
+f 2 3 7 6
+`
+
+func TestParseOBJ(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string   // Part of the error message, or "" for no error
+		objects []string // Names of the objects found
+		solid   bool     // Whether the objects should be closed solids
+		volume  float64  // Total volume of the solids
+	}{
+		{"cube", testCubeOBJ, "", []string{"test"}, true, 8},
+		{"cube with normals", strings.Replace(testCubeOBJ, "f 1 4 3 2", "f 1//1 4//1 3//1 2//1", 1), "", []string{"test"}, true, 8},
+		{"cube with uvs", "vt 0 0\nvt 1 0\nvt 1 1\n" + strings.Replace(testCubeOBJ, "f 1 4 3 2", "f 1/1 4/2 3/3 2/2", 1),
+			"", []string{"test"}, false, 0},
+		{"tetrahedron with negative numbers", "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 0 0 1\nf -4 -2 -3\nf -4 -3 -1\nf -4 -1 -2\nf -3 -2 -1\n",
+			"", []string{"test"}, true, 1.0 / 6},
+		{"two objects", "o a\n" + testCubeOBJ + "o b\nv 0 0 5\nv 1 0 5\nv 0 1 5\nf 9 10 11\n", "", []string{"a", "b"}, false, 0},
+		{"lines only", "v 0 0 0\nv 1 0 0\nv 1 1 0\nl 1 2 3\n", "", []string{"test"}, false, 0},
+		{"points only", "v 0 0 0\nv 1 0 0\np 1 2\n", "", []string{"test"}, false, 0},
+		{"degenerate face dropped", "v 0 0 0\nv 1 0 0\nv 2 0 0\nv 0 1 0\nf 1 2 4\nf 1 2 3\n", "", []string{"test"}, false, 0},
+		{"comments and blank lines", "# nothing\n\n" + strings.Replace(testCubeOBJ, "\n", " # here\n", -1), "", []string{"test"}, true, 8},
+
+		{"empty", "", "no faces, lines, or points", nil, false, 0},
+		{"vertices only", "v 0 0 0\nv 1 0 0\n", "no faces, lines, or points", nil, false, 0},
+		{"truncated vertex", "v 0 0 0\nv 1 0", "line 2: vertex needs", nil, false, 0},
+		{"truncated face", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2", "line 4: 'f' needs at least 3", nil, false, 0},
+		{"face before vertices", "f 1 2 3\nv 0 0 0\nv 1 0 0\nv 0 1 0\n", "line 1: vertex number '1' is out of range", nil, false, 0},
+		{"uvs after the face", strings.Replace(testCubeOBJ, "f 1 4 3 2", "f 1/1 4/2 3/3 2/2", 1) + "vt 0 0\nvt 1 0\nvt 1 1\n",
+			"texture vertex number '1/1' is out of range", nil, false, 0},
+		{"bad face number", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 x\n", "vertex number 'x' isn't a number", nil, false, 0},
+		{"bad vertex", "v 0 0 x\n", "bad vertex value", nil, false, 0},
+		{"bad colour", "v 0 0 0 1 x 1\n", "bad vertex colour", nil, false, 0},
+		{"short line", "v 0 0 0\nl 1\n", "'l' needs at least 2", nil, false, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			obs, err := parseOBJ(strings.NewReader(tc.src), "test", nil)
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			names := sortedNames(obs)
+			if strings.Join(names, ",") != strings.Join(tc.objects, ",") {
+				t.Fatalf("objects are %v, wanted %v", names, tc.objects)
+			}
+			volume := 0.0
+			for _, name := range names {
+				if err := obs[name].Validate(); err != nil {
+					t.Errorf("object %s is invalid: %v", name, err)
+				}
+				if tc.solid {
+					volume += checkSolid(t, obs[name])
+				}
+			}
+			if !near(volume, tc.volume, 1e-9) {
+				t.Errorf("volume is %v, wanted %v", volume, tc.volume)
+			}
+		})
+	}
+}
+
+func TestParseOBJMaterials(t *testing.T) {
+	materials, err := parseMTL(strings.NewReader("newmtl red\nKd 1 0 0\nnewmtl tiles\nKd 1 1 1\nmap_Kd -s 2 2 tiles.png\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvt 0 0\nvt 1 0\nvt 1 1\n" +
+		"usemtl red\nf 1 2 3\nusemtl missing\nf 1 3 4\nusemtl tiles\nf 1/1 2/2 3/3\n"
+	obs, err := parseOBJ(strings.NewReader(src), "test", materials)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first material is the object's colour, the second a surface colour, and the texture starts a new object
+	plain, textured := obs["test"], obs["test (tiles)"]
+	if plain.C != "rgb(255, 0, 0)" || len(plain.S) != 2 || strings.Join(plain.SC, ",") != ","+defaultColour {
+		t.Errorf("untextured object has colour %q, %d surfaces, and surface colours %q", plain.C, len(plain.S), plain.SC)
+	}
+	if textured.T != "tiles.png" || len(textured.S) != 1 || !hasUVs(textured.P, textured.S[0]) {
+		t.Errorf("textured object has texture %q and surfaces %v", textured.T, textured.S)
+	}
+
+	for _, tc := range []struct{ src, want string }{
+		{"newmtl a\nKd 1 0\n", "line 2: Kd needs"},
+		{"newmtl a\nKd 1 0 x\n", "bad Kd value"},
+		{"newmtl a\nmap_Kd\n", "map_Kd needs"},
+	} {
+		_, err := parseMTL(strings.NewReader(tc.src))
+		checkError(t, err, tc.want)
+	}
+}
+
+// Checks objects written by writeOBJ read back the same
+func TestWriteOBJ(t *testing.T) {
+	obs, err := parseOBJ(strings.NewReader(testCubeOBJ), "cube", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeOBJ(&buf, obs, ""); err != nil {
+		t.Fatal(err)
+	}
+	again, err := parseOBJ(&buf, "cube", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 {
+		t.Fatalf("read back %d objects, wanted 1", len(again))
+	}
+	for _, o := range again {
+		if v := checkSolid(t, o); !near(v, 8, 1e-9) {
+			t.Errorf("volume is %v, wanted 8", v)
+		}
+	}
+}
+
+// Checks every material used by writeOBJ is written by writeMTL, including for objects without a colour
+func TestWriteOBJMaterials(t *testing.T) {
+	plain := validSquare()
+	cube := testCube(t)
+	cube.C = "red"
+	cube.SC = []string{"", "blue"}
+	obs := map[string]Object{"plain": plain, "cube": cube}
+
+	var mtl, obj bytes.Buffer
+	if err := writeMTL(&mtl, obs); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOBJ(&obj, obs, "test.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	defined := make(map[string]bool)
+	for _, line := range strings.Split(mtl.String(), "\n") {
+		if strings.HasPrefix(line, "newmtl ") {
+			defined[strings.TrimPrefix(line, "newmtl ")] = true
+		}
+	}
+	for _, line := range strings.Split(obj.String(), "\n") {
+		if name := strings.TrimPrefix(line, "usemtl "); name != line && !defined[name] {
+			t.Errorf("usemtl %s has no material in the library", name)
+		}
+	}
+
+	materials, err := parseMTL(&mtl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := parseOBJ(&obj, "test", materials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := again["plain"].C; c != "rgb(211, 211, 211)" {
+		t.Errorf("plain square is %q, wanted the default colour", c)
+	}
+	if c := again["cube"]; c.C != "rgb(255, 0, 0)" || strings.Join(c.SC, ",") != ",rgb(0, 0, 255)" {
+		t.Errorf("cube is %q with surface colours %q", c.C, c.SC)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// The colour given to imported objects which don't specify one
+const defaultColour = "lightgray"
+
+type Point struct {
+	Num int     `json:"-"`
+	X   float64 `json:"x"`
+	Y   float64 `json:"y"`
+	Z   float64 `json:"z"`
+	C   *RGB    `json:"colour,omitempty"` // Colour of the point.  Optional, the object colour is used when not set
+	T   *UV     `json:"uv,omitempty"`     // Where the point is on the object's texture image.  Optional
+}
+
+// A position on a texture image, with U going from 0 at the left to 1 at the right, and V from 0 at the bottom to 1 at
+// the top.  Positions outside of 0 to 1 repeat the image
+type UV struct {
+	U float64 `json:"u"`
+	V float64 `json:"v"`
+}
+
+// A colour with red, green, and blue values from 0 to 255
+type RGB struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+type Edge []int
+type Surface []int
+
+type Object struct {
+	C   string     `json:"colour"` // Colour of the object
+	P   []Point    `json:"points"`
+	E   []Edge     `json:"edges,omitempty"`          // List of points to connect by edges
+	S   []Surface  `json:"surfaces,omitempty"`       // List of points to connect in order, to create a surface
+	SC  []string   `json:"surfaceColours,omitempty"` // Colours of the surfaces, in the same order.  Optional, with "" or a missing colour using the point or object colours
+	A   float64    `json:"opacity,omitempty"`        // How solid the object looks, from 0 to 1.  Not setting it (or 0) means fully solid
+	T   string     `json:"texture,omitempty"`        // URL or file name of an image drawn on the surfaces whose points all have UVs
+	M   RenderMode `json:"renderMode,omitempty"`     // How the object is drawn.  The default uses the mode of the whole world
+	Mid Point      `json:"-"`                        // The mid point of the object.  Used for calculating object draw order in a very simple way
+}
+
+// Used to give each point a unique number
+var pointCounter = 1
+
+// Returns an object whose points have been transformed into 3D world space XYZ co-ordinates.  Also assigns a number
+// to each point
+func importObject(ob Object, x float64, y float64, z float64) (translatedObject Object) {
+	// X and Y translation matrix.  Translates the objects into the world space at the given X and Y co-ordinates
+	translateMatrix := matrix{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+
+	// Translate the points
+	var midX, midY, midZ float64
+	var pt Point
+	for _, j := range ob.P {
+		pt = Point{
+			Num: pointCounter,
+			X:   (translateMatrix[0] * j.X) + (translateMatrix[1] * j.Y) + (translateMatrix[2] * j.Z) + (translateMatrix[3] * 1),   // 1st col, top
+			Y:   (translateMatrix[4] * j.X) + (translateMatrix[5] * j.Y) + (translateMatrix[6] * j.Z) + (translateMatrix[7] * 1),   // 1st col, upper middle
+			Z:   (translateMatrix[8] * j.X) + (translateMatrix[9] * j.Y) + (translateMatrix[10] * j.Z) + (translateMatrix[11] * 1), // 1st col, lower middle
+			C:   j.C,
+			T:   j.T,
+		}
+		translatedObject.P = append(translatedObject.P, pt)
+		midX += pt.X
+		midY += pt.Y
+		midZ += pt.Z
+		pointCounter++
+	}
+
+	// Determine the mid point for the object
+	numPts := float64(len(ob.P))
+	translatedObject.Mid.X = midX / numPts
+	translatedObject.Mid.Y = midY / numPts
+	translatedObject.Mid.Z = midZ / numPts
+
+	// Copy the colour, edge, and surface definitions across, along with how the object is drawn
+	translatedObject.C = ob.C
+	translatedObject.SC = ob.SC
+	translatedObject.A = ob.A
+	translatedObject.T = ob.T
+	translatedObject.M = ob.M
+	for _, j := range ob.E {
+		translatedObject.E = append(translatedObject.E, j)
+	}
+	for _, j := range ob.S {
+		translatedObject.S = append(translatedObject.S, j)
+	}
+
+	return translatedObject
+}
+
+// Returns the CSS colour string for the colour
+func (c RGB) String() string {
+	return "rgb(" + strconv.Itoa(int(c.R)) + ", " + strconv.Itoa(int(c.G)) + ", " + strconv.Itoa(int(c.B)) + ")"
+}
+
+// Checks the object can be drawn safely, returning an error describing the first problem found.  Objects need at
+// least one point, and every point needs a position.  Edges need two points and surfaces at least three, all of which
+// have to exist and be in different places
+func (o Object) Validate() error {
+	if len(o.P) == 0 {
+		return errors.New("object has no points")
+	}
+	for i, p := range o.P {
+		if math.IsNaN(p.X+p.Y+p.Z) || math.IsInf(p.X+p.Y+p.Z, 0) {
+			return errors.New("point " + strconv.Itoa(i) + " has no valid position")
+		}
+		if p.T != nil && (math.IsNaN(p.T.U+p.T.V) || math.IsInf(p.T.U+p.T.V, 0)) {
+			return errors.New("point " + strconv.Itoa(i) + " has no valid texture position")
+		}
+	}
+
+	// Checks the points used by an edge or surface exist and are in different places
+	checkPoints := func(kind string, n int, pts []int) error {
+		name := kind + " " + strconv.Itoa(n)
+		for i, a := range pts {
+			if a < 0 || a >= len(o.P) {
+				return errors.New(name + " uses point " + strconv.Itoa(a) + ", but the object only has points 0 to " + strconv.Itoa(len(o.P)-1))
+			}
+			for _, b := range pts[:i] {
+				if a == b {
+					return errors.New(name + " uses point " + strconv.Itoa(a) + " more than once")
+				}
+				if o.P[a].X == o.P[b].X && o.P[a].Y == o.P[b].Y && o.P[a].Z == o.P[b].Z {
+					return errors.New(name + " uses points " + strconv.Itoa(b) + " and " + strconv.Itoa(a) + ", which are in the same place")
+				}
+			}
+		}
+		return nil
+	}
+	for i, e := range o.E {
+		if len(e) != 2 {
+			return errors.New("edge " + strconv.Itoa(i) + " needs 2 points, but has " + strconv.Itoa(len(e)))
+		}
+		if err := checkPoints("edge", i, e); err != nil {
+			return err
+		}
+	}
+	for i, s := range o.S {
+		if len(s) < 3 {
+			return errors.New("surface " + strconv.Itoa(i) + " needs at least 3 points, but has " + strconv.Itoa(len(s)))
+		}
+		if err := checkPoints("surface", i, s); err != nil {
+			return err
+		}
+	}
+	if len(o.SC) > len(o.S) {
+		return errors.New("object has " + strconv.Itoa(len(o.SC)) + " surface colours, but only " + strconv.Itoa(len(o.S)) + " surfaces")
+	}
+	for i, c := range o.SC {
+		if _, ok := parseCSSColour(c); c != "" && !ok {
+			return errors.New("surface " + strconv.Itoa(i) + " has an unknown colour '" + c + "'")
+		}
+	}
+	if math.IsNaN(o.A) || o.A < 0 || o.A > 1 {
+		return errors.New("opacity needs to be from 0 to 1")
+	}
+	return nil
+}
+
+// Returns how solid the object looks, from 0 (invisible) to 1 (fully solid)
+func (o Object) opacity() float64 {
+	if o.A == 0 {
+		return 1
+	}
+	return o.A
+}
+
+// Returns the surface colours with the empty ones at the end left out, or nil if none of the surfaces have a colour
+func trimColours(colours []string) []string {
+	n := len(colours)
+	for n > 0 && colours[n-1] == "" {
+		n--
+	}
+	if n == 0 {
+		return nil
+	}
+	return colours[:n]
+}
+
+// Returns the colour given to a surface, or "" if it doesn't have one
+func (o Object) surfaceColour(i int) string {
+	if i < len(o.SC) {
+		return o.SC[i]
+	}
+	return ""
+}
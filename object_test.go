@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A unit square in the X-Y plane, used as the starting point for the Validate tests
+func validSquare() Object {
+	return Object{
+		P: []Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}},
+		E: []Edge{{0, 1}, {1, 2}, {2, 3}, {3, 0}},
+		S: []Surface{{0, 1, 2, 3}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(o *Object)
+		want   string // Part of the error message, or "" for no error
+	}{
+		{"valid", func(o *Object) {}, ""},
+		{"no points", func(o *Object) { *o = Object{} }, "no points"},
+		{"nan point", func(o *Object) { o.P[1].X = math.NaN() }, "point 1 has no valid position"},
+		{"infinite point", func(o *Object) { o.P[2].Z = math.Inf(1) }, "point 2 has no valid position"},
+		{"nan uv", func(o *Object) { o.P[0].T = &UV{U: math.NaN()} }, "no valid texture position"},
+		{"edge out of range", func(o *Object) { o.E[0] = Edge{0, 7} }, "edge 0 uses point 7"},
+		{"negative edge", func(o *Object) { o.E[1] = Edge{-1, 2} }, "edge 1 uses point -1"},
+		{"short edge", func(o *Object) { o.E[2] = Edge{2} }, "edge 2 needs 2 points"},
+		{"long edge", func(o *Object) { o.E[2] = Edge{1, 2, 3} }, "edge 2 needs 2 points"},
+		{"looped edge", func(o *Object) { o.E[3] = Edge{3, 3} }, "more than once"},
+		{"short surface", func(o *Object) { o.S[0] = Surface{0, 1} }, "at least 3 points"},
+		{"surface out of range", func(o *Object) { o.S[0] = Surface{0, 1, 4} }, "surface 0 uses point 4"},
+		{"repeated surface point", func(o *Object) { o.S[0] = Surface{0, 1, 2, 1} }, "more than once"},
+		{"duplicate points", func(o *Object) { o.P[3] = o.P[0] }, "in the same place"},
+		{"too many colours", func(o *Object) { o.SC = []string{"red", "blue"} }, "2 surface colours"},
+		{"unknown colour", func(o *Object) { o.SC = []string{"nope"} }, "unknown colour"},
+		{"opacity too high", func(o *Object) { o.A = 1.5 }, "opacity"},
+		{"negative opacity", func(o *Object) { o.A = -0.5 }, "opacity"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := validSquare()
+			tc.change(&o)
+			checkError(t, o.Validate(), tc.want)
+		})
+	}
+}
+
+// Checks every import path returns an error, rather than panicking or producing an object which would panic when
+// drawn
+func TestImportMalformed(t *testing.T) {
+	obj := func(src string) func() error {
+		return func() error {
+			_, err := parseOBJ(strings.NewReader(src), "test", nil)
+			return err
+		}
+	}
+	stl := func(src []byte) func() error {
+		return func() error {
+			_, err := parseSTL(src, 0)
+			return err
+		}
+	}
+	ply := func(src string) func() error {
+		return func() error {
+			_, err := parsePLY([]byte(src))
+			return err
+		}
+	}
+	gltf := func(accessor, view string) func() error {
+		return func() error {
+			_, err := parseGLTF([]byte(testGLTF(accessor, view)), "test", map[string][]byte{"tri.bin": testTriangleBuffer()})
+			return err
+		}
+	}
+	const (
+		goodAccessor = `{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}`
+		goodView     = `{"buffer": 0, "byteLength": 36}`
+	)
+	gltfEdit := func(accessors, old, new string) func() error {
+		return func() error {
+			doc := strings.Replace(testGLTF(accessors, goodView), old, new, 1)
+			_, err := parseGLTF([]byte(doc), "test", map[string][]byte{"tri.bin": testTriangleBuffer()})
+			return err
+		}
+	}
+	withColours := func(colours string) func() error {
+		return gltfEdit(goodAccessor+", "+colours, `{"POSITION": 0}`, `{"POSITION": 0, "COLOR_0": 1}`)
+	}
+
+	// A binary STL header claiming more triangles than the file holds
+	shortSTL := make([]byte, 84+50)
+	binary.LittleEndian.PutUint32(shortSTL[80:], 2)
+	hugeSTL := make([]byte, 84)
+	binary.LittleEndian.PutUint32(hugeSTL[80:], math.MaxUint32)
+
+	// A glb file whose only chunk claims to be almost 4GB long
+	glb := make([]byte, 20)
+	copy(glb, "glTF")
+	binary.LittleEndian.PutUint32(glb[4:], 2)
+	binary.LittleEndian.PutUint32(glb[8:], 20)
+	binary.LittleEndian.PutUint32(glb[12:], math.MaxUint32-4)
+	copy(glb[16:], "JSON")
+
+	tests := []struct {
+		name string
+		load func() error
+		want string
+	}{
+		{"obj empty", obj(""), "no faces"},
+		{"obj short vertex", obj("v 1 2\n"), "line 1: vertex needs"},
+		{"obj bad vertex", obj("v 1 x 3\n"), "bad vertex value"},
+		{"obj face out of range", obj("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n"), "line 4: vertex number '4'"},
+		{"obj face zero", obj("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n"), "vertex number '0'"},
+		{"obj short face", obj("v 0 0 0\nv 1 0 0\nf 1 2\n"), "needs at least 3"},
+		{"obj nan vertex", obj("v nan 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), "no valid position"},
+		{"obj bad texture vertex", obj("v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0 0\nf 1/1 2/2 3/1\n"), "texture vertex number '2/2'"},
+
+		{"stl no triangles", stl([]byte("solid t\nendsolid t\n")), "no triangles"},
+		{"stl empty", stl(nil), "too short"},
+		{"stl truncated facet", stl([]byte("solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\n")), "3 vertices"},
+		{"stl bad vertex", stl([]byte("solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 zero 0\n")), "line 4: bad vertex value"},
+		{"stl nan vertex", stl([]byte("solid t\nouter loop\nvertex nan 0 0\nvertex 1 0 0\nvertex 0 1 0\nendloop\n")), "no valid position"},
+		{"stl short binary", stl(make([]byte, 40)), "too short"},
+		{"stl truncated binary", stl(shortSTL), "truncated"},
+		{"stl huge triangle count", stl(hugeSTL), "truncated"},
+
+		{"ply not ply", ply("solid\n"), "not a PLY file"},
+		{"ply no end", ply("ply\nformat ascii 1.0\nelement vertex 3\n"), "end_header"},
+		{"ply truncated", ply(testPLY("3", "0 0 0\n1 0 0\n", "")), "ply:"},
+		{"ply face out of range", ply(testPLY("3", "0 0 0\n1 0 0\n0 1 0\n", "3 0 1 5\n")), "vertex 5 is out of range"},
+		{"ply negative face index", ply(testPLY("3", "0 0 0\n1 0 0\n0 1 0\n", "3 0 1 -1\n")), "vertex -1 is out of range"},
+		{"ply negative count", ply(testPLY("-3", "", "")), "ply:"},
+
+		{"gltf not json", func() error { _, err := parseGLTF([]byte("{"), "test", nil); return err }, "gltf:"},
+		{"gltf huge glb chunk", func() error { _, err := parseGLTF(glb, "test", nil); return err }, "truncated"},
+		{"gltf negative count", gltf(`{"bufferView": 0, "componentType": 5126, "count": -1, "type": "VEC3"}`, goodView), "negative count"},
+		{"gltf huge count", gltf(`{"bufferView": 0, "componentType": 5126, "count": 1099511627776, "type": "VEC3"}`, goodView), "past the end"},
+		{"gltf negative offset", gltf(`{"bufferView": 0, "byteOffset": -12, "componentType": 5126, "count": 3, "type": "VEC3"}`, goodView), "negative count or offset"},
+		{"gltf offset past end", gltf(`{"bufferView": 0, "byteOffset": 12, "componentType": 5126, "count": 3, "type": "VEC3"}`, goodView), "past the end"},
+		{"gltf negative stride", gltf(goodAccessor, `{"buffer": 0, "byteLength": 36, "byteStride": -12}`), "negative"},
+		{"gltf negative view offset", gltf(goodAccessor, `{"buffer": 0, "byteOffset": -12, "byteLength": 36}`), "negative"},
+		{"gltf negative view length", gltf(goodAccessor, `{"buffer": 0, "byteLength": -1}`), "negative"},
+		{"gltf view past buffer", gltf(goodAccessor, `{"buffer": 0, "byteOffset": 24, "byteLength": 36}`), "past the end of its buffer"},
+		{"gltf huge view", gltf(goodAccessor, `{"buffer": 0, "byteOffset": 24, "byteLength": 9223372036854775800}`), "past the end of its buffer"},
+		{"gltf missing view", gltf(`{"bufferView": 3, "componentType": 5126, "count": 3, "type": "VEC3"}`, goodView), "buffer view 3"},
+		{"gltf missing buffer", gltf(goodAccessor, `{"buffer": 2, "byteLength": 36}`), "buffer 2"},
+		{"gltf negative scene", gltfEdit(goodAccessor, `"scenes"`, `"scene": -1, "scenes"`), "scene -1 doesn't exist"},
+		{"gltf missing scene", gltfEdit(goodAccessor, `"scenes"`, `"scene": 1, "scenes"`), "scene 1 doesn't exist"},
+		{"gltf positions without view", gltf(`{"componentType": 5126, "count": 4000000000000, "type": "VEC3"}`, goodView), "no buffer view"},
+		{"gltf indices without view", gltfEdit(goodAccessor+`, {"componentType": 5125, "count": 3, "type": "SCALAR"}`,
+			`"primitives": [{`, `"primitives": [{"indices": 1, `), "no buffer view"},
+		{"gltf colours without view", withColours(`{"componentType": 5126, "count": 3, "type": "VEC3"}`), ""},
+		{"gltf too many colours without view", withColours(`{"componentType": 5126, "count": 4000000000000, "type": "VEC3"}`), "no buffer view"},
+		{"gltf bad type", gltf(`{"bufferView": 0, "componentType": 1, "count": 3, "type": "VEC3"}`, goodView), "unsupported type"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checkError(t, tc.load(), tc.want)
+		})
+	}
+
+	// Check the glTF test file works when it isn't broken
+	if err := gltf(goodAccessor, goodView)(); err != nil {
+		t.Errorf("valid glTF failed to load: %v", err)
+	}
+}
+
+// Fails the test if the error doesn't contain want, or if there is an error when want is ""
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("no error, wanted one containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error %q doesn't contain %q", err, want)
+	}
+}
+
+// Returns a glTF document holding a single triangle, with the given JSON for its position accessor and buffer view.
+// The buffer is the external file tri.bin
+func testGLTF(accessor, view string) string {
+	return `{
+		"scenes": [{"nodes": [0]}],
+		"nodes": [{"mesh": 0}],
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0}}]}],
+		"accessors": [` + accessor + `],
+		"bufferViews": [` + view + `],
+		"buffers": [{"uri": "tri.bin", "byteLength": 36}]
+	}`
+}
+
+// Returns the three corners of a triangle as little endian float32 values
+func testTriangleBuffer() []byte {
+	buf := make([]byte, 36)
+	for i, v := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0} {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// Returns an ASCII PLY file with the given vertex count and the vertex and face lines.  The face count is the number
+// of face lines
+func testPLY(numVerts string, verts string, faces string) string {
+	numFaces := strings.Count(faces, "\n")
+	return "ply\nformat ascii 1.0\nelement vertex " + numVerts + "\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face " + strconv.Itoa(numFaces) + "\nproperty list uchar int vertex_indices\nend_header\n" + verts + faces
+}
+
+// Checks an object is valid and a closed solid, with every side of every surface shared with exactly one other
+// surface going the opposite way round it.  Returns the object's volume, which is negative if its surfaces face inwards
+func checkSolid(t *testing.T, o Object) float64 {
+	t.Helper()
+	if err := o.Validate(); err != nil {
+		t.Fatalf("invalid object: %v", err)
+	}
+	sides := make(map[[2]int]int)
+	for _, s := range o.S {
+		for i := range s {
+			sides[[2]int{s[i], s[(i+1)%len(s)]}]++
+		}
+	}
+	for side, n := range sides {
+		if n != 1 || sides[[2]int{side[1], side[0]}] != 1 {
+			t.Errorf("side %v isn't matched by one going the other way, so the object isn't closed or its surfaces "+
+				"don't face the same way", side)
+			break
+		}
+	}
+	return meshVolume(o)
+}
+
+// Returns the volume enclosed by an object's surfaces, from the tetrahedra joining each surface triangle to the origin
+func meshVolume(o Object) (v float64) {
+	for _, s := range o.S {
+		for i := 1; i+1 < len(s); i++ {
+			a, b, c := o.P[s[0]], o.P[s[i]], o.P[s[i+1]]
+			v += (a.X*(b.Y*c.Z-b.Z*c.Y) + a.Y*(b.Z*c.X-b.X*c.Z) + a.Z*(b.X*c.Y-b.Y*c.X)) / 6
+		}
+	}
+	return v
+}
+
+// Reports whether two numbers are within tolerance of each other
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
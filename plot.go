@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Plot settings used when a plot doesn't give its own
+const (
+	plotDefaultRange      = 5   // Surfaces go from -plotDefaultRange to plotDefaultRange along x and y
+	plotDefaultSteps      = 30  // Grid squares along each side of a surface
+	plotDefaultCurveSteps = 200 // Lines along a curve
+	plotMaxSteps          = 200 // Stops surfaces being too slow to draw
+	plotMaxCurveSteps     = 5000
+)
+
+// A function plot, sampled to make an object.  Plots are z = f(x, y) surfaces, parametric curves, or parametric
+// surfaces.  Functions using t as time can be animated by sampling them again as t changes
+type plot struct {
+	spec     ScenePlot
+	fs       []exprFunc // The height function of z = f(x, y) surfaces, or the x, y, and z functions of the others
+	animated bool       // Whether the functions use t as time
+	place    matrix     // Transform placing the plot in the world
+}
+
+// Compiles the functions of a plot, filling in any settings it doesn't give
+func newPlot(spec ScenePlot) (*plot, error) {
+	var vars []string
+	var err error
+	p := plot{place: identityMatrix}
+	switch spec.Type {
+	case "", "surface":
+		if spec.Function == "" {
+			return nil, errors.New("plot: surfaces need a function")
+		}
+		if spec.X, err = plotRange("x", spec.X, -plotDefaultRange, plotDefaultRange); err != nil {
+			return nil, err
+		}
+		if spec.Y, err = plotRange("y", spec.Y, -plotDefaultRange, plotDefaultRange); err != nil {
+			return nil, err
+		}
+		vars = []string{"x", "y", "t"}
+	case "curve":
+		if spec.T, err = plotRange("t", spec.T, 0, 2*math.Pi); err != nil {
+			return nil, err
+		}
+		vars = []string{"t"}
+	case "parametric":
+		if spec.U, err = plotRange("u", spec.U, 0, 2*math.Pi); err != nil {
+			return nil, err
+		}
+		if spec.V, err = plotRange("v", spec.V, 0, 2*math.Pi); err != nil {
+			return nil, err
+		}
+		vars = []string{"u", "v", "t"}
+	default:
+		return nil, errors.New("plot: unknown type '" + spec.Type + "'")
+	}
+
+	// Compile the functions
+	functions := spec.Functions
+	if spec.Type == "" || spec.Type == "surface" {
+		functions = []string{spec.Function}
+	} else if len(functions) != 3 {
+		return nil, errors.New("plot: " + spec.Type + "s need x, y, and z functions")
+	}
+	for _, fn := range functions {
+		f, used, err := parseExpr(fn, vars...)
+		if err != nil {
+			return nil, err
+		}
+		p.fs = append(p.fs, f)
+		if spec.Type != "curve" && used[len(used)-1] {
+			p.animated = true
+		}
+	}
+
+	// Check the number of samples
+	maxSteps := plotMaxSteps
+	if spec.Steps == 0 {
+		spec.Steps = plotDefaultSteps
+		if spec.Type == "curve" {
+			spec.Steps = plotDefaultCurveSteps
+		}
+	}
+	if spec.Type == "curve" {
+		maxSteps = plotMaxCurveSteps
+	}
+	if spec.Type == "parametric" && spec.VSteps == 0 {
+		spec.VSteps = spec.Steps
+	}
+	if spec.Steps < 1 || spec.Steps > maxSteps || spec.VSteps < 0 || spec.VSteps > plotMaxSteps {
+		return nil, errors.New("plot: steps need to be from 1 to " + strconv.Itoa(maxSteps))
+	}
+	p.spec = spec
+	return &p, nil
+}
+
+// Returns the colour for a height, going from blue for the lowest (0) through cyan, green, and yellow to red for the
+// highest (1)
+func heightColour(h float64) *RGB {
+	h = math.Max(0, math.Min(1, h)) * 4
+	band := math.Min(math.Floor(h), 3)
+	f := h - band
+	switch band {
+	case 0:
+		return &RGB{R: 0, G: uint8(math.Round(255 * f)), B: 255}
+	case 1:
+		return &RGB{R: 0, G: 255, B: uint8(math.Round(255 * (1 - f)))}
+	case 2:
+		return &RGB{R: uint8(math.Round(255 * f)), G: 255, B: 0}
+	}
+	return &RGB{R: 255, G: uint8(math.Round(255 * (1 - f))), B: 0}
+}
+
+// Returns the plot for functions typed in by the user.  A single function is the height of a z = f(x, y) surface,
+// while three comma separated functions are the x, y, and z of a curve along t, or of a parametric surface if they
+// use u or v
+func plotSpec(fn string) (ScenePlot, error) {
+	parts := splitExprList(fn)
+	switch len(parts) {
+	case 1:
+		return ScenePlot{Function: parts[0]}, nil
+	case 3:
+		for _, part := range parts {
+			if _, _, err := parseExpr(part, "t"); err != nil {
+				return ScenePlot{Type: "parametric", Functions: parts}, nil
+			}
+		}
+		return ScenePlot{Type: "curve", Functions: parts}, nil
+	}
+	return ScenePlot{}, errors.New("plot: give either one function, or three separated by commas")
+}
+
+// Returns the range of a plot variable, or the default range if none is given
+func plotRange(name string, r []float64, low float64, high float64) ([]float64, error) {
+	if len(r) == 0 {
+		return []float64{low, high}, nil
+	}
+	if len(r) != 2 || r[0] >= r[1] {
+		return nil, errors.New("plot: the " + name + " range needs to go from a lower to a higher value")
+	}
+	return r, nil
+}
+
+// Splits a list of expressions on the commas which aren't inside brackets
+func splitExprList(s string) (parts []string) {
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// Returns the plot as an object at time t, before it's placed in the world.  Points where the functions have no value
+// (eg the square root of a negative number) are left out, along with the surfaces and edges using them
+func (p *plot) object(t float64) (ob Object) {
+	lerp := func(r []float64, i int, steps int) float64 {
+		return r[0] + (r[1]-r[0])*float64(i)/float64(steps)
+	}
+	switch p.spec.Type {
+	case "curve":
+		// The points are joined by edges, and coloured along the curve
+		vars := []float64{0}
+		last := -1
+		for i := 0; i <= p.spec.Steps; i++ {
+			vars[0] = lerp(p.spec.T, i, p.spec.Steps)
+			pt, ok := p.point(vars)
+			if !ok {
+				last = -1
+				continue
+			}
+			pt.C = heightColour(float64(i) / float64(p.spec.Steps))
+			if last >= 0 {
+				ob.E = append(ob.E, Edge{last, len(ob.P)})
+			}
+			last = len(ob.P)
+			ob.P = append(ob.P, pt)
+		}
+		ob.C = defaultColour
+		ob.Mid = midPoint(ob.P)
+		return ob
+
+	case "parametric":
+		vars := []float64{0, 0, t}
+		ob = plotGrid(p.spec.Steps, p.spec.VSteps, true, func(i, j int) (Point, bool) {
+			vars[0] = lerp(p.spec.U, i, p.spec.Steps)
+			vars[1] = lerp(p.spec.V, j, p.spec.VSteps)
+			return p.point(vars)
+		})
+
+	default:
+		// The height is shown up the world's Y axis
+		vars := []float64{0, 0, t}
+		ob = plotGrid(p.spec.Steps, p.spec.Steps, false, func(i, j int) (Point, bool) {
+			vars[0] = lerp(p.spec.X, i, p.spec.Steps)
+			vars[1] = lerp(p.spec.Y, j, p.spec.Steps)
+			h := p.fs[0](vars)
+			return Point{X: vars[0], Y: h, Z: -vars[1]}, !math.IsNaN(h) && !math.IsInf(h, 0)
+		})
+	}
+
+	// Colour the points of surfaces by height
+	minH, maxH := math.Inf(1), math.Inf(-1)
+	for _, pt := range ob.P {
+		minH, maxH = math.Min(minH, pt.Y), math.Max(maxH, pt.Y)
+	}
+	for i := range ob.P {
+		h := 0.5
+		if maxH > minH {
+			h = (ob.P[i].Y - minH) / (maxH - minH)
+		}
+		ob.P[i].C = heightColour(h)
+	}
+	return ob
+}
+
+// Returns the point given by the x, y, and z functions of curves and parametric surfaces, and whether they all have
+// a value there
+func (p *plot) point(vars []float64) (Point, bool) {
+	var v [3]float64
+	for i, f := range p.fs {
+		v[i] = f(vars)
+		if math.IsNaN(v[i]) || math.IsInf(v[i], 0) {
+			return Point{}, false
+		}
+	}
+	return Point{X: v[0], Y: v[1], Z: v[2]}, true
+}
+
+// Returns an object made by sampling a grid of points, joining each square of the grid which has all of its corners.
+// If weld is true, points in the same place are joined together, so surfaces which wrap around (eg a torus) don't
+// have seams
+func plotGrid(cols int, rows int, weld bool, at func(i, j int) (Point, bool)) (ob Object) {
+	index := make([]int, (cols+1)*(rows+1)) // Index of each grid point in the object, or -1 if it has no value
+	welded := make(map[[3]float64]int)
+	for j := 0; j <= rows; j++ {
+		for i := 0; i <= cols; i++ {
+			pt, ok := at(i, j)
+			n := j*(cols+1) + i
+			if !ok {
+				index[n] = -1
+				continue
+			}
+			if weld {
+				key := [3]float64{math.Round(pt.X * 1e9), math.Round(pt.Y * 1e9), math.Round(pt.Z * 1e9)}
+				if k, ok := welded[key]; ok {
+					index[n] = k
+					continue
+				}
+				welded[key] = len(ob.P)
+			}
+			index[n] = len(ob.P)
+			ob.P = append(ob.P, pt)
+		}
+	}
+
+	// Welded surfaces can lose corners, eg at the poles of a sphere, so they're kept if three or more are left
+	row := cols + 1
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			n := j*row + i
+			var s Surface
+			for _, k := range []int{index[n], index[n+1], index[n+row+1], index[n+row]} {
+				if k < 0 {
+					s = nil
+					break
+				}
+				if len(s) == 0 || (k != s[len(s)-1] && k != s[0]) {
+					s = append(s, k)
+				}
+			}
+			if len(s) >= 3 {
+				ob.S = append(ob.S, s)
+			}
+		}
+	}
+	ob.C = defaultColour
+	ob.E = surfaceEdges(ob.S)
+	ob.Mid = midPoint(ob.P)
+	return ob
+}
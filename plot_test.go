@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPlotSpec(t *testing.T) {
+	tests := []struct {
+		fn        string
+		kind      string
+		functions int
+		want      string // Part of the error message, or "" for no error
+	}{
+		{"sin(x) * cos(y)", "", 0, ""},
+		{"x ^ 2 + t", "", 0, ""},
+		{"cos(t), sin(t), t / 5", "curve", 3, ""},
+		{"1, 2, 3", "curve", 3, ""},
+		{"cos(u) * sin(v), cos(v), sin(u) * sin(v)", "parametric", 3, ""},
+		{"u, v, 0", "parametric", 3, ""},
+		{"abs(t), (t), sin((t)), ", "", 0, "give either one function, or three"},
+		{"x, y", "", 0, "give either one function, or three"},
+		{"a, b, c, d", "", 0, "give either one function, or three"},
+	}
+	for _, tc := range tests {
+		spec, err := plotSpec(tc.fn)
+		checkError(t, err, tc.want)
+		if spec.Type != tc.kind || len(spec.Functions) != tc.functions {
+			t.Errorf("%q is a %q plot with functions %q", tc.fn, spec.Type, spec.Functions)
+		}
+	}
+
+	// Commas inside brackets don't split the functions
+	if parts := splitExprList("f(a, b), (c, d) ,e"); strings.Join(parts, "|") != "f(a, b)|(c, d)|e" {
+		t.Errorf("split into %q", parts)
+	}
+}
+
+func TestNewPlot(t *testing.T) {
+	curve := []string{"cos(t)", "sin(t)", "t"}
+	sphere := []string{"cos(u) * sin(v)", "cos(v)", "sin(u) * sin(v)"}
+	tests := []struct {
+		name     string
+		spec     ScenePlot
+		want     string // Part of the error message, or "" for no error
+		steps    int    // Steps after filling in the defaults
+		animated bool
+	}{
+		{"surface", ScenePlot{Function: "x * y"}, "", plotDefaultSteps, false},
+		{"animated surface", ScenePlot{Type: "surface", Function: "sin(x + t)", Steps: 10}, "", 10, true},
+		{"curve", ScenePlot{Type: "curve", Functions: curve}, "", plotDefaultCurveSteps, false},
+		{"parametric", ScenePlot{Type: "parametric", Functions: sphere, Steps: 8}, "", 8, false},
+		{"animated parametric", ScenePlot{Type: "parametric", Functions: []string{"u", "v", "t"}}, "", plotDefaultSteps, true},
+		{"most surface steps", ScenePlot{Function: "x", Steps: plotMaxSteps}, "", plotMaxSteps, false},
+		{"most curve steps", ScenePlot{Type: "curve", Functions: curve, Steps: plotMaxCurveSteps}, "", plotMaxCurveSteps, false},
+
+		{"unknown type", ScenePlot{Type: "volume", Function: "x"}, "unknown type 'volume'", 0, false},
+		{"no function", ScenePlot{}, "surfaces need a function", 0, false},
+		{"two curve functions", ScenePlot{Type: "curve", Functions: curve[:2]}, "curves need x, y, and z functions", 0, false},
+		{"bad function", ScenePlot{Function: "x +"}, "expression: unexpected end", 0, false},
+		{"curve using x", ScenePlot{Type: "curve", Functions: []string{"x", "t", "t"}}, "unknown name 'x'", 0, false},
+		{"backwards range", ScenePlot{Function: "x", X: []float64{1, -1}}, "the x range needs", 0, false},
+		{"empty range", ScenePlot{Type: "parametric", Functions: sphere, V: []float64{1, 1}}, "the v range needs", 0, false},
+		{"one value range", ScenePlot{Type: "curve", Functions: curve, T: []float64{1}}, "the t range needs", 0, false},
+		{"negative steps", ScenePlot{Function: "x", Steps: -1}, "steps need to be from 1 to 200", 0, false},
+		{"too many surface steps", ScenePlot{Function: "x", Steps: plotMaxSteps + 1}, "steps need to be from 1 to 200", 0, false},
+		{"too many curve steps", ScenePlot{Type: "curve", Functions: curve, Steps: plotMaxCurveSteps + 1}, "from 1 to 5000", 0, false},
+		{"too many v steps", ScenePlot{Type: "parametric", Functions: sphere, VSteps: plotMaxSteps + 1}, "steps need", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := newPlot(tc.spec)
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			if p.spec.Steps != tc.steps || p.animated != tc.animated {
+				t.Errorf("plot has %d steps and animated %v, wanted %d and %v", p.spec.Steps, p.animated, tc.steps,
+					tc.animated)
+			}
+			if err := p.object(0).Validate(); err != nil {
+				t.Errorf("plot object is invalid: %v", err)
+			}
+		})
+	}
+}
+
+// Checks the objects made by plots, including the seams of surfaces which wrap around being welded
+func TestPlotObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     ScenePlot
+		points   int
+		surfaces int
+		edges    int
+		solid    bool // Whether the plot should be a closed solid
+	}{
+		{"surface", ScenePlot{Function: "x * y", Steps: 4}, 25, 16, 40, false},
+		{"surface with a hole", ScenePlot{Function: "sqrt(x)", Steps: 10}, 66, 50, 115, false},
+		{"curve", ScenePlot{Type: "curve", Functions: []string{"cos(t)", "sin(t)", "t"}, Steps: 10}, 11, 0, 10, false},
+		{"curve with a gap", ScenePlot{Type: "curve", Functions: []string{"t", "sqrt(abs(t - 5) - 1)", "0"}, T: []float64{0, 10}, Steps: 10}, 10, 0, 8, false},
+		{"sphere", ScenePlot{Type: "parametric", Functions: []string{"cos(u) * sin(v)", "cos(v)", "sin(u) * sin(v)"}, V: []float64{0, math.Pi}, Steps: 8, VSteps: 4}, 26, 32, 56, true},
+		{"torus", ScenePlot{Type: "parametric", Functions: []string{"(2 + cos(v)) * cos(u)", "sin(v)", "(2 + cos(v)) * sin(u)"}, Steps: 12, VSteps: 6}, 72, 72, 144, true},
+		{"open tube", ScenePlot{Type: "parametric", Functions: []string{"cos(u)", "v", "sin(u)"}, V: []float64{0, 1}, Steps: 6, VSteps: 2}, 18, 12, 30, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := newPlot(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := p.object(0)
+			if err := o.Validate(); err != nil {
+				t.Fatalf("plot object is invalid: %v", err)
+			}
+			if len(o.P) != tc.points || len(o.S) != tc.surfaces || len(o.E) != tc.edges {
+				t.Errorf("%d points, %d surfaces, and %d edges, wanted %d, %d, and %d", len(o.P), len(o.S), len(o.E),
+					tc.points, tc.surfaces, tc.edges)
+			}
+			if tc.solid && checkSolid(t, o) == 0 {
+				t.Errorf("closed plot has no volume")
+			}
+			for _, pt := range o.P {
+				if pt.C == nil {
+					t.Fatalf("point %v has no colour", pt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A property of a PLY element.  List properties have a count type as well as the type of their items
+type plyProperty struct {
+	name      string
+	typ       string
+	countType string
+}
+
+// An element (eg vertex or face) declared in a PLY header
+type plyElement struct {
+	name  string
+	count int
+	props []plyProperty
+}
+
+// Reads values from the body of a PLY file, in whichever format the file uses
+type plyReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder // The byte order of binary files.  Nil for ASCII files
+	words *bufio.Scanner   // Splits the body of ASCII files into values
+	ascii bool
+}
+
+// Parses an ASCII or binary (little or big endian) PLY file into an object.  Files with faces become surfaces, with
+// the edges derived from them, while files with only vertices become point clouds.  Per vertex colours are kept as
+// the colour of each point
+func parsePLY(data []byte) (Object, error) {
+	// Read the header
+	if !bytes.HasPrefix(data, []byte("ply")) {
+		return Object{}, errors.New("ply: not a PLY file")
+	}
+	end := bytes.Index(data, []byte("end_header"))
+	if end < 0 {
+		return Object{}, errors.New("ply: header has no end_header")
+	}
+	body := end + len("end_header")
+	if body < len(data) && data[body] == '\r' {
+		body++
+	}
+	if body < len(data) && data[body] == '\n' {
+		body++
+	}
+	r := plyReader{data: data, pos: body}
+	var elements []plyElement
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return Object{}, errors.New("ply: format line has no format")
+			}
+			switch fields[1] {
+			case "ascii":
+				r.ascii = true
+				r.words = bufio.NewScanner(bytes.NewReader(data[body:]))
+				r.words.Split(bufio.ScanWords)
+			case "binary_little_endian":
+				r.order = binary.LittleEndian
+			case "binary_big_endian":
+				r.order = binary.BigEndian
+			default:
+				return Object{}, errors.New("ply: unknown format '" + fields[1] + "'")
+			}
+		case "element":
+			if len(fields) < 3 {
+				return Object{}, errors.New("ply: bad element line '" + line + "'")
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return Object{}, errors.New("ply: bad element count '" + fields[2] + "'")
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return Object{}, errors.New("ply: property declared before any element")
+			}
+			var prop plyProperty
+			if len(fields) == 5 && fields[1] == "list" {
+				prop = plyProperty{name: fields[4], typ: fields[3], countType: fields[2]}
+			} else if len(fields) == 3 {
+				prop = plyProperty{name: fields[2], typ: fields[1]}
+			} else {
+				return Object{}, errors.New("ply: bad property line '" + line + "'")
+			}
+			if plyTypeSize(prop.typ) == 0 || (prop.countType != "" && plyTypeSize(prop.countType) == 0) {
+				return Object{}, errors.New("ply: unknown property type in '" + line + "'")
+			}
+			el := &elements[len(elements)-1]
+			el.props = append(el.props, prop)
+		}
+	}
+	if !r.ascii && r.order == nil {
+		return Object{}, errors.New("ply: header has no format line")
+	}
+
+	// Read the elements, keeping the vertices and faces
+	var ob Object
+	for _, el := range elements {
+		for i := 0; i < el.count; i++ {
+			var p Point
+			var rgb [3]uint8
+			numColours := 0
+			var s Surface
+			for _, prop := range el.props {
+				if prop.countType != "" {
+					// List property, eg the vertex indices of a face
+					n, err := r.read(prop.countType)
+					if err != nil {
+						return Object{}, err
+					}
+					for j := 0; j < int(n); j++ {
+						v, err := r.read(prop.typ)
+						if err != nil {
+							return Object{}, err
+						}
+						if el.name == "face" && (prop.name == "vertex_indices" || prop.name == "vertex_index") {
+							s = append(s, int(v))
+						}
+					}
+					continue
+				}
+				v, err := r.read(prop.typ)
+				if err != nil {
+					return Object{}, err
+				}
+				if el.name != "vertex" {
+					continue
+				}
+				switch prop.name {
+				case "x":
+					p.X = v
+				case "y":
+					p.Y = v
+				case "z":
+					p.Z = v
+				case "red", "diffuse_red":
+					rgb[0] = plyColourValue(prop.typ, v)
+					numColours++
+				case "green", "diffuse_green":
+					rgb[1] = plyColourValue(prop.typ, v)
+					numColours++
+				case "blue", "diffuse_blue":
+					rgb[2] = plyColourValue(prop.typ, v)
+					numColours++
+				}
+			}
+			switch el.name {
+			case "vertex":
+				if numColours == 3 {
+					p.C = &RGB{R: rgb[0], G: rgb[1], B: rgb[2]}
+				}
+				ob.P = append(ob.P, p)
+			case "face":
+				if len(s) >= 3 {
+					ob.S = append(ob.S, s)
+				}
+			}
+		}
+	}
+	if len(ob.P) == 0 {
+		return Object{}, errors.New("ply: no vertices found")
+	}
+	for _, s := range ob.S {
+		for _, n := range s {
+			if n < 0 || n >= len(ob.P) {
+				return Object{}, errors.New("ply: face vertex " + strconv.Itoa(n) + " is out of range")
+			}
+		}
+	}
+
+	ob.C = defaultColour
+	ob.S = dropDegenerate(ob.P, ob.S)
+	ob.E = surfaceEdges(ob.S)
+	ob.Mid = midPoint(ob.P)
+	if err := ob.Validate(); err != nil {
+		return Object{}, errors.New("ply: " + err.Error())
+	}
+	return ob, nil
+}
+
+// Returns a colour value in the 0 - 255 range.  Integer colour properties already use that range, while floating point
+// ones range from 0 to 1
+func plyColourValue(typ string, v float64) uint8 {
+	switch typ {
+	case "float", "float32", "double", "float64":
+		v *= 255
+	}
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// Returns the size in bytes of a PLY property type, or 0 if the type isn't known
+func plyTypeSize(typ string) int {
+	switch typ {
+	case "char", "int8", "uchar", "uint8":
+		return 1
+	case "short", "int16", "ushort", "uint16":
+		return 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+// Reads the next value of the given type from the body of the PLY file
+func (r *plyReader) read(typ string) (float64, error) {
+	if r.ascii {
+		if !r.words.Scan() {
+			return 0, errors.New("ply: unexpected end of file")
+		}
+		v, err := strconv.ParseFloat(r.words.Text(), 64)
+		if err != nil {
+			return 0, errors.New("ply: bad value '" + r.words.Text() + "'")
+		}
+		return v, nil
+	}
+
+	size := plyTypeSize(typ)
+	if r.pos+size > len(r.data) {
+		return 0, errors.New("ply: unexpected end of file")
+	}
+	b := r.data[r.pos : r.pos+size]
+	r.pos += size
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(r.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(r.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(r.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(r.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(r.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(r.order.Uint64(b)), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParsePLY(t *testing.T) {
+	cube := testCube(t)
+	ascii := testASCIIPLY(cube)
+	little := testBinaryPLY(cube, binary.LittleEndian)
+	big := testBinaryPLY(cube, binary.BigEndian)
+	header := func(body string) string {
+		return "ply\nformat ascii 1.0\n" + body + "end_header\n"
+	}
+	points := header("element vertex 3\nproperty float x\nproperty float y\nproperty float z\n"+
+		"property uchar red\nproperty uchar green\nproperty uchar blue\n") + "0 0 0 255 0 0\n1 0 0 0 255 0\n0 1 0 0 0 255\n"
+
+	tests := []struct {
+		name  string
+		data  string
+		want  string // Part of the error message, or "" for no error
+		solid bool   // Whether the result should be the test cube
+	}{
+		{"ascii", ascii, "", true},
+		{"ascii with crlf", strings.Replace(ascii, "\n", "\r\n", -1), "", true},
+		{"binary little endian", string(little), "", true},
+		{"binary big endian", string(big), "", true},
+		{"point cloud with colours", points, "", false},
+		{"comments and other elements", strings.Replace(ascii, "end_header\n", "comment made for a test\n"+
+			"element material 1\nproperty float shine\nend_header\n", 1) + "0.5\n", "", true},
+
+		{"empty", "", "not a PLY file", false},
+		{"no end", "ply\nformat ascii 1.0\nelement vertex 1\n", "no end_header", false},
+		{"no format", "ply\nelement vertex 1\nproperty float x\nend_header\n0\n", "no format line", false},
+		{"unknown format", "ply\nformat binary_middle_endian 1.0\nend_header\n", "unknown format", false},
+		{"bad element", header("element vertex\n"), "bad element line", false},
+		{"bad count", header("element vertex x\n"), "bad element count", false},
+		{"negative count", header("element vertex -3\n"), "bad element count", false},
+		{"property first", header("property float x\n"), "before any element", false},
+		{"bad property", header("element vertex 1\nproperty float\n"), "bad property line", false},
+		{"unknown type", header("element vertex 1\nproperty quad x\n"), "unknown property type", false},
+		{"no vertices", header("element vertex 0\nproperty float x\n"), "no vertices", false},
+		{"ascii truncated", ascii[:len(ascii)-10], "ply:", false},
+		{"ascii bad number", strings.Replace(ascii, "end_header\n-1", "end_header\nx", 1), "ply:", false},
+		{"binary truncated", string(little[:len(little)-3]), "ply:", false},
+		{"face out of range", header("element vertex 3\nproperty float x\nproperty float y\nproperty float z\n"+
+			"element face 1\nproperty list uchar int vertex_indices\n") + "0 0 0\n1 0 0\n0 1 0\n3 0 1 3\n", "vertex 3 is out of range", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := parsePLY([]byte(tc.data))
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if !tc.solid {
+				return
+			}
+			if len(o.P) != 8 || len(o.S) != 6 {
+				t.Errorf("cube has %d points and %d surfaces, wanted 8 and 6", len(o.P), len(o.S))
+			}
+			if v := checkSolid(t, o); !near(v, 8, 1e-9) {
+				t.Errorf("volume is %v, wanted 8", v)
+			}
+		})
+	}
+
+	// Point colours are kept
+	o, err := parsePLY([]byte(points))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.P[1].C == nil || *o.P[1].C != (RGB{G: 255}) {
+		t.Errorf("second point has colour %v, wanted green", o.P[1].C)
+	}
+}
+
+// Returns the test cube as an object
+func testCube(t *testing.T) Object {
+	t.Helper()
+	obs, err := parseOBJ(strings.NewReader(testCubeOBJ), "cube", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obs["cube"]
+}
+
+func testASCIIPLY(o Object) string {
+	var b strings.Builder
+	b.WriteString(testPLYHeader("ascii", o))
+	for _, p := range o.P {
+		for i, v := range []float64{p.X, p.Y, p.Z} {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		b.WriteString("\n")
+	}
+	for _, s := range o.S {
+		b.WriteString(strconv.Itoa(len(s)))
+		for _, n := range s {
+			b.WriteString(" " + strconv.Itoa(n))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func testBinaryPLY(o Object, order binary.ByteOrder) []byte {
+	format := "binary_little_endian"
+	if order == binary.BigEndian {
+		format = "binary_big_endian"
+	}
+	var b bytes.Buffer
+	b.WriteString(testPLYHeader(format, o))
+	buf := make([]byte, 4)
+	for _, p := range o.P {
+		for _, v := range []float64{p.X, p.Y, p.Z} {
+			order.PutUint32(buf, math.Float32bits(float32(v)))
+			b.Write(buf)
+		}
+	}
+	for _, s := range o.S {
+		b.WriteByte(byte(len(s)))
+		for _, n := range s {
+			order.PutUint32(buf, uint32(n))
+			b.Write(buf)
+		}
+	}
+	return b.Bytes()
+}
+
+func testPLYHeader(format string, o Object) string {
+	return "ply\nformat " + format + " 1.0\nelement vertex " + strconv.Itoa(len(o.P)) + "\n" +
+		"property float x\nproperty float y\nproperty float z\n" +
+		"element face " + strconv.Itoa(len(o.S)) + "\nproperty list uchar int vertex_indices\nend_header\n"
+}
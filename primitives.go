@@ -0,0 +1,179 @@
+package main
+
+import "math"
+
+// The fewest segments around round shapes, and the fewest rings from top to bottom of spheres.  Generators given
+// less than these use them instead
+const (
+	minSegments = 3
+	minRings    = 2
+)
+
+// Returns a cube of the given width, centered on the origin
+func cube(size float64) Object {
+	h := size / 2
+	return primitiveObject([]Point{
+		{X: -h, Y: -h, Z: -h},
+		{X: h, Y: -h, Z: -h},
+		{X: h, Y: h, Z: -h},
+		{X: -h, Y: h, Z: -h},
+		{X: -h, Y: -h, Z: h},
+		{X: h, Y: -h, Z: h},
+		{X: h, Y: h, Z: h},
+		{X: -h, Y: h, Z: h},
+	}, []Surface{
+		{4, 5, 6, 7}, // Front
+		{1, 0, 3, 2}, // Back
+		{5, 1, 2, 6}, // Right
+		{0, 4, 7, 3}, // Left
+		{7, 6, 2, 3}, // Top
+		{0, 1, 5, 4}, // Bottom
+	})
+}
+
+// Returns a cone standing on the X/Z plane around the Y axis, centered on the origin.  The base is a single surface
+func cone(radius float64, height float64, segments int) Object {
+	if segments < minSegments {
+		segments = minSegments
+	}
+	pts := primitiveRing(radius, -height/2, segments)
+	apex := len(pts)
+	pts = append(pts, Point{Y: height / 2})
+	var surfaces []Surface
+	for i := 0; i < segments; i++ {
+		surfaces = append(surfaces, Surface{i, (i + 1) % segments, apex})
+	}
+	return primitiveObject(pts, append(surfaces, reversedRing(0, segments)))
+}
+
+// Returns a cylinder standing on the X/Z plane around the Y axis, centered on the origin.  The ends are single
+// surfaces
+func cylinder(radius float64, height float64, segments int) Object {
+	if segments < minSegments {
+		segments = minSegments
+	}
+	pts := append(primitiveRing(radius, -height/2, segments), primitiveRing(radius, height/2, segments)...)
+	surfaces := primitiveBand(0, segments, segments)
+	top := make(Surface, segments)
+	for i := range top {
+		top[i] = segments + i
+	}
+	return primitiveObject(pts, append(surfaces, top, reversedRing(0, segments)))
+}
+
+// Returns a flat square of the given width on the X/Z plane, centered on the origin and facing up the Y axis.  It's
+// split into a grid of divisions squares along each side
+func plane(size float64, divisions int) Object {
+	if divisions < 1 {
+		divisions = 1
+	}
+	h := size / 2
+	step := size / float64(divisions)
+	var pts []Point
+	for k := 0; k <= divisions; k++ {
+		for i := 0; i <= divisions; i++ {
+			pts = append(pts, Point{X: -h + float64(i)*step, Z: -h + float64(k)*step})
+		}
+	}
+	var surfaces []Surface
+	row := divisions + 1
+	for k := 0; k < divisions; k++ {
+		for i := 0; i < divisions; i++ {
+			p := k*row + i
+			surfaces = append(surfaces, Surface{p + row, p + row + 1, p + 1, p})
+		}
+	}
+	return primitiveObject(pts, surfaces)
+}
+
+// Returns a sphere centered on the origin, made of segments slices around the Y axis and rings slices from top to
+// bottom.  The top and bottom rings are triangles meeting at the poles, while the others are four sided
+func sphere(radius float64, segments int, rings int) Object {
+	if segments < minSegments {
+		segments = minSegments
+	}
+	if rings < minRings {
+		rings = minRings
+	}
+	pts := []Point{{Y: radius}}
+	for j := 1; j < rings; j++ {
+		a := math.Pi * float64(j) / float64(rings)
+		pts = append(pts, primitiveRing(radius*math.Sin(a), radius*math.Cos(a), segments)...)
+	}
+	bottom := len(pts)
+	pts = append(pts, Point{Y: -radius})
+
+	// The rings are numbered from the top, so each band has the lower ring first
+	var surfaces []Surface
+	for i := 0; i < segments; i++ {
+		surfaces = append(surfaces, Surface{1 + i, 1 + (i+1)%segments, 0})
+	}
+	for j := 1; j < rings-1; j++ {
+		lower := 1 + j*segments
+		for i := 0; i < segments; i++ {
+			upper := lower - segments
+			surfaces = append(surfaces, Surface{lower + i, lower + (i+1)%segments, upper + (i+1)%segments, upper + i})
+		}
+	}
+	last := bottom - segments
+	for i := 0; i < segments; i++ {
+		surfaces = append(surfaces, Surface{bottom, last + (i+1)%segments, last + i})
+	}
+	return primitiveObject(pts, surfaces)
+}
+
+// Returns a torus (ring doughnut) lying on the X/Z plane around the Y axis, centered on the origin.  Radius is the
+// distance from the center to the middle of the tube, and tube is the radius of the tube.  It's made of segments
+// slices around the Y axis and rings slices around the tube
+func torus(radius float64, tube float64, segments int, rings int) Object {
+	if segments < minSegments {
+		segments = minSegments
+	}
+	if rings < minSegments {
+		rings = minSegments
+	}
+	var pts []Point
+	for j := 0; j < rings; j++ {
+		a := 2 * math.Pi * float64(j) / float64(rings)
+		pts = append(pts, primitiveRing(radius+tube*math.Cos(a), tube*math.Sin(a), segments)...)
+	}
+	var surfaces []Surface
+	for j := 0; j < rings; j++ {
+		surfaces = append(surfaces, primitiveBand(j*segments, ((j+1)%rings)*segments, segments)...)
+	}
+	return primitiveObject(pts, surfaces)
+}
+
+// Returns the four sided surfaces joining a lower ring of points to an upper one, facing outwards from the Y axis
+func primitiveBand(lower int, upper int, segments int) (surfaces []Surface) {
+	for i := 0; i < segments; i++ {
+		next := (i + 1) % segments
+		surfaces = append(surfaces, Surface{lower + i, lower + next, upper + next, upper + i})
+	}
+	return
+}
+
+// Returns an object made of the given points and surfaces, with edges around each surface
+func primitiveObject(pts []Point, surfaces []Surface) Object {
+	return Object{C: defaultColour, P: pts, E: surfaceEdges(surfaces), S: surfaces, Mid: midPoint(pts)}
+}
+
+// Returns a circle of points around the Y axis, at the given height.  The points go anticlockwise when looking down
+// from above, so surfaces using them in order face up
+func primitiveRing(radius float64, y float64, segments int) []Point {
+	pts := make([]Point, segments)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / float64(segments)
+		pts[i] = Point{X: radius * math.Cos(a), Y: y, Z: -radius * math.Sin(a)}
+	}
+	return pts
+}
+
+// Returns a surface using a ring of points in reverse order, so it faces down
+func reversedRing(first int, segments int) Surface {
+	s := make(Surface, segments)
+	for i := range s {
+		s[i] = first + segments - 1 - i
+	}
+	return s
+}
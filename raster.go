@@ -0,0 +1,548 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The number of rows each pixel is split into when working out how much of it a shape covers, for anti-aliasing
+const rasterSubRows = 4
+
+// The size of the built in font's glyphs, in pixels.  The baseline is rasterAscent rows from the top
+const (
+	rasterGlyphWidth   = 6
+	rasterGlyphHeight  = 13
+	rasterGlyphAdvance = 7
+	rasterAscent       = 11
+)
+
+// A Renderer drawing into an image, without needing a web browser.  Shapes are anti-aliased, and text uses a small
+// built in bitmap font scaled to roughly the font size asked for
+type imageRenderer struct {
+	img      *image.RGBA
+	path     [][]screenPoint // Sub paths, in image pixels
+	state    rasterState
+	saved    []rasterState
+	textures map[string]image.Image // Texture images which can be drawn, by name
+}
+
+// The drawing state kept by Save() and Restore()
+type rasterState struct {
+	fill      color.RGBA
+	gradient  *rasterGradient // Used instead of the fill colour when set
+	texture   *rasterTexture  // Used instead of the fill colour when set
+	stroke    color.RGBA
+	alpha     float64 // Opacity everything is drawn with
+	lineWidth float64
+	fontSize  float64
+	bold      bool
+	scale     screenPoint
+	clip      *image.Alpha // How much of each pixel can be drawn on.  Nil when there's no clip region
+}
+
+// A linear gradient fill, in image pixels
+type rasterGradient struct {
+	from     screenPoint
+	along    screenPoint // From the start of the gradient to its end
+	fromFill color.RGBA
+	toFill   color.RGBA
+}
+
+// A texture image fill, along with the transform from image pixels back to the image being 1 across
+type rasterTexture struct {
+	img     image.Image
+	inverse [6]float64
+}
+
+// A side of a polygon being filled, going from the top to the bottom
+type rasterEdge struct {
+	x0, y0, x1, y1 float64
+	winding        int // 1 if the side went down the image, -1 if it went up
+}
+
+// Returns a renderer drawing into the image, with the given texture images available to it
+func newImageRenderer(img *image.RGBA, textures map[string]image.Image) *imageRenderer {
+	return &imageRenderer{
+		img:      img,
+		textures: textures,
+		state: rasterState{
+			fill:      color.RGBA{A: 255},
+			stroke:    color.RGBA{A: 255},
+			alpha:     1,
+			lineWidth: 1,
+			fontSize:  10,
+			scale:     screenPoint{1, 1},
+		},
+	}
+}
+
+func (r *imageRenderer) Arc(x float64, y float64, radius float64, startAngle float64, endAngle float64) {
+	// The arc is drawn as straight lines, short enough not to be seen.  Like a canvas, it's joined to any sub path
+	// already started
+	sweep := endAngle - startAngle
+	steps := int(math.Ceil(math.Abs(sweep) * radius * math.Max(r.state.scale.X, r.state.scale.Y) / 2))
+	if steps < 8 {
+		steps = 8
+	}
+	for i := 0; i <= steps; i++ {
+		a := startAngle + sweep*float64(i)/float64(steps)
+		r.LineTo(x+radius*math.Cos(a), y+radius*math.Sin(a))
+	}
+}
+
+func (r *imageRenderer) BeginPath() {
+	r.path = nil
+}
+
+func (r *imageRenderer) Clip() {
+	mask := image.NewAlpha(r.img.Bounds())
+	rasterize(r.closedPath(), mask.Rect, func(x, y int, alpha float64) {
+		if r.state.clip != nil {
+			alpha *= float64(r.state.clip.AlphaAt(x, y).A) / 255
+		}
+		mask.SetAlpha(x, y, color.Alpha{A: uint8(alpha*255 + 0.5)})
+	})
+	r.state.clip = mask
+}
+
+// The sub path is joined back to its start, and a new one is started there
+func (r *imageRenderer) ClosePath() {
+	if n := len(r.path); n > 0 {
+		start := r.path[n-1][0]
+		r.path[n-1] = append(r.path[n-1], start)
+		r.path = append(r.path, []screenPoint{start})
+	}
+}
+
+func (r *imageRenderer) Fill() {
+	r.paint(r.closedPath(), r.state.fill, r.fillShade())
+}
+
+func (r *imageRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	s := r.state.scale
+	rect := []screenPoint{{x * s.X, y * s.Y}, {(x + w) * s.X, y * s.Y}, {(x + w) * s.X, (y + h) * s.Y}, {x * s.X, (y + h) * s.Y}}
+	r.paint([][]screenPoint{rect}, r.state.fill, r.fillShade())
+}
+
+func (r *imageRenderer) FillText(text string, x float64, y float64) {
+	// The glyphs are scaled by a whole number, so they stay sharp
+	k := math.Max(1, math.Round(r.state.fontSize*r.state.scale.Y/rasterGlyphHeight))
+	px := x * r.state.scale.X
+	top := y*r.state.scale.Y - rasterAscent*k
+	var rects [][]screenPoint
+	for _, c := range text {
+		if c >= ' ' && c <= '~' {
+			glyph := rasterFont[c-' ']
+			for row, bits := range glyph {
+				for col := 0; col < rasterGlyphWidth; col++ {
+					if bits&(0x80>>uint(col)) == 0 {
+						continue
+					}
+					x0, y0 := px+float64(col)*k, top+float64(row)*k
+					x1 := x0 + k
+					if r.state.bold {
+						x1 += k
+					}
+					rects = append(rects, []screenPoint{{x0, y0}, {x1, y0}, {x1, y0 + k}, {x0, y0 + k}})
+				}
+			}
+		}
+		px += rasterGlyphAdvance * k
+	}
+	r.paint(rects, r.state.fill, r.fillShade())
+}
+
+func (r *imageRenderer) LineTo(x float64, y float64) {
+	if len(r.path) == 0 {
+		r.MoveTo(x, y)
+		return
+	}
+	n := len(r.path) - 1
+	r.path[n] = append(r.path[n], screenPoint{x * r.state.scale.X, y * r.state.scale.Y})
+}
+
+func (r *imageRenderer) MoveTo(x float64, y float64) {
+	r.path = append(r.path, []screenPoint{{x * r.state.scale.X, y * r.state.scale.Y}})
+}
+
+func (r *imageRenderer) Restore() {
+	if n := len(r.saved); n > 0 {
+		r.state = r.saved[n-1]
+		r.saved = r.saved[:n-1]
+	}
+}
+
+func (r *imageRenderer) Save() {
+	r.saved = append(r.saved, r.state)
+}
+
+func (r *imageRenderer) Scale(x float64, y float64) {
+	r.state.scale.X *= x
+	r.state.scale.Y *= y
+}
+
+func (r *imageRenderer) SetFillGradient(x0 float64, y0 float64, x1 float64, y1 float64, from string, to string) {
+	s := r.state.scale
+	r.state.texture = nil
+	r.state.gradient = &rasterGradient{
+		from:     screenPoint{x0 * s.X, y0 * s.Y},
+		along:    screenPoint{(x1 - x0) * s.X, (y1 - y0) * s.Y},
+		fromFill: rasterColour(from),
+		toFill:   rasterColour(to),
+	}
+}
+
+func (r *imageRenderer) SetFillImage(name string, transform [6]float64) {
+	img, ok := r.textures[name]
+	if !ok {
+		return
+	}
+
+	// Pixels are mapped back to the image by the inverse of the transform, after scaling
+	s := r.state.scale
+	a, b, c, d := transform[0]*s.X, transform[1]*s.Y, transform[2]*s.X, transform[3]*s.Y
+	e, f := transform[4]*s.X, transform[5]*s.Y
+	det := a*d - b*c
+	if det == 0 {
+		return
+	}
+	r.state.gradient = nil
+	r.state.texture = &rasterTexture{
+		img:     img,
+		inverse: [6]float64{d / det, -b / det, -c / det, a / det, (c*f - d*e) / det, (b*e - a*f) / det},
+	}
+}
+
+func (r *imageRenderer) SetFillStyle(colour string) {
+	r.state.fill = rasterColour(colour)
+	r.state.gradient = nil
+	r.state.texture = nil
+}
+
+// Only the font size in pixels and whether it's bold are used, eg "bold 14px serif"
+func (r *imageRenderer) SetFont(font string) {
+	r.state.bold = false
+	for _, f := range strings.Fields(font) {
+		if f == "bold" {
+			r.state.bold = true
+		}
+		if strings.HasSuffix(f, "px") {
+			if size, err := strconv.ParseFloat(strings.TrimSuffix(f, "px"), 64); err == nil && size > 0 {
+				r.state.fontSize = size
+			}
+		}
+	}
+}
+
+func (r *imageRenderer) SetGlobalAlpha(alpha float64) {
+	if alpha >= 0 && alpha <= 1 {
+		r.state.alpha = alpha
+	}
+}
+
+func (r *imageRenderer) SetLineWidth(w float64) {
+	if w > 0 {
+		r.state.lineWidth = w
+	}
+}
+
+func (r *imageRenderer) SetStrokeStyle(colour string) {
+	r.state.stroke = rasterColour(colour)
+}
+
+// Lines are drawn as a rectangle along each part of the path, with square ends so the corners are filled in
+func (r *imageRenderer) Stroke() {
+	half := r.state.lineWidth * math.Max(r.state.scale.X, r.state.scale.Y) / 2
+	var rects [][]screenPoint
+	for _, sub := range r.path {
+		for i := 0; i+1 < len(sub); i++ {
+			a, b := sub[i], sub[i+1]
+			dx, dy := b.X-a.X, b.Y-a.Y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			// Along and across the line, each half the line width long
+			ax, ay := dx/length*half, dy/length*half
+			cx, cy := -ay, ax
+			rects = append(rects, []screenPoint{
+				{a.X - ax + cx, a.Y - ay + cy},
+				{b.X + ax + cx, b.Y + ay + cy},
+				{b.X + ax - cx, b.Y + ay - cy},
+				{a.X - ax - cx, a.Y - ay - cy},
+			})
+		}
+	}
+	r.paint(rects, r.state.stroke, nil)
+}
+
+// Returns the sub paths of the current path with each one closed, as needed for filling
+func (r *imageRenderer) closedPath() [][]screenPoint {
+	var polys [][]screenPoint
+	for _, sub := range r.path {
+		if len(sub) >= 3 {
+			polys = append(polys, sub)
+		}
+	}
+	return polys
+}
+
+// Returns the colour of the fill at each position, for gradient and image fills.  Returns nil for plain colour fills
+func (r *imageRenderer) fillShade() func(x float64, y float64) color.RGBA {
+	if r.state.gradient != nil {
+		return r.state.gradient.at
+	}
+	if r.state.texture != nil {
+		return r.state.texture.at
+	}
+	return nil
+}
+
+// Fills the polygons with a colour, or the colours from shade if it isn't nil, blending it over what's already there
+func (r *imageRenderer) paint(polys [][]screenPoint, c color.RGBA, shade func(x float64, y float64) color.RGBA) {
+	bounds := r.img.Bounds()
+	rasterize(polys, bounds, func(x, y int, alpha float64) {
+		if r.state.clip != nil {
+			alpha *= float64(r.state.clip.AlphaAt(x, y).A) / 255
+		}
+		if shade != nil {
+			c = shade(float64(x)+0.5, float64(y)+0.5)
+		}
+		alpha *= float64(c.A) / 255 * r.state.alpha
+		if alpha <= 0 {
+			return
+		}
+		i := r.img.PixOffset(x, y)
+		pix := r.img.Pix[i : i+4]
+		for j, v := range []uint8{c.R, c.G, c.B, 255} {
+			pix[j] = uint8(float64(pix[j])*(1-alpha) + float64(v)*alpha + 0.5)
+		}
+	})
+}
+
+// Works out how much of each pixel inside the bounds is covered by the polygons, using the non-zero winding rule, and
+// calls plot for each pixel at least partly covered
+func rasterize(polys [][]screenPoint, bounds image.Rectangle, plot func(x, y int, alpha float64)) {
+	var edges []rasterEdge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, poly := range polys {
+		for i, a := range poly {
+			b := poly[(i+1)%len(poly)]
+			if a.Y == b.Y {
+				continue
+			}
+			e := rasterEdge{x0: a.X, y0: a.Y, x1: b.X, y1: b.Y, winding: 1}
+			if a.Y > b.Y {
+				e = rasterEdge{x0: b.X, y0: b.Y, x1: a.X, y1: a.Y, winding: -1}
+			}
+			edges = append(edges, e)
+			minY = math.Min(minY, e.y0)
+			maxY = math.Max(maxY, e.y1)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	y0 := int(math.Max(math.Floor(minY), float64(bounds.Min.Y)))
+	y1 := int(math.Min(math.Ceil(maxY), float64(bounds.Max.Y)))
+	width := bounds.Dx()
+	cover := make([]float64, width+1)
+	type crossing struct {
+		x       float64
+		winding int
+	}
+	var crossings []crossing
+	for y := y0; y < y1; y++ {
+		for i := range cover {
+			cover[i] = 0
+		}
+		touched := false
+		for sub := 0; sub < rasterSubRows; sub++ {
+			sy := float64(y) + (float64(sub)+0.5)/rasterSubRows
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if sy < e.y0 || sy >= e.y1 {
+					continue
+				}
+				x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+				crossings = append(crossings, crossing{x, e.winding})
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+			// Add the parts of the row inside the polygons to the coverage of each pixel
+			winding := 0
+			for i, c := range crossings {
+				winding += c.winding
+				if winding == 0 || i+1 == len(crossings) {
+					continue
+				}
+				left := math.Max(c.x-float64(bounds.Min.X), 0)
+				right := math.Min(crossings[i+1].x-float64(bounds.Min.X), float64(width))
+				if right <= left {
+					continue
+				}
+				touched = true
+				l, rt := int(left), int(right)
+				if l == rt {
+					cover[l] += right - left
+					continue
+				}
+				cover[l] += float64(l+1) - left
+				for x := l + 1; x < rt; x++ {
+					cover[x]++
+				}
+				cover[rt] += right - float64(rt)
+			}
+		}
+		if !touched {
+			continue
+		}
+		for x := 0; x < width; x++ {
+			if cover[x] > 0 {
+				plot(bounds.Min.X+x, y, math.Min(cover[x]/rasterSubRows, 1))
+			}
+		}
+	}
+}
+
+// Returns the colour of the gradient at a position.  Positions before its start or after its end get the colour there
+func (g *rasterGradient) at(x float64, y float64) color.RGBA {
+	length := g.along.X*g.along.X + g.along.Y*g.along.Y
+	if length == 0 {
+		return g.toFill
+	}
+	t := math.Max(0, math.Min(1, ((x-g.from.X)*g.along.X+(y-g.from.Y)*g.along.Y)/length))
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	f, e := g.fromFill, g.toFill
+	return color.RGBA{R: mix(f.R, e.R), G: mix(f.G, e.G), B: mix(f.B, e.B), A: mix(f.A, e.A)}
+}
+
+// Returns the colour of the texture image at a position, repeating the image outside of it
+func (t *rasterTexture) at(x float64, y float64) color.RGBA {
+	m := t.inverse
+	u := m[0]*x + m[2]*y + m[4]
+	v := m[1]*x + m[3]*y + m[5]
+	b := t.img.Bounds()
+	px := b.Min.X + int((u-math.Floor(u))*float64(b.Dx()))
+	py := b.Min.Y + int((v-math.Floor(v))*float64(b.Dy()))
+	if px >= b.Max.X {
+		px = b.Max.X - 1
+	}
+	if py >= b.Max.Y {
+		py = b.Max.Y - 1
+	}
+	c := color.NRGBAModel.Convert(t.img.At(px, py)).(color.NRGBA)
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: c.A}
+}
+
+// Returns the colour for a CSS colour string, or black if it isn't one
+func rasterColour(colour string) color.RGBA {
+	c, _ := parseCSSColour(colour)
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// The glyphs of the built in font, for the characters from space to tilde.  Each is 13 rows from the top down, with
+// the left 6 bits of each row being its pixels.  They come from the public domain X11 misc-fixed 6x13 font
+var rasterFont = [][rasterGlyphHeight]uint8{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00, 0x10, 0x00, 0x00}, // '!'
+	{0x00, 0x00, 0x28, 0x28, 0x28, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '"'
+	{0x00, 0x00, 0x00, 0x28, 0x28, 0x7c, 0x28, 0x7c, 0x28, 0x28, 0x00, 0x00, 0x00}, // '#'
+	{0x00, 0x00, 0x00, 0x10, 0x3c, 0x50, 0x38, 0x14, 0x78, 0x10, 0x00, 0x00, 0x00}, // '$'
+	{0x00, 0x00, 0x44, 0xa4, 0x48, 0x10, 0x10, 0x20, 0x48, 0x94, 0x88, 0x00, 0x00}, // '%'
+	{0x00, 0x00, 0x00, 0x00, 0x60, 0x90, 0x90, 0x60, 0x94, 0x88, 0x74, 0x00, 0x00}, // '&'
+	{0x00, 0x00, 0x10, 0x10, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '\''
+	{0x00, 0x00, 0x08, 0x10, 0x10, 0x20, 0x20, 0x20, 0x10, 0x10, 0x08, 0x00, 0x00}, // '('
+	{0x00, 0x00, 0x20, 0x10, 0x10, 0x08, 0x08, 0x08, 0x10, 0x10, 0x20, 0x00, 0x00}, // ')'
+	{0x00, 0x00, 0x00, 0x00, 0x48, 0x30, 0xfc, 0x30, 0x48, 0x00, 0x00, 0x00, 0x00}, // '*'
+	{0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x7c, 0x10, 0x10, 0x00, 0x00, 0x00, 0x00}, // '+'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x38, 0x30, 0x40, 0x00}, // ','
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '-'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x38, 0x10, 0x00}, // '.'
+	{0x00, 0x00, 0x04, 0x04, 0x08, 0x08, 0x10, 0x20, 0x20, 0x40, 0x40, 0x00, 0x00}, // '/'
+	{0x00, 0x00, 0x30, 0x48, 0x84, 0x84, 0x84, 0x84, 0x84, 0x48, 0x30, 0x00, 0x00}, // '0'
+	{0x00, 0x00, 0x10, 0x30, 0x50, 0x10, 0x10, 0x10, 0x10, 0x10, 0x7c, 0x00, 0x00}, // '1'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x04, 0x08, 0x30, 0x40, 0x80, 0xfc, 0x00, 0x00}, // '2'
+	{0x00, 0x00, 0xfc, 0x04, 0x08, 0x10, 0x38, 0x04, 0x04, 0x84, 0x78, 0x00, 0x00}, // '3'
+	{0x00, 0x00, 0x08, 0x18, 0x28, 0x48, 0x88, 0x88, 0xfc, 0x08, 0x08, 0x00, 0x00}, // '4'
+	{0x00, 0x00, 0xfc, 0x80, 0x80, 0xb8, 0xc4, 0x04, 0x04, 0x84, 0x78, 0x00, 0x00}, // '5'
+	{0x00, 0x00, 0x38, 0x40, 0x80, 0x80, 0xb8, 0xc4, 0x84, 0x84, 0x78, 0x00, 0x00}, // '6'
+	{0x00, 0x00, 0xfc, 0x04, 0x08, 0x10, 0x10, 0x20, 0x20, 0x40, 0x40, 0x00, 0x00}, // '7'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x84, 0x78, 0x84, 0x84, 0x84, 0x78, 0x00, 0x00}, // '8'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x8c, 0x74, 0x04, 0x04, 0x08, 0x70, 0x00, 0x00}, // '9'
+	{0x00, 0x00, 0x00, 0x00, 0x10, 0x38, 0x10, 0x00, 0x00, 0x10, 0x38, 0x10, 0x00}, // ':'
+	{0x00, 0x00, 0x00, 0x00, 0x10, 0x38, 0x10, 0x00, 0x00, 0x38, 0x30, 0x40, 0x00}, // ';'
+	{0x00, 0x00, 0x04, 0x08, 0x10, 0x20, 0x40, 0x20, 0x10, 0x08, 0x04, 0x00, 0x00}, // '<'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0xfc, 0x00, 0x00, 0xfc, 0x00, 0x00, 0x00, 0x00}, // '='
+	{0x00, 0x00, 0x40, 0x20, 0x10, 0x08, 0x04, 0x08, 0x10, 0x20, 0x40, 0x00, 0x00}, // '>'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x04, 0x08, 0x10, 0x10, 0x00, 0x10, 0x00, 0x00}, // '?'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x9c, 0xa4, 0xac, 0x94, 0x80, 0x78, 0x00, 0x00}, // '@'
+	{0x00, 0x00, 0x30, 0x48, 0x84, 0x84, 0x84, 0xfc, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'A'
+	{0x00, 0x00, 0xf8, 0x44, 0x44, 0x44, 0x78, 0x44, 0x44, 0x44, 0xf8, 0x00, 0x00}, // 'B'
+	{0x00, 0x00, 0x78, 0x84, 0x80, 0x80, 0x80, 0x80, 0x80, 0x84, 0x78, 0x00, 0x00}, // 'C'
+	{0x00, 0x00, 0xf8, 0x44, 0x44, 0x44, 0x44, 0x44, 0x44, 0x44, 0xf8, 0x00, 0x00}, // 'D'
+	{0x00, 0x00, 0xfc, 0x80, 0x80, 0x80, 0xf0, 0x80, 0x80, 0x80, 0xfc, 0x00, 0x00}, // 'E'
+	{0x00, 0x00, 0xfc, 0x80, 0x80, 0x80, 0xf0, 0x80, 0x80, 0x80, 0x80, 0x00, 0x00}, // 'F'
+	{0x00, 0x00, 0x78, 0x84, 0x80, 0x80, 0x80, 0x9c, 0x84, 0x8c, 0x74, 0x00, 0x00}, // 'G'
+	{0x00, 0x00, 0x84, 0x84, 0x84, 0x84, 0xfc, 0x84, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'H'
+	{0x00, 0x00, 0x7c, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x7c, 0x00, 0x00}, // 'I'
+	{0x00, 0x00, 0x1c, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x88, 0x70, 0x00, 0x00}, // 'J'
+	{0x00, 0x00, 0x84, 0x88, 0x90, 0xa0, 0xc0, 0xa0, 0x90, 0x88, 0x84, 0x00, 0x00}, // 'K'
+	{0x00, 0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0xfc, 0x00, 0x00}, // 'L'
+	{0x00, 0x00, 0x84, 0xcc, 0xcc, 0xb4, 0xb4, 0x84, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'M'
+	{0x00, 0x00, 0x84, 0x84, 0xc4, 0xa4, 0x94, 0x8c, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'N'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x84, 0x84, 0x84, 0x84, 0x84, 0x78, 0x00, 0x00}, // 'O'
+	{0x00, 0x00, 0xf8, 0x84, 0x84, 0x84, 0xf8, 0x80, 0x80, 0x80, 0x80, 0x00, 0x00}, // 'P'
+	{0x00, 0x00, 0x78, 0x84, 0x84, 0x84, 0x84, 0x84, 0xa4, 0x94, 0x78, 0x04, 0x00}, // 'Q'
+	{0x00, 0x00, 0xf8, 0x84, 0x84, 0x84, 0xf8, 0xa0, 0x90, 0x88, 0x84, 0x00, 0x00}, // 'R'
+	{0x00, 0x00, 0x78, 0x84, 0x80, 0x80, 0x78, 0x04, 0x04, 0x84, 0x78, 0x00, 0x00}, // 'S'
+	{0x00, 0x00, 0x7c, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00, 0x00}, // 'T'
+	{0x00, 0x00, 0x84, 0x84, 0x84, 0x84, 0x84, 0x84, 0x84, 0x84, 0x78, 0x00, 0x00}, // 'U'
+	{0x00, 0x00, 0x84, 0x84, 0x84, 0x48, 0x48, 0x48, 0x30, 0x30, 0x30, 0x00, 0x00}, // 'V'
+	{0x00, 0x00, 0x84, 0x84, 0x84, 0x84, 0xb4, 0xb4, 0xcc, 0xcc, 0x84, 0x00, 0x00}, // 'W'
+	{0x00, 0x00, 0x84, 0x84, 0x48, 0x48, 0x30, 0x48, 0x48, 0x84, 0x84, 0x00, 0x00}, // 'X'
+	{0x00, 0x00, 0x44, 0x44, 0x28, 0x28, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00, 0x00}, // 'Y'
+	{0x00, 0x00, 0xfc, 0x04, 0x08, 0x10, 0x30, 0x20, 0x40, 0x80, 0xfc, 0x00, 0x00}, // 'Z'
+	{0x00, 0x78, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x78, 0x00}, // '['
+	{0x00, 0x00, 0x40, 0x40, 0x20, 0x20, 0x10, 0x08, 0x08, 0x04, 0x04, 0x00, 0x00}, // '\\'
+	{0x00, 0x78, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x78, 0x00}, // ']'
+	{0x00, 0x00, 0x10, 0x28, 0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '^'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xfc, 0x00}, // '_'
+	{0x00, 0x20, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '`'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x78, 0x04, 0x7c, 0x84, 0x8c, 0x74, 0x00, 0x00}, // 'a'
+	{0x00, 0x00, 0x80, 0x80, 0x80, 0xb8, 0xc4, 0x84, 0x84, 0xc4, 0xb8, 0x00, 0x00}, // 'b'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x78, 0x84, 0x80, 0x80, 0x84, 0x78, 0x00, 0x00}, // 'c'
+	{0x00, 0x00, 0x04, 0x04, 0x04, 0x74, 0x8c, 0x84, 0x84, 0x8c, 0x74, 0x00, 0x00}, // 'd'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x78, 0x84, 0xfc, 0x80, 0x84, 0x78, 0x00, 0x00}, // 'e'
+	{0x00, 0x00, 0x38, 0x44, 0x40, 0x40, 0xf0, 0x40, 0x40, 0x40, 0x40, 0x00, 0x00}, // 'f'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x74, 0x88, 0x88, 0x70, 0x80, 0x78, 0x84, 0x78}, // 'g'
+	{0x00, 0x00, 0x80, 0x80, 0x80, 0xb8, 0xc4, 0x84, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'h'
+	{0x00, 0x00, 0x00, 0x10, 0x00, 0x30, 0x10, 0x10, 0x10, 0x10, 0x7c, 0x00, 0x00}, // 'i'
+	{0x00, 0x00, 0x00, 0x04, 0x00, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x44, 0x44, 0x38}, // 'j'
+	{0x00, 0x00, 0x80, 0x80, 0x80, 0x88, 0x90, 0xe0, 0x90, 0x88, 0x84, 0x00, 0x00}, // 'k'
+	{0x00, 0x00, 0x30, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x7c, 0x00, 0x00}, // 'l'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x68, 0x54, 0x54, 0x54, 0x54, 0x44, 0x00, 0x00}, // 'm'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0xb8, 0xc4, 0x84, 0x84, 0x84, 0x84, 0x00, 0x00}, // 'n'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x78, 0x84, 0x84, 0x84, 0x84, 0x78, 0x00, 0x00}, // 'o'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0xb8, 0xc4, 0x84, 0xc4, 0xb8, 0x80, 0x80, 0x80}, // 'p'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x74, 0x8c, 0x84, 0x8c, 0x74, 0x04, 0x04, 0x04}, // 'q'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0xb8, 0x44, 0x40, 0x40, 0x40, 0x40, 0x00, 0x00}, // 'r'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x78, 0x84, 0x60, 0x18, 0x84, 0x78, 0x00, 0x00}, // 's'
+	{0x00, 0x00, 0x00, 0x40, 0x40, 0xf0, 0x40, 0x40, 0x40, 0x44, 0x38, 0x00, 0x00}, // 't'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x84, 0x84, 0x84, 0x84, 0x8c, 0x74, 0x00, 0x00}, // 'u'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x44, 0x44, 0x44, 0x28, 0x28, 0x10, 0x00, 0x00}, // 'v'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x44, 0x44, 0x54, 0x54, 0x54, 0x28, 0x00, 0x00}, // 'w'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x84, 0x48, 0x30, 0x30, 0x48, 0x84, 0x00, 0x00}, // 'x'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x84, 0x84, 0x84, 0x8c, 0x74, 0x04, 0x84, 0x78}, // 'y'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0xfc, 0x08, 0x10, 0x20, 0x40, 0xfc, 0x00, 0x00}, // 'z'
+	{0x00, 0x1c, 0x20, 0x20, 0x20, 0x10, 0x60, 0x10, 0x20, 0x20, 0x20, 0x1c, 0x00}, // '{'
+	{0x00, 0x00, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x00, 0x00}, // '|'
+	{0x00, 0x70, 0x08, 0x08, 0x08, 0x10, 0x0c, 0x10, 0x08, 0x08, 0x08, 0x70, 0x00}, // '}'
+	{0x00, 0x00, 0x24, 0x54, 0x48, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // '~'
+}
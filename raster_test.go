@@ -0,0 +1,119 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Run the tests with -update to write new golden images, after checking the changes to them are wanted
+var updateGolden = flag.Bool("update", false, "write the rendered images as the new golden images")
+
+const (
+	goldenWidth  = 400
+	goldenHeight = 300
+
+	// How far each colour channel of a pixel can be from the golden image, to allow for small rounding differences
+	goldenTolerance = 8
+)
+
+// Renders each testdata/*.json scene with the image renderer, comparing it with the testdata/*.png golden image of the
+// same name.  When they don't match, the rendered image is written to testdata/*.actual.png
+func TestRenderGolden(t *testing.T) {
+	scenes, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenes) == 0 {
+		t.Fatal("no test scenes found")
+	}
+	for _, scenePath := range scenes {
+		name := strings.TrimSuffix(filepath.Base(scenePath), ".json")
+		t.Run(name, func(t *testing.T) {
+			world, err := readSceneFile(scenePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			textures, err := loadTextures(world, "testdata")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := renderImage(world, goldenWidth, goldenHeight, textures)
+			goldenPath := filepath.Join("testdata", name+".png")
+			actualPath := filepath.Join("testdata", name+".actual.png")
+			if *updateGolden {
+				writeTestPNG(t, goldenPath, got)
+				return
+			}
+
+			want, err := readTestPNG(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg := compareImages(got, want, goldenTolerance); msg != "" {
+				writeTestPNG(t, actualPath, got)
+				t.Errorf("%s doesn't match %s: %s", actualPath, goldenPath, msg)
+				return
+			}
+			os.Remove(actualPath)
+		})
+	}
+}
+
+// Returns a description of how two images differ, or "" if every channel of every pixel is within tolerance
+func compareImages(got image.Image, want image.Image, tolerance int) string {
+	if got.Bounds() != want.Bounds() {
+		return "the size is " + got.Bounds().String() + ", wanted " + want.Bounds().String()
+	}
+	bad := 0
+	var first image.Point
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8), int(a1>>8) - int(a2>>8)} {
+				if d > tolerance || d < -tolerance {
+					if bad == 0 {
+						first = image.Pt(x, y)
+					}
+					bad++
+					break
+				}
+			}
+		}
+	}
+	if bad > 0 {
+		return strconv.Itoa(bad) + " pixels are different, starting at " + first.String()
+	}
+	return ""
+}
+
+func readTestPNG(pngPath string) (image.Image, error) {
+	f, err := os.Open(pngPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writeTestPNG(t *testing.T, pngPath string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
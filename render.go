@@ -0,0 +1,302 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// The URL of the source code, shown at the bottom of the side panel
+const sourceURL = "https://github.com/justinclift/tinygo_canvas2"
+
+// A 2D drawing surface, following the HTML5 canvas 2D context.  Paths are built up with the path methods, then filled,
+// stroked, or used to clip later drawing.  Colours and fonts use the CSS formats
+type Renderer interface {
+	// Path building
+	BeginPath()
+	MoveTo(x float64, y float64)
+	LineTo(x float64, y float64)
+	Arc(x float64, y float64, radius float64, startAngle float64, endAngle float64) // Angles in radians, clockwise
+	ClosePath()
+
+	// Drawing the current path, or limiting later drawing to inside it
+	Fill()
+	Stroke()
+	Clip()
+
+	// Drawing without a path
+	FillRect(x float64, y float64, w float64, h float64)
+	FillText(text string, x float64, y float64)
+
+	// Drawing state, which is kept by Save() and put back by Restore() along with the clip region and scale.  A fill
+	// gradient goes from one colour at (x0, y0) to another at (x1, y1), and is used until the fill style is next set.
+	// A fill image is repeated across the drawing, placed by a transform as described for drawTexture.  If the image
+	// can't be drawn (eg it hasn't loaded yet) the fill style is left as it was
+	SetFillStyle(colour string)
+	SetFillGradient(x0 float64, y0 float64, x1 float64, y1 float64, from string, to string)
+	SetFillImage(image string, transform [6]float64)
+	SetGlobalAlpha(alpha float64) // From 0 (invisible) to 1 (fully solid)
+	SetStrokeStyle(colour string)
+	SetLineWidth(w float64)
+	SetFont(font string)
+	Scale(x float64, y float64)
+	Save()
+	Restore()
+}
+
+// Everything shown in one frame of the web page
+type frameInfo struct {
+	world           map[string]Object
+	width           float64 // Size of the whole drawing area
+	height          float64
+	graphWidth      float64 // Size of the graph area, with the side panel to the right of it
+	graphHeight     float64
+	step            float64     // Pixels per world space unit
+	selected        string      // Name of the selected object
+	mode            RenderMode  // Render mode of objects using the default one
+	opText          string      // Description of the current operation
+	highlightSource bool        // Whether the mouse is over the source code link
+	stats           *frameStats // Shown in the side panel when set, eg from the frame before
+}
+
+// Counts of what went into drawing a frame
+type frameStats struct {
+	commands int // Drawing commands for the graph area
+	batches  int // Groups the commands were merged into, each drawn in one go
+	calls    int // Calls made to the browser for the whole frame.  Only counted by renderers drawing in a browser
+}
+
+// The help text in the side panel, in groups of lines
+var helpText = [][]string{
+	{"Use wasd to move, numpad keys", "to rotate, mouse wheel to zoom."},
+	{"+ and - keys to change speed."},
+	{"Press a key a 2nd time to", "stop the current change."},
+	{"Click an object to select it.", "E changes how its edges are", "found from its surfaces.", "R changes how it's drawn.",
+		"H adds its convex hull."},
+	{"F plots z = f(x, y), or x, y, z", "of t (a curve) or of u and v."},
+	{"Drop model files onto the", "graph to load them."},
+	{"Ctrl+S saves the scene.", "Ctrl+E exports an SVG, add", "Shift for object names.", "Ctrl+P exports a PNG, and",
+		"Ctrl+O exports an OBJ, add", "Shift to split it into triangles."},
+}
+
+// Draws a whole frame: the graph area with its grid and objects, then the side panel with the current operation,
+// selected object, help text, and source code link.  Returns the counts of what was drawn
+func drawFrame(r Renderer, f frameInfo) frameStats {
+	border := float64(2)
+	gap := float64(3)
+	top := border + gap
+
+	// Clear the background
+	r.SetFillStyle("white")
+	r.FillRect(0, 0, f.width, f.height)
+
+	// Save the current graphics state - no clip region currently defined - as the default
+	r.Save()
+
+	// Set the clip region so drawing only occurs in the display area
+	r.BeginPath()
+	r.MoveTo(0, 0)
+	r.LineTo(f.graphWidth, 0)
+	r.LineTo(f.graphWidth, f.height)
+	r.LineTo(0, f.height)
+	r.Clip()
+
+	// Draw the grid lines, then the objects in Z depth order
+	cmds := drawList(f.world, f.graphWidth, f.graphHeight, f.step, false, f.selected, f.mode)
+	batches := drawGraph(r, cmds)
+
+	// Set the clip region so drawing only occurs in the side panel
+	r.Restore()
+	r.Save()
+	r.BeginPath()
+	r.MoveTo(f.graphWidth, 0)
+	r.LineTo(f.width, 0)
+	r.LineTo(f.width, f.height)
+	r.LineTo(f.graphWidth, f.height)
+	r.Clip()
+
+	// Draw the text describing the current operation
+	textY := top + 20
+	r.SetFillStyle("black")
+	r.SetFont("bold 14px serif")
+	r.FillText("Operation:", f.graphWidth+20, textY)
+	textY += 20
+	r.SetFont("14px sans-serif")
+	r.FillText(f.opText, f.graphWidth+20, textY)
+	textY += 30
+	if f.selected != "" {
+		r.SetFont("bold 14px serif")
+		r.FillText("Selected:", f.graphWidth+20, textY)
+		textY += 20
+		r.SetFont("14px sans-serif")
+		r.FillText(f.selected, f.graphWidth+20, textY)
+		textY += 30
+	}
+	if f.stats != nil {
+		r.SetFont("bold 14px serif")
+		r.FillText("Last frame:", f.graphWidth+20, textY)
+		textY += 20
+		r.SetFont("14px sans-serif")
+		r.FillText(strconv.Itoa(f.stats.commands)+" commands in", f.graphWidth+20, textY)
+		textY += 20
+		r.FillText(strconv.Itoa(f.stats.batches)+" batches", f.graphWidth+20, textY)
+		textY += 20
+		if f.stats.calls > 0 {
+			r.FillText(strconv.Itoa(f.stats.calls)+" browser calls", f.graphWidth+20, textY)
+			textY += 20
+		}
+		textY += 10
+	}
+
+	// Add the help text about control keys and mouse zoom
+	r.SetFillStyle("blue")
+	r.SetFont("14px sans-serif")
+	for _, group := range helpText {
+		for _, line := range group {
+			r.FillText(line, f.graphWidth+20, textY)
+			textY += 20
+		}
+		textY += 10
+	}
+
+	// Clear the source code link area
+	r.SetFillStyle("white")
+	r.FillRect(f.graphWidth+1, f.graphHeight-55, f.width, f.height)
+
+	// Add the URL to the source code
+	r.SetFillStyle("black")
+	r.SetFont("bold 14px serif")
+	r.FillText("Source code:", f.graphWidth+20, f.graphHeight-35)
+	r.SetFillStyle("blue")
+	if f.highlightSource {
+		r.SetFont("bold 12px sans-serif")
+	} else {
+		r.SetFont("12px sans-serif")
+	}
+	r.FillText(sourceURL, f.graphWidth+20, f.graphHeight-15)
+
+	// Draw a border around the graph area
+	r.SetLineWidth(2)
+	r.SetStrokeStyle("white")
+	r.BeginPath()
+	r.MoveTo(0, 0)
+	r.LineTo(f.width, 0)
+	r.LineTo(f.width, f.height)
+	r.LineTo(0, f.height)
+	r.ClosePath()
+	r.Stroke()
+	r.SetStrokeStyle("black")
+	r.BeginPath()
+	r.MoveTo(border, border)
+	r.LineTo(f.graphWidth, border)
+	r.LineTo(f.graphWidth, f.graphHeight)
+	r.LineTo(border, f.graphHeight)
+	r.ClosePath()
+	r.Stroke()
+
+	// Restore the default graphics state (eg no clip region)
+	r.Restore()
+	return frameStats{commands: len(cmds), batches: batches}
+}
+
+// Draws the graph area's drawing commands.  Runs of commands of the same kind, colour, and opacity are drawn as a
+// single path, so a whole grid or all the edges of an object take only a few renderer calls.  See-through, gradient
+// filled, and textured surfaces are drawn one at a time instead, as they need to blend over each other in order or
+// have their own fill.  Returns the number of runs drawn
+func drawGraph(r Renderer, cmds []drawCmd) (batches int) {
+	var fillStyle, strokeStyle string
+	alpha := float64(1)
+	r.SetLineWidth(1)
+	for i := 0; i < len(cmds); batches++ {
+		c := cmds[i]
+		j := i + 1
+		alone := c.kind == DRAW_SURFACE && (c.alpha != 0 || c.gradient != nil || c.texture != nil)
+		for !alone && j < len(cmds) && cmds[j].kind == c.kind && cmds[j].colour == c.colour &&
+			cmds[j].alpha == c.alpha && cmds[j].gradient == nil && cmds[j].texture == nil {
+			j++
+		}
+		batch := cmds[i:j]
+		i = j
+
+		a := c.alpha
+		if a == 0 {
+			a = 1
+		}
+		if a != alpha {
+			alpha = a
+			r.SetGlobalAlpha(alpha)
+		}
+
+		switch c.kind {
+		case DRAW_GRID, DRAW_EDGE:
+			if c.colour != strokeStyle {
+				strokeStyle = c.colour
+				r.SetStrokeStyle(strokeStyle)
+			}
+			r.BeginPath()
+			for _, b := range batch {
+				r.MoveTo(b.pts[0].X, b.pts[0].Y)
+				r.LineTo(b.pts[1].X, b.pts[1].Y)
+			}
+			r.Stroke()
+			continue
+		}
+		if g := c.gradient; g != nil {
+			r.SetFillGradient(g.from.X, g.from.Y, g.to.X, g.to.Y, g.fromColour, g.toColour)
+			fillStyle = ""
+		} else if c.colour != fillStyle {
+			fillStyle = c.colour
+			r.SetFillStyle(fillStyle)
+		}
+		if t := c.texture; t != nil {
+			r.SetFillImage(t.image, t.transform)
+			fillStyle = ""
+		}
+		switch c.kind {
+		case DRAW_SURFACE:
+			// Surfaces facing away are turned around, as overlapping surfaces going opposite ways would leave holes
+			// in a single filled path
+			r.BeginPath()
+			for _, b := range batch {
+				pts := b.pts
+				if screenArea(pts) < 0 {
+					pts = make([]screenPoint, len(b.pts))
+					for k, p := range b.pts {
+						pts[len(pts)-1-k] = p
+					}
+				}
+				r.MoveTo(pts[0].X, pts[0].Y)
+				for _, p := range pts[1:] {
+					r.LineTo(p.X, p.Y)
+				}
+				r.ClosePath()
+			}
+			r.Fill()
+		case DRAW_POINT:
+			r.BeginPath()
+			for _, b := range batch {
+				r.MoveTo(b.pts[0].X+b.radius, b.pts[0].Y)
+				r.Arc(b.pts[0].X, b.pts[0].Y, b.radius, 0, 2*math.Pi)
+			}
+			r.Fill()
+		case DRAW_LABEL:
+			r.SetFont("12px sans-serif")
+			for _, b := range batch {
+				r.FillText(b.text, b.pts[0].X, b.pts[0].Y)
+			}
+		}
+	}
+	if alpha != 1 {
+		r.SetGlobalAlpha(1)
+	}
+	return batches
+}
+
+// Returns the signed area of a polygon on the screen, which is positive if it goes clockwise (as the screen's Y axis
+// points down)
+func screenArea(pts []screenPoint) (area float64) {
+	for i, a := range pts {
+		b := pts[(i+1)%len(pts)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
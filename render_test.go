@@ -0,0 +1,116 @@
+package main
+
+import "testing"
+
+// A renderer which counts the calls made to it, for checking how drawing is batched
+type recordingRenderer struct {
+	calls map[string]int
+}
+
+func newRecordingRenderer() *recordingRenderer {
+	return &recordingRenderer{calls: make(map[string]int)}
+}
+
+func (r *recordingRenderer) BeginPath()                                      { r.calls["BeginPath"]++ }
+func (r *recordingRenderer) MoveTo(float64, float64)                         { r.calls["MoveTo"]++ }
+func (r *recordingRenderer) LineTo(float64, float64)                         { r.calls["LineTo"]++ }
+func (r *recordingRenderer) Arc(float64, float64, float64, float64, float64) { r.calls["Arc"]++ }
+func (r *recordingRenderer) ClosePath()                                      { r.calls["ClosePath"]++ }
+func (r *recordingRenderer) Fill()                                           { r.calls["Fill"]++ }
+func (r *recordingRenderer) Stroke()                                         { r.calls["Stroke"]++ }
+func (r *recordingRenderer) Clip()                                           { r.calls["Clip"]++ }
+func (r *recordingRenderer) FillRect(float64, float64, float64, float64)     { r.calls["FillRect"]++ }
+func (r *recordingRenderer) FillText(string, float64, float64)               { r.calls["FillText"]++ }
+func (r *recordingRenderer) SetFillStyle(string)                             { r.calls["SetFillStyle"]++ }
+func (r *recordingRenderer) SetFillImage(string, [6]float64)                 { r.calls["SetFillImage"]++ }
+func (r *recordingRenderer) SetGlobalAlpha(float64)                          { r.calls["SetGlobalAlpha"]++ }
+func (r *recordingRenderer) SetStrokeStyle(string)                           { r.calls["SetStrokeStyle"]++ }
+func (r *recordingRenderer) SetLineWidth(float64)                            { r.calls["SetLineWidth"]++ }
+func (r *recordingRenderer) SetFont(string)                                  { r.calls["SetFont"]++ }
+func (r *recordingRenderer) Scale(float64, float64)                          { r.calls["Scale"]++ }
+func (r *recordingRenderer) Save()                                           { r.calls["Save"]++ }
+func (r *recordingRenderer) Restore()                                        { r.calls["Restore"]++ }
+
+func (r *recordingRenderer) SetFillGradient(float64, float64, float64, float64, string, string) {
+	r.calls["SetFillGradient"]++
+}
+
+// Checks runs of drawing commands sharing a kind, colour, and opacity are drawn together, while see-through and
+// gradient filled surfaces are drawn one at a time
+func TestDrawGraph(t *testing.T) {
+	line := []screenPoint{{0, 0}, {10, 10}}
+	tri := []screenPoint{{0, 0}, {10, 0}, {0, 10}}
+	var cmds []drawCmd
+	add := func(n int, c drawCmd) {
+		for i := 0; i < n; i++ {
+			cmds = append(cmds, c)
+		}
+	}
+	add(3, drawCmd{kind: DRAW_GRID, colour: gridColour, pts: line})
+	add(4, drawCmd{kind: DRAW_SURFACE, colour: "red", pts: tri})
+	add(1, drawCmd{kind: DRAW_SURFACE, colour: "blue", pts: tri})
+	add(2, drawCmd{kind: DRAW_SURFACE, colour: "red", alpha: 0.5, pts: tri})
+	add(1, drawCmd{kind: DRAW_SURFACE, pts: tri, gradient: &drawGradient{tri[0], tri[1], "red", "blue"}})
+	add(3, drawCmd{kind: DRAW_EDGE, colour: "black", pts: line})
+	add(2, drawCmd{kind: DRAW_POINT, colour: "green", radius: 2, pts: line[:1]})
+
+	r := newRecordingRenderer()
+	if batches := drawGraph(r, cmds); batches != 8 {
+		t.Errorf("%d batches, wanted 8", batches)
+	}
+	want := map[string]int{
+		"BeginPath":       8,
+		"Stroke":          2, // The grid and the edges
+		"Fill":            6, // The solid red and blue surfaces, each see-through one, the gradient, and the points
+		"ClosePath":       8,
+		"Arc":             2,
+		"SetFillStyle":    4, // Red, blue, red again after it, and green after the gradient
+		"SetFillGradient": 1,
+		"SetGlobalAlpha":  2, // Half for the see-through surfaces, then back to solid
+		"SetStrokeStyle":  2,
+	}
+	for call, n := range want {
+		if r.calls[call] != n {
+			t.Errorf("%d calls to %s, wanted %d", r.calls[call], call, n)
+		}
+	}
+}
+
+// Checks the counts returned for a frame, with several surfaces of the same colour drawn as one batch
+func TestDrawFrameStats(t *testing.T) {
+	// Two 4 x 4 squares facing the screen, side by side
+	square := func(x float64, colour string, opacity float64) Object {
+		o := plane(4, 4)
+		for i, p := range o.P {
+			o.P[i] = Point{X: p.X + x, Y: p.Z, Z: p.Y}
+		}
+		o.C, o.A = colour, opacity
+		o.Mid = midPoint(o.P)
+		return o
+	}
+	f := frameInfo{width: 600, height: 300, graphWidth: 400, graphHeight: 300, step: 20, mode: RENDER_SOLID}
+	grid := len(drawList(nil, f.graphWidth, f.graphHeight, f.step, false, "", f.mode))
+
+	tests := []struct {
+		name    string
+		world   map[string]Object
+		batches int
+	}{
+		{"same colour", map[string]Object{"a": square(-3, "red", 0), "b": square(3, "red", 0)}, 2},
+		{"two colours", map[string]Object{"a": square(-3, "red", 0), "b": square(3, "blue", 0)}, 3},
+		{"see-through", map[string]Object{"a": square(-3, "red", 0), "b": square(3, "red", 0.5)}, 18},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f.world = tc.world
+			r := newRecordingRenderer()
+			stats := drawFrame(r, f)
+			if stats.commands != grid+32 || stats.batches != tc.batches || stats.calls != 0 {
+				t.Errorf("stats are %+v, wanted %d commands in %d batches", stats, grid+32, tc.batches)
+			}
+			if r.calls["Save"] != r.calls["Restore"] {
+				t.Errorf("%d saves but %d restores", r.calls["Save"], r.calls["Restore"])
+			}
+		})
+	}
+}
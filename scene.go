@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// The version of the scene file format written by writeScene().  Bump this when making incompatible changes
+const sceneVersion = 1
+
+// A whole world, as stored in a scene file
+type Scene struct {
+	Version int           `json:"version"`
+	Objects []SceneObject `json:"objects"`
+	Camera  SceneCamera   `json:"camera"`
+	Lights  []SceneLight  `json:"lights,omitempty"`
+
+	// How objects with the default render mode are drawn.  Defaults to surfaces with their edges and points
+	RenderMode RenderMode `json:"renderMode,omitempty"`
+}
+
+// An object in a scene, along with the transform used to place it in the world.  Instead of listing its points, an
+// object can be a generated primitive shape, a solid swept from a profile, a function plot, or a boolean operation
+// between two other objects
+type SceneObject struct {
+	Name string `json:"name"`
+	Object
+	Primitive *ScenePrimitive `json:"primitive,omitempty"`
+	Sweep     *SceneSweep     `json:"sweep,omitempty"`
+	Plot      *ScenePlot      `json:"plot,omitempty"`
+	CSG       *SceneCSG       `json:"csg,omitempty"`
+	Hull      bool            `json:"hull,omitempty"` // Replaces the object with the convex hull of its points
+	Transform SceneTransform  `json:"transform"`
+
+	// Finds the edges from the surfaces instead of using the listed ones: "all", "boundary", or "feature".  Feature
+	// edges are where surfaces meet at more than the crease angle in degrees, which defaults to 30
+	EdgeMode    string  `json:"edgeMode,omitempty"`
+	CreaseAngle float64 `json:"creaseAngle,omitempty"`
+}
+
+// A boolean operation between two closed objects listed earlier in the scene, which are used up by it.  The objects
+// are combined where their transforms have placed them, then the result is placed by its own transform
+type SceneCSG struct {
+	Operation string   `json:"operation"` // "union", "difference" (the first object with the second cut out), or "intersection"
+	Objects   []string `json:"objects"`   // Names of the two objects
+}
+
+// A function plot generated when the scene is loaded.  Plots are z = f(x, y) surfaces (the default), parametric
+// curves along t, or parametric surfaces over u and v.  The heights of z = f(x, y) surfaces are shown up the world's Y
+// axis, while the others use the world's axes as they are.  Functions of surfaces which use t change as time passes
+type ScenePlot struct {
+	Type      string    `json:"type,omitempty"`      // "surface", "curve", or "parametric"
+	Function  string    `json:"function,omitempty"`  // Height of z = f(x, y) surfaces, eg "sin(x) * cos(y + t)"
+	Functions []string  `json:"functions,omitempty"` // X, Y, and Z of the others, eg ["cos(t)", "t / 5", "sin(t)"]
+	X         []float64 `json:"x,omitempty"`         // Range of x values of z = f(x, y) surfaces.  Defaults to -5 to 5
+	Y         []float64 `json:"y,omitempty"`         // Range of y values of z = f(x, y) surfaces.  Defaults to -5 to 5
+	T         []float64 `json:"t,omitempty"`         // Range of t along curves.  Defaults to 0 to 2 pi
+	U         []float64 `json:"u,omitempty"`         // Range of u of parametric surfaces.  Defaults to 0 to 2 pi
+	V         []float64 `json:"v,omitempty"`         // Range of v of parametric surfaces.  Defaults to 0 to 2 pi
+	Steps     int       `json:"steps,omitempty"`     // Grid squares along each side of surfaces (along u for parametric ones), or lines along curves
+	VSteps    int       `json:"vsteps,omitempty"`    // Grid squares along v of parametric surfaces.  Defaults to steps
+}
+
+// A primitive shape generated when the scene is loaded.  Sizes not given use the defaults of a shape which fits in a
+// 2 x 2 x 2 cube
+type ScenePrimitive struct {
+	Type     string  `json:"type"`               // "cube", "sphere", "cylinder", "cone", "torus", or "plane"
+	Size     float64 `json:"size,omitempty"`     // Width of cubes and planes
+	Radius   float64 `json:"radius,omitempty"`   // Radius of spheres, cylinders, cones, and the middle of tori
+	Tube     float64 `json:"tube,omitempty"`     // Radius of the tube of tori
+	Height   float64 `json:"height,omitempty"`   // Height of cylinders and cones
+	Segments int     `json:"segments,omitempty"` // Slices around round shapes, or divisions along each side of planes
+	Rings    int     `json:"rings,omitempty"`    // Slices from top to bottom of spheres, or around the tube of tori
+}
+
+// A solid made from a 2D profile when the scene is loaded.  Lathes revolve the profile around the Y axis, and
+// extrusions move it along a direction or through the points of a path
+type SceneSweep struct {
+	Type      string         `json:"type"`                // "lathe" or "extrude"
+	Profile   [][2]float64   `json:"profile"`             // X and Y of each point.  Lathes use X as the radius
+	Holes     [][][2]float64 `json:"holes,omitempty"`     // Polygons cut out of extrusions' profiles, which mustn't touch
+	Segments  int            `json:"segments,omitempty"`  // Slices around lathes.  Defaults to 16
+	Direction []float64      `json:"direction,omitempty"` // X, Y, and Z to extrude along.  Defaults to 0, 0, 1
+	Path      [][3]float64   `json:"path,omitempty"`      // Points to extrude through instead of a direction
+	Open      bool           `json:"open,omitempty"`      // Leaves the ends without caps
+}
+
+// Places an object in the world.  The object is scaled first, then rotated around the X, Y, and Z axes, then
+// translated
+type SceneTransform struct {
+	Translate [3]float64 `json:"translate"`
+	Rotate    [3]float64 `json:"rotate"`          // Degrees around the X, Y, and Z axes
+	Scale     float64    `json:"scale,omitempty"` // Defaults to 1 when not given
+}
+
+// How the world is first shown
+type SceneCamera struct {
+	Zoom float64    `json:"zoom,omitempty"` // Scale applied to the whole world after loading.  Defaults to 1
+	Spin [3]float64 `json:"spin"`           // Starting rotation, in degrees around the X, Y, and Z axes
+}
+
+// A light in the scene.  These aren't used by the renderer yet, but are kept when loading and saving scenes
+type SceneLight struct {
+	Type      string     `json:"type"` // eg "ambient", "directional", "point"
+	Colour    string     `json:"colour,omitempty"`
+	Intensity float64    `json:"intensity,omitempty"`
+	Position  [3]float64 `json:"position"`  // Used by point lights
+	Direction [3]float64 `json:"direction"` // Used by directional lights
+}
+
+// Reads a scene file
+func readScene(r io.Reader) (s Scene, err error) {
+	if err = json.NewDecoder(r).Decode(&s); err != nil {
+		return Scene{}, errors.New("scene: " + err.Error())
+	}
+	if s.Version < 1 {
+		return Scene{}, errors.New("scene: file has no version")
+	}
+	if s.Version > sceneVersion {
+		return Scene{}, errors.New("scene: version " + strconv.Itoa(s.Version) + " is newer than this viewer supports")
+	}
+	return s, nil
+}
+
+// Returns a scene holding the given objects as they currently are, so no transforms are needed to place them
+func sceneFromWorld(world map[string]Object, cam SceneCamera, lights []SceneLight) Scene {
+	s := Scene{Version: sceneVersion, Camera: cam, Lights: lights}
+	for _, name := range sortedNames(world) {
+		s.Objects = append(s.Objects, SceneObject{Name: name, Object: world[name], Transform: SceneTransform{Scale: 1}})
+	}
+	return s
+}
+
+// Writes a scene file
+func writeScene(w io.Writer, s Scene) error {
+	s.Version = sceneVersion
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Returns the objects of a scene, with their transforms applied
+func (s Scene) objects() (map[string]Object, error) {
+	obs := make(map[string]Object, len(s.Objects))
+	for i, so := range s.Objects {
+		name := so.objectName(i)
+		if _, ok := obs[name]; ok {
+			return nil, errors.New("scene: more than one object named '" + name + "'")
+		}
+		ob := so.Object
+		sources := 0
+		for _, given := range []bool{len(ob.P) != 0, so.Primitive != nil, so.Sweep != nil, so.Plot != nil, so.CSG != nil} {
+			if given {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return nil, errors.New("scene: object '" + name + "' can only have one of points, a primitive, a sweep, a plot, or a csg operation")
+		}
+		if so.Primitive != nil {
+			p, err := so.Primitive.object()
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			ob.P, ob.E, ob.S = p.P, p.E, p.S
+		}
+		if so.Sweep != nil {
+			sw, err := so.Sweep.object()
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			ob.P, ob.E, ob.S = sw.P, sw.E, sw.S
+		}
+		if so.Plot != nil {
+			p, err := newPlot(*so.Plot)
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			po := p.object(0)
+			ob.P, ob.E, ob.S = po.P, po.E, po.S
+		}
+		if so.CSG != nil {
+			c, err := s.csgObject(obs, so.CSG)
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			ob.P, ob.E, ob.S = c.P, c.E, c.S
+			if ob.C == "" {
+				ob.C = c.C
+			}
+			if len(ob.SC) == 0 {
+				ob.SC = c.SC
+			}
+		}
+		if so.Hull {
+			h, err := convexHull(ob.P)
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			// The hull's surfaces aren't the ones the colours were given for
+			ob.P, ob.E, ob.S, ob.SC = h.P, h.E, h.S, nil
+		}
+		if so.EdgeMode != "" {
+			mode, err := parseEdgeMode(so.EdgeMode)
+			if err != nil {
+				return nil, errors.New("scene: object '" + name + "': " + err.Error())
+			}
+			angle := so.CreaseAngle
+			if angle == 0 {
+				angle = defaultCreaseAngle
+			}
+			ob.E = deriveEdges(ob, mode, angle)
+		}
+		if ob.C == "" {
+			ob.C = defaultColour
+		}
+		m := so.Transform.matrix()
+		pts := ob.P
+		ob.P = make([]Point, 0, len(pts))
+		for _, p := range pts {
+			ob.P = append(ob.P, transform(m, p))
+		}
+		ob.Mid = midPoint(ob.P)
+		if err := ob.Validate(); err != nil {
+			return nil, errors.New("scene: object '" + name + "': " + err.Error())
+		}
+		obs[name] = ob
+	}
+	return obs, nil
+}
+
+// Returns the result of a boolean operation between two of the objects found so far, removing them from obs
+func (s Scene) csgObject(obs map[string]Object, c *SceneCSG) (Object, error) {
+	op, ok := csgOperations[c.Operation]
+	if !ok {
+		return Object{}, errors.New("unknown csg operation '" + c.Operation + "'")
+	}
+	if len(c.Objects) != 2 {
+		return Object{}, errors.New("csg operations need two objects")
+	}
+	var operands [2]Object
+	for i, name := range c.Objects {
+		if s.isPlot(name) {
+			return Object{}, errors.New("plot '" + name + "' can't be used in a csg operation")
+		}
+		ob, ok := obs[name]
+		if !ok {
+			return Object{}, errors.New("no object named '" + name + "' earlier in the scene")
+		}
+		operands[i] = ob
+	}
+	if c.Objects[0] == c.Objects[1] {
+		return Object{}, errors.New("csg operations need two different objects")
+	}
+	result, err := op(operands[0], operands[1])
+	if err != nil {
+		return Object{}, err
+	}
+	delete(obs, c.Objects[0])
+	delete(obs, c.Objects[1])
+	return result, nil
+}
+
+// Returns whether the named object of a scene is a function plot
+func (s Scene) isPlot(name string) bool {
+	for i, so := range s.Objects {
+		if so.objectName(i) == name {
+			return so.Plot != nil
+		}
+	}
+	return false
+}
+
+// Returns the function plots of a scene, keyed by object name.  Each is placed by its object's transform
+func (s Scene) plots() map[string]*plot {
+	plots := make(map[string]*plot)
+	for i, so := range s.Objects {
+		if so.Plot == nil {
+			continue
+		}
+		p, err := newPlot(*so.Plot)
+		if err != nil {
+			// Already reported by objects()
+			continue
+		}
+		p.place = so.Transform.matrix()
+		plots[so.objectName(i)] = p
+	}
+	return plots
+}
+
+// Returns the name of the object, which is the i'th in the scene.  Objects without a name are given one from their
+// position
+func (so SceneObject) objectName(i int) string {
+	if so.Name == "" {
+		return "object " + strconv.Itoa(i+1)
+	}
+	return so.Name
+}
+
+// Returns the generated object for a primitive shape
+func (p ScenePrimitive) object() (Object, error) {
+	size := func(v float64, def float64) float64 {
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+	count := func(v int, def int) int {
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+	if p.Size < 0 || p.Radius < 0 || p.Tube < 0 || p.Height < 0 || p.Segments < 0 || p.Rings < 0 {
+		return Object{}, errors.New("primitive sizes can't be negative")
+	}
+	switch p.Type {
+	case "cube":
+		return cube(size(p.Size, 2)), nil
+	case "sphere":
+		return sphere(size(p.Radius, 1), count(p.Segments, 16), count(p.Rings, 8)), nil
+	case "cylinder":
+		return cylinder(size(p.Radius, 1), size(p.Height, 2), count(p.Segments, 16)), nil
+	case "cone":
+		return cone(size(p.Radius, 1), size(p.Height, 2), count(p.Segments, 16)), nil
+	case "torus":
+		return torus(size(p.Radius, 0.75), size(p.Tube, 0.25), count(p.Segments, 16), count(p.Rings, 8)), nil
+	case "plane":
+		return plane(size(p.Size, 2), count(p.Segments, 1)), nil
+	}
+	return Object{}, errors.New("unknown primitive type '" + p.Type + "'")
+}
+
+// Returns the generated object for a sweep
+func (sw SceneSweep) object() (Object, error) {
+	points := func(pts [][2]float64) []Point {
+		poly := make([]Point, len(pts))
+		for i, p := range pts {
+			poly[i] = Point{X: p[0], Y: p[1]}
+		}
+		return poly
+	}
+	profile := points(sw.Profile)
+	if sw.Segments < 0 {
+		return Object{}, errors.New("sweep segments can't be negative")
+	}
+	switch sw.Type {
+	case "lathe":
+		if len(profile) < 2 {
+			return Object{}, errors.New("lathe profiles need at least 2 points")
+		}
+		if len(sw.Holes) > 0 {
+			return Object{}, errors.New("only extrusions can have holes")
+		}
+		for _, p := range profile {
+			if p.X < 0 {
+				return Object{}, errors.New("lathe profile points can't have a negative X")
+			}
+		}
+		segments := sw.Segments
+		if segments == 0 {
+			segments = 16
+		}
+		return lathe(profile, segments, !sw.Open), nil
+	case "extrude":
+		if len(profile) < 3 {
+			return Object{}, errors.New("extrude profiles need at least 3 points")
+		}
+		var holes [][]Point
+		for i, h := range sw.Holes {
+			hole := points(h)
+			if len(hole) < 3 {
+				return Object{}, errors.New("hole " + strconv.Itoa(i+1) + " needs at least 3 points")
+			}
+			for _, p := range hole {
+				if !inPolygon(profile, p) {
+					return Object{}, errors.New("hole " + strconv.Itoa(i+1) + " isn't inside the profile")
+				}
+			}
+			holes = append(holes, hole)
+		}
+		if len(sw.Path) > 0 {
+			if len(sw.Direction) > 0 {
+				return Object{}, errors.New("extrusions can only have one of a direction or a path")
+			}
+			path := make([]Point, len(sw.Path))
+			for i, p := range sw.Path {
+				path[i] = Point{X: p[0], Y: p[1], Z: p[2]}
+			}
+			if len(path) < 2 {
+				return Object{}, errors.New("extrude paths need at least 2 points")
+			}
+			return extrudePath(profile, holes, path, !sw.Open), nil
+		}
+		direction := Point{Z: 1}
+		if len(sw.Direction) > 0 {
+			if len(sw.Direction) != 3 {
+				return Object{}, errors.New("extrude directions need an X, Y, and Z")
+			}
+			direction = Point{X: sw.Direction[0], Y: sw.Direction[1], Z: sw.Direction[2]}
+			if vecLength(direction) == 0 {
+				return Object{}, errors.New("extrude directions can't be zero")
+			}
+		}
+		return extrude(profile, holes, direction, !sw.Open), nil
+	}
+	return Object{}, errors.New("unknown sweep type '" + sw.Type + "'")
+}
+
+// Returns the scene transform for a transformation matrix made from a uniform scale, rotations, and a translation
+func sceneTransform(m matrix) SceneTransform {
+	v := viewFromMatrix(m)
+	return SceneTransform{Translate: v.move, Rotate: v.rotate, Scale: v.zoom}
+}
+
+// Returns the transform matrix for a scene transform
+func (t SceneTransform) matrix() matrix {
+	m := identityMatrix
+	if t.Scale != 0 && t.Scale != 1 {
+		m = scale(m, t.Scale, t.Scale, t.Scale)
+	}
+	if t.Rotate[0] != 0 {
+		m = rotateAroundX(m, t.Rotate[0])
+	}
+	if t.Rotate[1] != 0 {
+		m = rotateAroundY(m, t.Rotate[1])
+	}
+	if t.Rotate[2] != 0 {
+		m = rotateAroundZ(m, t.Rotate[2])
+	}
+	return translate(m, t.Translate[0], t.Translate[1], t.Translate[2])
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A scene using most of the things an object can have
+const testScene = `{
+  "version": 1,
+  "camera": {"zoom": 2, "spin": [10, 20, 0]},
+  "lights": [{"type": "point", "colour": "white", "intensity": 0.8, "position": [1, 2, 3], "direction": [0, 0, 0]}],
+  "objects": [
+    {
+      "name": "box",
+      "colour": "steelblue",
+      "primitive": {"type": "cube", "size": 2},
+      "surfaceColours": ["red", "", "gold"],
+      "transform": {"translate": [1, 2, 3], "rotate": [30, 40, 50], "scale": 1.5}
+    },
+    {
+      "name": "tile",
+      "colour": "orange",
+      "opacity": 0.5,
+      "texture": "tile.png",
+      "renderMode": "wireframe",
+      "points": [
+        {"x": 0, "y": 0, "z": 0, "colour": {"r": 255, "g": 0, "b": 0}, "uv": {"u": 0, "v": 0}},
+        {"x": 1, "y": 0, "z": 0, "uv": {"u": 1, "v": 0}},
+        {"x": 0, "y": 1, "z": 0, "uv": {"u": 0, "v": 1}}
+      ],
+      "edges": [[0, 1], [1, 2], [2, 0]],
+      "surfaces": [[0, 1, 2]],
+      "transform": {"translate": [0, 0, 0], "rotate": [0, 0, 0]}
+    },
+    {
+      "primitive": {"type": "sphere", "radius": 0.5, "segments": 8, "rings": 4},
+      "edgeMode": "boundary",
+      "transform": {"translate": [-3, 0, 0], "rotate": [0, 0, 0], "scale": 1}
+    }
+  ]
+}`
+
+// Checks a world saved to a scene file loads back the same
+func TestSceneRoundTrip(t *testing.T) {
+	s, err := readScene(strings.NewReader(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := s.objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(sortedNames(world), ","); names != "box,object 3,tile" {
+		t.Fatalf("objects are %s, wanted box, object 3, and tile", names)
+	}
+
+	var buf bytes.Buffer
+	if err := writeScene(&buf, sceneFromWorld(world, s.Camera, s.Lights)); err != nil {
+		t.Fatal(err)
+	}
+	again, err := readScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Version != sceneVersion {
+		t.Errorf("saved version is %d, wanted %d", again.Version, sceneVersion)
+	}
+	if !reflect.DeepEqual(again.Camera, s.Camera) || !reflect.DeepEqual(again.Lights, s.Lights) {
+		t.Errorf("camera and lights are %v and %v, wanted %v and %v", again.Camera, again.Lights, s.Camera, s.Lights)
+	}
+	reloaded, err := again.objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded) != len(world) {
+		t.Fatalf("reloaded %d objects, wanted %d", len(reloaded), len(world))
+	}
+	for name, o := range world {
+		if !reflect.DeepEqual(reloaded[name], o) {
+			t.Errorf("object %s reloaded as\n%+v\nwanted\n%+v", name, reloaded[name], o)
+		}
+	}
+}
+
+func TestReadScene(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // Part of the error message, or "" for no error
+	}{
+		{"empty scene", `{"version": 1}`, ""},
+		{"current version", `{"version": 1, "objects": [{"name": "a", "primitive": {"type": "cube"}}]}`, ""},
+		{"no version", `{"objects": []}`, "has no version"},
+		{"zero version", `{"version": 0}`, "has no version"},
+		{"newer version", `{"version": 2}`, "version 2 is newer"},
+		{"not json", `version: 1`, "scene:"},
+		{"truncated", `{"version": 1, "objects": [`, "scene:"},
+		{"wrong type", `{"version": "1"}`, "scene:"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readScene(strings.NewReader(tc.src))
+			checkError(t, err, tc.want)
+		})
+	}
+}
+
+func TestSceneObjects(t *testing.T) {
+	const (
+		cube     = `"primitive": {"type": "cube"}`
+		triangle = `"points": [{"x": 0, "y": 0, "z": 0}, {"x": 1, "y": 0, "z": 0}, {"x": 0, "y": 1, "z": 0}], "surfaces": [[0, 1, 2]]`
+		plot     = `"plot": {"function": "x * y", "steps": 4}`
+		sweep    = `"sweep": {"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]]}`
+		csg      = `"csg": {"operation": "union", "objects": ["a", "b"]}`
+	)
+	tests := []struct {
+		name    string
+		objects []string // JSON of each object, inside its braces
+		want    string   // Part of the error message, or "" for no error
+	}{
+		{"points", []string{`"name": "a", ` + triangle}, ""},
+		{"primitive", []string{`"name": "a", ` + cube}, ""},
+		{"plot", []string{`"name": "a", ` + plot}, ""},
+		{"sweep", []string{`"name": "a", ` + sweep}, ""},
+		{"unnamed objects", []string{cube, cube}, ""},
+		{"csg", []string{`"name": "a", ` + cube, `"name": "b", "primitive": {"type": "sphere", "radius": 0.5}`, `"name": "c", ` + csg}, ""},
+
+		{"duplicate names", []string{`"name": "a", ` + cube, `"name": "a", ` + triangle}, "more than one object named 'a'"},
+		{"name used by an unnamed object", []string{cube, `"name": "object 1", ` + cube}, "more than one object named 'object 1'"},
+		{"points and primitive", []string{`"name": "a", ` + triangle + `, ` + cube}, "object 'a' can only have one of"},
+		{"primitive and plot", []string{`"name": "a", ` + cube + `, ` + plot}, "can only have one of"},
+		{"sweep and csg", []string{`"name": "a", ` + sweep + `, ` + csg}, "can only have one of"},
+		{"unknown primitive", []string{`"name": "a", "primitive": {"type": "teapot"}`}, "object 'a': unknown primitive type 'teapot'"},
+		{"bad surface", []string{`"name": "a", "points": [{"x": 0, "y": 0, "z": 0}], "surfaces": [[0, 1, 2]]`}, "object 'a':"},
+		{"unknown edge mode", []string{`"name": "a", "edgeMode": "some", ` + cube}, "object 'a':"},
+		{"csg of missing objects", []string{`"name": "c", ` + csg}, "no object named 'a'"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := `{"version": 1, "objects": [{` + strings.Join(tc.objects, `}, {`) + `}]}`
+			s, err := readScene(strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			obs, err := s.objects()
+			checkError(t, err, tc.want)
+			for name, o := range obs {
+				if err := o.Validate(); err != nil {
+					t.Errorf("object %s is invalid: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,61 @@
+//go:build !js
+// +build !js
+
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strings"
 )
 
+// The web page runs the TinyGo build of this package, which needs rebuilding after any change to the Go code
+//go:generate tinygo build -target wasm -no-debug -o docs/wasm.wasm .
+
 const dir = "./docs"
 
 func main() {
+	// Exporting a scene is done instead of starting the web server
+	scenePath := flag.String("scene", "", "Scene file to export, instead of starting the web server")
+	svgPath := flag.String("svg", "", "SVG file to export the scene to")
+	objPath := flag.String("obj", "", "OBJ file to export the scene to.  The colours go in an MTL file of the same name")
+	pngPath := flag.String("png", "", "PNG file to draw the scene to, as the web page would show it")
+	width := flag.Int("width", 800, "Width of the exported SVG or PNG image")
+	height := flag.Int("height", 600, "Height of the exported SVG or PNG image")
+	labels := flag.Bool("labels", false, "Include the object names in the exported SVG image")
+	triangles := flag.Bool("triangulate", false, "Split the surfaces into triangles in the exported OBJ file")
+	flag.Parse()
+	if *scenePath != "" {
+		if *svgPath == "" && *pngPath == "" && *objPath == "" {
+			log.Fatal("Exporting a scene needs -svg, -png, or -obj")
+		}
+		if *width < 1 || *height < 1 {
+			log.Fatal("The image width and height need to be at least 1")
+		}
+		world, err := readSceneFile(*scenePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *svgPath != "" {
+			if err = writeSVGFile(world, *svgPath, *width, *height, *labels); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *pngPath != "" {
+			if err = writePNGFile(world, *pngPath, *width, *height, filepath.Dir(*scenePath)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *objPath != "" {
+			if err = writeOBJFile(world, *objPath, *triangles); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
 	fs := http.FileServer(http.Dir(dir))
 	log.Print("Serving " + dir + " on http://localhost:8080")
 	http.ListenAndServe(":8080", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
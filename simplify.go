@@ -0,0 +1,391 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// How much more the planes keeping the boundary of a mesh in place count for, compared to the planes of its surfaces
+const simplifyBoundaryWeight = 1000
+
+// A quadric error matrix, which measures the sum of the squared distances from a point to a set of planes.  Only the
+// upper half of the symmetric 4x4 matrix is kept: aa, ab, ac, ad, bb, bc, bd, cc, cd, dd
+type quadric [10]float64
+
+// A possible collapse of an edge, joining its two points into one
+type simplifyPair struct {
+	a, b     int
+	cost     float64
+	pos      Point // Where the joined point goes
+	versions [2]int
+}
+
+// The possible collapses, cheapest first
+type simplifyHeap []simplifyPair
+
+// A triangle mesh being simplified
+type simplifyMesh struct {
+	pts      []Point
+	quadrics []quadric
+	faces    [][3]int
+	alive    []bool  // Whether each face is still in the mesh
+	vFaces   [][]int // The faces using each point.  Faces which have since been removed may still be listed
+	parent   []int   // The point each point has been joined into, or itself if it hasn't been
+	versions []int   // Changed each time a point moves, so out of date collapses can be spotted
+	pairs    simplifyHeap
+	numFaces int
+}
+
+// Returns a simplified copy of the object with at most targetFaces surfaces, made by repeatedly collapsing the edge
+// whose removal changes the shape least.  This is the quadric error metric method from "Surface Simplification Using
+// Quadric Error Metrics" by Michael Garland and Paul Heckbert.  If maxError is above zero, simplifying stops early
+// rather than collapse an edge which would move the surface further than about the square root of maxError.  The
+// surfaces of the result are all triangles, and its edges follow the original ones as closely as they can
+func (o Object) Simplify(targetFaces int, maxError float64) Object {
+	if len(o.S) == 0 {
+		return o
+	}
+	tri := triangulateObject(o)
+	if len(tri.S) <= targetFaces {
+		return tri
+	}
+
+	// Set up the mesh, with the quadric of each point summing the planes of the surfaces around it
+	m := simplifyMesh{
+		pts:      append([]Point(nil), tri.P...),
+		quadrics: make([]quadric, len(tri.P)),
+		vFaces:   make([][]int, len(tri.P)),
+		parent:   make([]int, len(tri.P)),
+		versions: make([]int, len(tri.P)),
+	}
+	for i := range m.parent {
+		m.parent[i] = i
+	}
+	sideFaces := make(map[[2]int][]int)
+	for _, s := range tri.S {
+		f := [3]int{s[0], s[1], s[2]}
+		n := len(m.faces)
+		m.faces = append(m.faces, f)
+		m.alive = append(m.alive, true)
+		normal := vecCross(vecSub(m.pts[f[1]], m.pts[f[0]]), vecSub(m.pts[f[2]], m.pts[f[0]]))
+		q := planeQuadric(m.pts[f[0]], vecNormalise(normal), vecLength(normal)/2)
+		for j, v := range f {
+			m.quadrics[v].add(q)
+			m.vFaces[v] = append(m.vFaces[v], n)
+			key := edgeKey(v, f[(j+1)%3])
+			sideFaces[key] = append(sideFaces[key], n)
+		}
+	}
+	m.numFaces = len(m.faces)
+
+	// Sides used by only one surface are the boundary, which is kept in place by planes standing up from it
+	for key, fs := range sideFaces {
+		if len(fs) != 1 {
+			continue
+		}
+		f := m.faces[fs[0]]
+		a, b := m.pts[key[0]], m.pts[key[1]]
+		faceNormal := vecNormalise(vecCross(vecSub(m.pts[f[1]], m.pts[f[0]]), vecSub(m.pts[f[2]], m.pts[f[0]])))
+		side := vecSub(b, a)
+		q := planeQuadric(a, vecNormalise(vecCross(side, faceNormal)), simplifyBoundaryWeight*vecDot(side, side))
+		m.quadrics[key[0]].add(q)
+		m.quadrics[key[1]].add(q)
+	}
+
+	// Collapse the cheapest edges until there are few enough surfaces
+	for key := range sideFaces {
+		m.pairs = append(m.pairs, m.pair(key[0], key[1]))
+	}
+	heap.Init(&m.pairs)
+	for m.numFaces > targetFaces && len(m.pairs) > 0 {
+		p := heap.Pop(&m.pairs).(simplifyPair)
+		if m.parent[p.a] != p.a || m.parent[p.b] != p.b || p.versions != [2]int{m.versions[p.a], m.versions[p.b]} {
+			// One of the points has changed since this collapse was worked out
+			continue
+		}
+		if maxError > 0 && p.cost > maxError {
+			break
+		}
+		m.collapse(p)
+	}
+
+	// Build the simplified object, leaving out points which are no longer used
+	out := Object{C: o.C, A: o.A, M: o.M}
+	index := make([]int, len(m.pts))
+	for i := range index {
+		index[i] = -1
+	}
+	use := func(v int) int {
+		v = m.root(v)
+		if index[v] < 0 {
+			index[v] = len(out.P)
+			out.P = append(out.P, m.pts[v])
+		}
+		return index[v]
+	}
+	var colours []string
+	for i, f := range m.faces {
+		if m.alive[i] {
+			out.S = append(out.S, Surface{use(f[0]), use(f[1]), use(f[2])})
+			colours = append(colours, tri.surfaceColour(i))
+		}
+	}
+	out.SC = trimColours(colours)
+	seen := make(map[[2]int]bool)
+	for _, e := range o.E {
+		if len(e) != 2 || e[0] < 0 || e[1] < 0 || e[0] >= len(m.pts) || e[1] >= len(m.pts) {
+			continue
+		}
+		a, b := use(e[0]), use(e[1])
+		if a != b && !seen[edgeKey(a, b)] {
+			seen[edgeKey(a, b)] = true
+			out.E = append(out.E, Edge{a, b})
+		}
+	}
+	out.Mid = midPoint(out.P)
+	return out
+}
+
+// Simplifies any objects with surfaces so there are at most maxFaces surfaces between them.  Each object keeps a share
+// of the surfaces in proportion to how many it had
+func simplifyObjects(obs map[string]Object, maxFaces int) {
+	total := 0
+	for _, o := range obs {
+		total += len(o.S)
+	}
+	if total <= maxFaces {
+		return
+	}
+	for name, o := range obs {
+		if len(o.S) > 0 {
+			obs[name] = o.Simplify(len(o.S)*maxFaces/total, 0)
+		}
+	}
+}
+
+// Adds a quadric to this one
+func (q *quadric) add(r quadric) {
+	for i := range q {
+		q[i] += r[i]
+	}
+}
+
+// Returns the error of a point against the quadric
+func (q quadric) error(p Point) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x + q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y + q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+// Returns the quadric of the plane through point p facing along the unit vector n, multiplied by weight
+func planeQuadric(p Point, n Point, weight float64) quadric {
+	a, b, c := n.X, n.Y, n.Z
+	d := -vecDot(n, p)
+	return quadric{a * a * weight, a * b * weight, a * c * weight, a * d * weight, b * b * weight, b * c * weight,
+		b * d * weight, c * c * weight, c * d * weight, d * d * weight}
+}
+
+// Joins the second point of a pair into the first, moving it to the pair's position.  The collapse is skipped if it
+// would flip a surface over or join parts of the mesh which only meet at a point
+func (m *simplifyMesh) collapse(p simplifyPair) {
+	a, b := p.a, p.b
+
+	// The points next to both a and b should only be the other corners of the surfaces using both of them.  If there
+	// are more, collapsing would join the mesh to itself
+	shared := 0
+	around := make(map[int]int)
+	for bit, v := range []int{a, b} {
+		for _, fi := range m.vFaces[v] {
+			if !m.alive[fi] {
+				continue
+			}
+			f := m.faces[fi]
+			if v == a && contains3(f, b) {
+				shared++
+			}
+			for _, w := range f {
+				if w != a && w != b {
+					around[w] |= 1 << uint(bit)
+				}
+			}
+		}
+	}
+	common := 0
+	for _, bits := range around {
+		if bits == 3 {
+			common++
+		}
+	}
+	if common != shared {
+		return
+	}
+
+	// Check no surface would be flipped over by moving its corner
+	for _, v := range []int{a, b} {
+		for _, fi := range m.vFaces[v] {
+			f := m.faces[fi]
+			if !m.alive[fi] || (contains3(f, a) && contains3(f, b)) {
+				continue
+			}
+			var before, after [3]Point
+			for j, w := range f {
+				before[j] = m.pts[w]
+				after[j] = m.pts[w]
+				if w == v {
+					after[j] = p.pos
+				}
+			}
+			n1 := vecCross(vecSub(before[1], before[0]), vecSub(before[2], before[0]))
+			n2 := vecCross(vecSub(after[1], after[0]), vecSub(after[2], after[0]))
+			if vecDot(n1, n2) <= 0 {
+				return
+			}
+		}
+	}
+
+	// Move a, then swap b for a in the surfaces using it, removing the ones which collapse
+	var orphans []int
+	colour, uv := m.pts[a].C, m.pts[a].T
+	m.pts[a] = p.pos
+	m.pts[a].C, m.pts[a].T = colour, uv
+	m.quadrics[a].add(m.quadrics[b])
+	m.parent[b] = a
+	m.versions[a]++
+	for _, fi := range m.vFaces[b] {
+		if !m.alive[fi] {
+			continue
+		}
+		f := &m.faces[fi]
+		if contains3(*f, a) {
+			m.alive[fi] = false
+			m.numFaces--
+			for _, w := range f {
+				if w != a && w != b {
+					orphans = append(orphans, w)
+				}
+			}
+			continue
+		}
+		for j := range f {
+			if f[j] == b {
+				f[j] = a
+			}
+		}
+		m.vFaces[a] = append(m.vFaces[a], fi)
+	}
+	m.vFaces[b] = nil
+
+	// Points left without any surfaces, eg the corner of a mesh whose only surface has gone, are joined into a too.
+	// Otherwise they'd be kept for the edges, even if a has moved to the same place
+	for _, w := range orphans {
+		alive := false
+		for _, fi := range m.vFaces[w] {
+			alive = alive || m.alive[fi]
+		}
+		if !alive {
+			m.parent[w] = a
+		}
+	}
+
+	// Tidy the list of surfaces using a, then work out the collapses of its edges again.  The edges of the points next
+	// to a are worked out again too, as moving a may have changed whether collapsing them would flip a surface over
+	kept := m.vFaces[a][:0]
+	neighbours := make(map[int]bool)
+	for _, fi := range m.vFaces[a] {
+		if !m.alive[fi] {
+			continue
+		}
+		kept = append(kept, fi)
+		for _, w := range m.faces[fi] {
+			if w != a {
+				neighbours[w] = true
+			}
+		}
+	}
+	m.vFaces[a] = kept
+	sides := make(map[[2]int]bool)
+	for w := range neighbours {
+		for _, fi := range m.vFaces[w] {
+			if !m.alive[fi] {
+				continue
+			}
+			for _, x := range m.faces[fi] {
+				if key := edgeKey(w, x); x != w && !sides[key] {
+					sides[key] = true
+					heap.Push(&m.pairs, m.pair(key[0], key[1]))
+				}
+			}
+		}
+	}
+}
+
+// Works out the cost of collapsing the edge between two points, and where the joined point would go
+func (m *simplifyMesh) pair(a int, b int) simplifyPair {
+	var q quadric
+	q.add(m.quadrics[a])
+	q.add(m.quadrics[b])
+	p := simplifyPair{a: a, b: b, versions: [2]int{m.versions[a], m.versions[b]}}
+
+	// The best place is where the quadric's gradient is zero, if that can be solved for
+	det := q[0]*(q[4]*q[7]-q[5]*q[5]) - q[1]*(q[1]*q[7]-q[5]*q[2]) + q[2]*(q[1]*q[5]-q[4]*q[2])
+	if math.Abs(det) > 1e-12 {
+		inv := [9]float64{
+			q[4]*q[7] - q[5]*q[5], q[2]*q[5] - q[1]*q[7], q[1]*q[5] - q[2]*q[4],
+			q[2]*q[5] - q[1]*q[7], q[0]*q[7] - q[2]*q[2], q[1]*q[2] - q[0]*q[5],
+			q[1]*q[5] - q[2]*q[4], q[1]*q[2] - q[0]*q[5], q[0]*q[4] - q[1]*q[1],
+		}
+		p.pos = Point{
+			X: -(inv[0]*q[3] + inv[1]*q[6] + inv[2]*q[8]) / det,
+			Y: -(inv[3]*q[3] + inv[4]*q[6] + inv[5]*q[8]) / det,
+			Z: -(inv[6]*q[3] + inv[7]*q[6] + inv[8]*q[8]) / det,
+		}
+		p.cost = q.error(p.pos)
+		return p
+	}
+
+	// Otherwise use whichever of the ends or middle of the edge is best
+	pa, pb := m.pts[a], m.pts[b]
+	p.cost = math.Inf(1)
+	for _, c := range []Point{pa, pb, vecScale(vecAdd(pa, pb), 0.5)} {
+		if e := q.error(c); e < p.cost {
+			p.cost = e
+			p.pos = c
+		}
+	}
+	return p
+}
+
+// Returns the point a point has been joined into, following any later joins too
+func (m *simplifyMesh) root(v int) int {
+	for m.parent[v] != v {
+		v = m.parent[v]
+	}
+	return v
+}
+
+// Returns whether a triangle uses a point
+func contains3(f [3]int, v int) bool {
+	return f[0] == v || f[1] == v || f[2] == v
+}
+
+func (h simplifyHeap) Len() int {
+	return len(h)
+}
+
+func (h simplifyHeap) Less(i, j int) bool {
+	return h[i].cost < h[j].cost
+}
+
+func (h simplifyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *simplifyHeap) Push(x interface{}) {
+	*h = append(*h, x.(simplifyPair))
+}
+
+func (h *simplifyHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		name      string
+		ob        Object
+		target    int
+		maxError  float64
+		wantFaces int     // Exact number of surfaces wanted, or 0 to only check there are at most target
+		solid     bool    // Whether the object is a closed solid, which should stay closed
+		tolerance float64 // How far the volume (or area of flat objects) can change, as a fraction
+	}{
+		{"sphere", sphere(2, 24, 12), 100, 0, 0, true, 0.1},
+		{"sphere down a lot", sphere(2, 24, 12), 20, 0, 0, true, 0.5},
+		{"torus", torus(2, 0.5, 24, 12), 150, 0, 0, true, 0.1},
+		{"cube", cube(2), 12, 0, 12, true, 1e-9},
+		{"cube can't go lower without changing shape", cube(2), 4, 1e-6, 12, true, 1e-9},
+		{"flat grid", plane(4, 8), 2, 0, 2, false, 1e-9},
+		{"flat grid within error", plane(4, 8), 20, 1e-6, 0, false, 1e-9},
+		{"sphere within error", sphere(2, 24, 12), 20, 1e-9, len(triangulateObject(sphere(2, 24, 12)).S), true, 1e-9},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := triangulateObject(tc.ob)
+			o := tc.ob.Simplify(tc.target, tc.maxError)
+			if err := o.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.wantFaces > 0 && len(o.S) != tc.wantFaces:
+				t.Errorf("got %d surfaces, wanted %d", len(o.S), tc.wantFaces)
+			case tc.wantFaces == 0 && len(o.S) > tc.target:
+				t.Errorf("got %d surfaces, wanted at most %d", len(o.S), tc.target)
+			}
+			for i, s := range o.S {
+				if len(s) != 3 {
+					t.Errorf("surface %d has %d points, wanted a triangle", i, len(s))
+				}
+			}
+
+			var got, want float64
+			if tc.solid {
+				got, want = checkSolid(t, o), meshVolume(before)
+			} else {
+				got, want = meshArea(o), meshArea(before)
+
+				// The outside should stay where it was
+				perimeter := 0.0
+				for _, e := range boundaryEdges(o.S) {
+					perimeter += vecLength(vecSub(o.P[e[0]], o.P[e[1]]))
+				}
+				if !near(perimeter, 16, 1e-9) {
+					t.Errorf("flat grid's outside is %v long, wanted 16", perimeter)
+				}
+			}
+			if math.Abs(got-want) > tc.tolerance*want {
+				t.Errorf("size changed from %v to %v", want, got)
+			}
+		})
+	}
+
+	// Objects without surfaces are left alone
+	line := Object{P: []Point{{}, {X: 1}}, E: []Edge{{0, 1}}}
+	if o := line.Simplify(1, 0); len(o.P) != 2 || len(o.E) != 1 {
+		t.Errorf("object without surfaces changed to %v", o)
+	}
+}
+
+func TestSimplifyObjects(t *testing.T) {
+	obs := map[string]Object{"big": sphere(1, 24, 12), "small": sphere(1, 12, 6), "cube": cube(2)}
+	total := 0
+	for _, o := range obs {
+		total += len(o.S)
+	}
+	simplifyObjects(obs, total/2)
+	total = 0
+	for name, o := range obs {
+		total += len(o.S)
+		checkSolid(t, o)
+		if name != "cube" && len(o.S) < 10 {
+			t.Errorf("%s only has %d surfaces left", name, len(o.S))
+		}
+	}
+	if total > 24*12+6*12 {
+		t.Errorf("objects have %d surfaces between them, wanted at most %d", total, 24*12+6*12)
+	}
+}
+
+// Returns the total area of an object's surfaces
+func meshArea(o Object) (area float64) {
+	for _, s := range o.S {
+		for i := 1; i+1 < len(s); i++ {
+			area += vecLength(vecCross(vecSub(o.P[s[i]], o.P[s[0]]), vecSub(o.P[s[i+1]], o.P[s[0]]))) / 2
+		}
+	}
+	return area
+}
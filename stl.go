@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Surfaces meeting at more than this angle (in degrees) have the edge between them drawn, when loading STL files
+const stlCreaseAngle = defaultCreaseAngle
+
+// Welds the duplicate vertices of an STL file together, so each triangle's corners become shared points
+type stlBuilder struct {
+	ob     Object
+	points map[[3]float64]int
+}
+
+// Parses an ASCII or binary STL file into an object.  Duplicate vertices are welded together into shared points.  If
+// creaseAngle is above zero, only the feature edges between surfaces meeting at more than that many degrees are
+// included, otherwise every triangle edge is
+func parseSTL(data []byte, creaseAngle float64) (Object, error) {
+	b := stlBuilder{points: make(map[[3]float64]int)}
+	var err error
+	if isBinarySTL(data) {
+		err = b.readBinary(data)
+	} else {
+		err = b.readASCII(data)
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	if len(b.ob.S) == 0 {
+		return Object{}, errors.New("stl: no triangles found")
+	}
+
+	b.ob.C = defaultColour
+	if creaseAngle > 0 {
+		b.ob.E = featureEdges(b.ob.P, b.ob.S, creaseAngle)
+	} else {
+		b.ob.E = surfaceEdges(b.ob.S)
+	}
+	b.ob.Mid = midPoint(b.ob.P)
+	if err := b.ob.Validate(); err != nil {
+		return Object{}, errors.New("stl: " + err.Error())
+	}
+	return b.ob, nil
+}
+
+// Reports whether STL data is in the binary format.  ASCII files start with "solid", but so do some binary files, so
+// the size of the data is checked against the triangle count in the binary header first
+func isBinarySTL(data []byte) bool {
+	if len(data) >= 84 {
+		numTris := binary.LittleEndian.Uint32(data[80:84])
+		if uint64(len(data)) == 84+(uint64(numTris)*50) {
+			return true
+		}
+	}
+	return !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid"))
+}
+
+// Adds a triangle to the object, skipping any which have collapsed to a line or point after welding
+func (b *stlBuilder) addTriangle(corners [3][3]float64) {
+	var s Surface
+	for _, c := range corners {
+		n, ok := b.points[c]
+		if !ok {
+			n = len(b.ob.P)
+			b.ob.P = append(b.ob.P, Point{X: c[0], Y: c[1], Z: c[2]})
+			b.points[c] = n
+		}
+		s = append(s, n)
+	}
+	if s[0] == s[1] || s[1] == s[2] || s[2] == s[0] {
+		return
+	}
+	b.ob.S = append(b.ob.S, s)
+}
+
+// Reads the facets of an ASCII STL file.  The facet normals are ignored, as they're often wrong
+func (b *stlBuilder) readASCII(data []byte) error {
+	var corners [3][3]float64
+	numCorners := 0
+	lineNum := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "outer":
+			numCorners = 0
+		case "vertex":
+			if len(fields) < 4 || numCorners > 2 {
+				return errors.New("stl: line " + strconv.Itoa(lineNum) + ": bad vertex")
+			}
+			for i := 0; i < 3; i++ {
+				v, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return errors.New("stl: line " + strconv.Itoa(lineNum) + ": bad vertex value")
+				}
+				corners[numCorners][i] = v
+			}
+			numCorners++
+		case "endloop":
+			if numCorners != 3 {
+				return errors.New("stl: line " + strconv.Itoa(lineNum) + ": facet doesn't have 3 vertices")
+			}
+			b.addTriangle(corners)
+		}
+	}
+	return scanner.Err()
+}
+
+// Reads the triangles of a binary STL file.  Each one is 50 bytes long: a normal, three vertices, then a 2 byte
+// attribute count
+func (b *stlBuilder) readBinary(data []byte) error {
+	if len(data) < 84 {
+		return errors.New("stl: file too short")
+	}
+	// The size is worked out as a uint64, as it can be too big for an int on 32 bit systems
+	numTris := binary.LittleEndian.Uint32(data[80:84])
+	if uint64(len(data)) < 84+(uint64(numTris)*50) {
+		return errors.New("stl: file is truncated, expected " + strconv.FormatUint(uint64(numTris), 10) + " triangles")
+	}
+	var corners [3][3]float64
+	for t := 0; t < int(numTris); t++ {
+		tri := data[84+(t*50):]
+		for c := 0; c < 3; c++ {
+			for i := 0; i < 3; i++ {
+				bits := binary.LittleEndian.Uint32(tri[12+(c*12)+(i*4):])
+				corners[c][i] = float64(math.Float32frombits(bits))
+			}
+		}
+		b.addTriangle(corners)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseSTL(t *testing.T) {
+	tris := testCubeTriangles(t)
+	ascii := testASCIISTL(tris)
+	bin := testBinarySTL("binary cube", tris)
+
+	// Binary files can start with "solid" too
+	solidBin := testBinarySTL("solid cube", tris)
+
+	// Triangles whose corners weld to the same points are left out
+	extra := append(append([][3][3]float64{}, tris...), [3][3]float64{{1, 1, 1}, {1, 1, 1}, {-1, 1, 1}}, [3][3]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		crease float64
+		want   string // Part of the error message, or "" for no error
+		edges  int    // Edges the cube should have
+	}{
+		{"ascii", []byte(ascii), 0, "", 18},
+		{"ascii with crlf", []byte(strings.Replace(ascii, "\n", "\r\n", -1)), 0, "", 18},
+		{"binary", bin, 0, "", 18},
+		{"binary starting with solid", solidBin, 0, "", 18},
+		{"feature edges", bin, 30, "", 12},
+		{"degenerate triangles", testBinarySTL("", extra), 0, "", 18},
+
+		{"empty", nil, 0, "too short", 0},
+		{"ascii without facets", []byte("solid empty\nendsolid empty\n"), 0, "no triangles", 0},
+		{"ascii extra vertex", []byte("solid x\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nvertex 0 1 0\nvertex 0 0 1\nendloop\n"), 0, "line 6: bad vertex", 0},
+		{"ascii short vertex", []byte("solid x\nouter loop\nvertex 0 0\n"), 0, "line 3: bad vertex", 0},
+		{"ascii two corners", []byte("solid x\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\n"), 0, "3 vertices", 0},
+		{"binary truncated", bin[:len(bin)-10], 0, "truncated, expected 12 triangles", 0},
+		{"binary header only", bin[:84], 0, "truncated, expected 12 triangles", 0},
+		{"binary nan", testBinarySTL("", [][3][3]float64{{{math.NaN(), 0, 0}, {1, 0, 0}, {0, 1, 0}}}), 0, "no valid position", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := parseSTL(tc.data, tc.crease)
+			checkError(t, err, tc.want)
+			if err != nil {
+				return
+			}
+			if len(o.P) != 8 || len(o.S) != 12 {
+				t.Errorf("cube has %d points and %d surfaces, wanted 8 and 12", len(o.P), len(o.S))
+			}
+			if len(o.E) != tc.edges {
+				t.Errorf("cube has %d edges, wanted %d", len(o.E), tc.edges)
+			}
+			if v := checkSolid(t, o); !near(v, 8, 1e-6) {
+				t.Errorf("volume is %v, wanted 8", v)
+			}
+		})
+	}
+
+	// Files cut off part way through either fail to load, or give a valid object from the whole facets before the cut
+	for _, data := range [][]byte{[]byte(ascii), bin} {
+		for n := 0; n < len(data); n += 7 {
+			if o, err := parseSTL(data[:n], 0); err == nil {
+				if err := o.Validate(); err != nil {
+					t.Errorf("file cut off after %d bytes gave an invalid object: %v", n, err)
+				}
+			}
+		}
+	}
+}
+
+// Returns the corners of the triangles of the test cube
+func testCubeTriangles(t *testing.T) (tris [][3][3]float64) {
+	t.Helper()
+	obs, err := parseOBJ(strings.NewReader(testCubeOBJ), "cube", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cube := obs["cube"]
+	for _, s := range cube.S {
+		for i := 1; i+1 < len(s); i++ {
+			var tri [3][3]float64
+			for c, n := range []int{s[0], s[i], s[i+1]} {
+				tri[c] = [3]float64{cube.P[n].X, cube.P[n].Y, cube.P[n].Z}
+			}
+			tris = append(tris, tri)
+		}
+	}
+	return tris
+}
+
+func testASCIISTL(tris [][3][3]float64) string {
+	var b strings.Builder
+	b.WriteString("solid test\n")
+	for _, tri := range tris {
+		b.WriteString("  facet normal 0 0 0\n    outer loop\n")
+		for _, c := range tri {
+			b.WriteString("      vertex")
+			for _, v := range c {
+				b.WriteString(" " + strconv.FormatFloat(v, 'g', -1, 64))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    endloop\n  endfacet\n")
+	}
+	b.WriteString("endsolid test\n")
+	return b.String()
+}
+
+func testBinarySTL(header string, tris [][3][3]float64) []byte {
+	data := make([]byte, 84+(50*len(tris)))
+	copy(data, header)
+	binary.LittleEndian.PutUint32(data[80:], uint32(len(tris)))
+	for t, tri := range tris {
+		for c, corner := range tri {
+			for i, v := range corner {
+				binary.LittleEndian.PutUint32(data[84+(t*50)+12+(c*12)+(i*4):], math.Float32bits(float32(v)))
+			}
+		}
+	}
+	return data
+}
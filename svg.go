@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Writes drawing commands out as an SVG document of the given size.  Gradient and texture fills are written as a
+// linearGradient or pattern just before the surface using them, with textures linking to their image
+func writeSVG(w io.Writer, cmds []drawCmd, width float64, height float64) error {
+	gradients, patterns := 0, 0
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	bw.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1"` +
+		` width="` + svgNum(width) + `" height="` + svgNum(height) + `" viewBox="0 0 ` + svgNum(width) + " " +
+		svgNum(height) + `">` + "\n")
+	bw.WriteString(`<rect width="100%" height="100%" fill="white"/>` + "\n")
+	for _, c := range cmds {
+		colour := html.EscapeString(c.colour)
+		opacity := ""
+		if c.alpha != 0 {
+			opacity = ` opacity="` + svgNum(c.alpha) + `"`
+		}
+		switch c.kind {
+		case DRAW_GRID, DRAW_EDGE:
+			bw.WriteString(`<line x1="` + svgNum(c.pts[0].X) + `" y1="` + svgNum(c.pts[0].Y) + `" x2="` + svgNum(c.pts[1].X) +
+				`" y2="` + svgNum(c.pts[1].Y) + `" stroke="` + colour + `" stroke-width="1"` + opacity + `/>` + "\n")
+		case DRAW_SURFACE:
+			if g := c.gradient; g != nil {
+				gradients++
+				id := "gradient" + strconv.Itoa(gradients)
+				bw.WriteString(`<linearGradient id="` + id + `" gradientUnits="userSpaceOnUse" x1="` + svgNum(g.from.X) +
+					`" y1="` + svgNum(g.from.Y) + `" x2="` + svgNum(g.to.X) + `" y2="` + svgNum(g.to.Y) + `">` +
+					`<stop offset="0" stop-color="` + html.EscapeString(g.fromColour) + `"/>` +
+					`<stop offset="1" stop-color="` + html.EscapeString(g.toColour) + `"/></linearGradient>` + "\n")
+				colour = "url(#" + id + ")"
+			}
+			if t := c.texture; t != nil {
+				// The pattern is one unit across, the same as the image, then placed by the texture's transform
+				patterns++
+				id := "texture" + strconv.Itoa(patterns)
+				m := t.transform
+				bw.WriteString(`<pattern id="` + id + `" patternUnits="userSpaceOnUse" width="1" height="1" patternTransform="matrix(` +
+					svgNum(m[0]) + " " + svgNum(m[1]) + " " + svgNum(m[2]) + " " + svgNum(m[3]) + " " + svgNum(m[4]) + " " +
+					svgNum(m[5]) + `)"><image xlink:href="` + html.EscapeString(t.image) +
+					`" width="1" height="1" preserveAspectRatio="none"/></pattern>` + "\n")
+				colour = "url(#" + id + ")"
+			}
+			bw.WriteString(`<polygon points="`)
+			for i, p := range c.pts {
+				if i > 0 {
+					bw.WriteString(" ")
+				}
+				bw.WriteString(svgNum(p.X) + "," + svgNum(p.Y))
+			}
+			bw.WriteString(`" fill="` + colour + `"` + opacity + `/>` + "\n")
+		case DRAW_POINT:
+			bw.WriteString(`<circle cx="` + svgNum(c.pts[0].X) + `" cy="` + svgNum(c.pts[0].Y) + `" r="` + svgNum(c.radius) +
+				`" fill="` + colour + `"` + opacity + `/>` + "\n")
+		case DRAW_LABEL:
+			bw.WriteString(`<text x="` + svgNum(c.pts[0].X) + `" y="` + svgNum(c.pts[0].Y) + `" fill="` + colour +
+				`" font-family="sans-serif" font-size="12">` + html.EscapeString(c.text) + `</text>` + "\n")
+		}
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// Formats a number for an SVG attribute, to two decimal places
+func svgNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
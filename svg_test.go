@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Checks the SVG written for each kind of drawing command parses as XML, with escaped text and linked textures
+func TestWriteSVG(t *testing.T) {
+	tri := []screenPoint{{10, 10}, {50, 10}, {30, 40}}
+	cmds := []drawCmd{
+		{kind: DRAW_GRID, colour: "#ddd", pts: []screenPoint{{0, 0}, {100, 0}}},
+		{kind: DRAW_SURFACE, colour: "red", alpha: 0.5, pts: tri},
+		{kind: DRAW_SURFACE, pts: tri, gradient: &drawGradient{screenPoint{10, 10}, screenPoint{30, 40}, "red", "blue"}},
+		{kind: DRAW_SURFACE, pts: tri, texture: &drawTexture{`tiles "a&b".png`, [6]float64{40, 0, 20, 30, 10, 10}}},
+		{kind: DRAW_EDGE, colour: "black", pts: []screenPoint{{10, 10}, {50, 10}}},
+		{kind: DRAW_POINT, colour: "green", radius: 3, pts: []screenPoint{{30, 40}}},
+		{kind: DRAW_LABEL, colour: "black", text: "x < 1 & y > 2", pts: []screenPoint{{5, 95}}},
+	}
+	var buf bytes.Buffer
+	if err := writeSVG(&buf, cmds, 100, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	const xlink = "http://www.w3.org/1999/xlink"
+	counts := make(map[string]int)
+	var href, text string
+	d := xml.NewDecoder(&buf)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("svg isn't well formed: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			counts[tok.Name.Local]++
+			for _, a := range tok.Attr {
+				if tok.Name.Local == "image" && a.Name.Space == xlink && a.Name.Local == "href" {
+					href = a.Value
+				}
+			}
+		case xml.CharData:
+			text += strings.TrimSpace(string(tok))
+		}
+	}
+
+	want := map[string]int{"svg": 1, "rect": 1, "line": 2, "polygon": 3, "linearGradient": 1, "stop": 2, "pattern": 1,
+		"image": 1, "circle": 1, "text": 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("%d %s elements, wanted %d", counts[name], name, n)
+		}
+	}
+	if href != `tiles "a&b".png` {
+		t.Errorf("image links to %q, wanted the texture with xlink:href", href)
+	}
+	if text != "x < 1 & y > 2" {
+		t.Errorf("label text is %q", text)
+	}
+}
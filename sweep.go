@@ -0,0 +1,297 @@
+package main
+
+import "math"
+
+const (
+	// Points closer than this to the axis of a lathe are treated as being on it
+	latheAxisTolerance = 1e-9
+
+	// Paths bending by more than this (the cosine of half the angle turned) aren't mitred, as the polygon would need
+	// stretching too far
+	minMitreCos = 0.25
+)
+
+// Returns the solid made by revolving a profile around the Y axis, with segments slices around it.  The X of each
+// profile point is its distance from the axis and Y its height.  The surfaces face outwards whichever way the profile
+// goes.  Profile points on the axis become a single point.  If the profile's last point is the same as its first the
+// solid joins up with itself, eg for a torus.  Otherwise, if caps is true, ends of the profile which aren't on the axis
+// are closed with flat surfaces
+func lathe(profile []Point, segments int, caps bool) Object {
+	if segments < minSegments {
+		segments = minSegments
+	}
+
+	// Closed off along the axis, the profile goes anticlockwise when it goes up the outside of the solid.  Profiles
+	// going the other way have their surfaces turned around
+	outline := []Point{{Y: profile[len(profile)-1].Y}, {Y: profile[0].Y}}
+	outline = append(outline, profile...)
+	flip := polygonArea(outline) < 0
+	closed := len(profile) > 2 && vecLength(vecSub(profile[0], profile[len(profile)-1])) < latheAxisTolerance
+	if closed {
+		profile = profile[:len(profile)-1]
+	}
+
+	// Build a ring of points for each profile point, or a single point for those on the axis
+	var pts []Point
+	first := make([]int, len(profile)) // Index of the first point of each ring
+	onAxis := make([]bool, len(profile))
+	for i, p := range profile {
+		first[i] = len(pts)
+		if math.Abs(p.X) < latheAxisTolerance {
+			onAxis[i] = true
+			pts = append(pts, Point{Y: p.Y, C: p.C})
+			continue
+		}
+		ring := primitiveRing(p.X, p.Y, segments)
+		for k := range ring {
+			ring[k].C = p.C
+		}
+		pts = append(pts, ring...)
+	}
+	ringPoint := func(i int, k int) int {
+		if onAxis[i] {
+			return first[i]
+		}
+		return first[i] + (k % segments)
+	}
+
+	// Join each ring to the next.  Where one end is on the axis the joins are triangles
+	var surfaces []Surface
+	joins := len(profile) - 1
+	if closed {
+		joins = len(profile)
+	}
+	for i := 0; i < joins; i++ {
+		j := (i + 1) % len(profile)
+		if onAxis[i] && onAxis[j] {
+			continue
+		}
+		for k := 0; k < segments; k++ {
+			var s Surface
+			for _, n := range []int{ringPoint(i, k), ringPoint(i, k+1), ringPoint(j, k+1), ringPoint(j, k)} {
+				if len(s) == 0 || (n != s[len(s)-1] && n != s[0]) {
+					s = append(s, n)
+				}
+			}
+			if flip {
+				reverseInts(s)
+			}
+			surfaces = append(surfaces, s)
+		}
+	}
+	edges := surfaceEdges(surfaces)
+
+	// Close off the ends, facing the opposite way to the sides joined to them.  The caps are circles, so their outlines
+	// are already edges
+	if caps && !closed && len(profile) > 1 {
+		last := len(profile) - 1
+		if !onAxis[0] {
+			surfaces = append(surfaces, capSurfaces(pts[first[0]:first[0]+segments], first[0], flip)...)
+		}
+		if !onAxis[last] {
+			surfaces = append(surfaces, capSurfaces(pts[first[last]:first[last]+segments], first[last], !flip)...)
+		}
+	}
+	return Object{C: defaultColour, P: pts, E: edges, S: surfaces, Mid: midPoint(pts)}
+}
+
+// Returns the solid made by moving a polygon along a direction.  The polygon is given by the X and Y of its points,
+// and can be concave, with holes through it.  The sides face outwards, and if caps is true, both ends are closed with
+// flat surfaces
+func extrude(polygon []Point, holes [][]Point, direction Point, caps bool) Object {
+	// The polygon needs to go anticlockwise when looking back down the direction, for the sides to face outwards
+	loops := profileLoops(polygon, holes, direction.Z >= 0)
+	var poly []Point
+	for _, l := range loops {
+		poly = append(poly, l...)
+	}
+	end := make([]Point, len(poly))
+	for i, p := range poly {
+		end[i] = vecAdd(p, direction)
+		end[i].C = p.C
+	}
+	return sweepRings(loops, [][]Point{poly, end}, caps, false)
+}
+
+// Returns the solid made by moving a polygon along a path of points, keeping it at right angles to the path as it
+// turns, with mitred corners.  The polygon is given by the X and Y of its points, and can be concave, with holes
+// through it.  If the path's
+// last point is the same as its first the solid joins up with itself, eg for a ring.  Otherwise, if caps is true,
+// both ends are closed with flat surfaces
+func extrudePath(polygon []Point, holes [][]Point, path []Point, caps bool) Object {
+	closed := len(path) > 2 && vecLength(vecSub(path[0], path[len(path)-1])) < latheAxisTolerance
+	if closed {
+		path = path[:len(path)-1]
+	}
+	if len(path) < 2 {
+		return Object{C: defaultColour}
+	}
+	loops := profileLoops(polygon, holes, true)
+	var poly []Point
+	for _, l := range loops {
+		poly = append(poly, l...)
+	}
+
+	// The direction of the path at each point, averaging the lines either side of it.  Where the path bends the polygon
+	// is stretched across the bend, so the sides keep their width going round it
+	tangents := make([]Point, len(path))
+	bends := make([]Point, len(path))
+	stretches := make([]float64, len(path))
+	for i := range path {
+		prev, next := i-1, i+1
+		if closed {
+			prev, next = (i+len(path)-1)%len(path), (i+1)%len(path)
+		} else if prev < 0 {
+			prev = 0
+		} else if next >= len(path) {
+			next = len(path) - 1
+		}
+		in := vecNormalise(vecSub(path[i], path[prev]))
+		out := vecNormalise(vecSub(path[next], path[i]))
+		tangents[i] = vecNormalise(vecAdd(in, out))
+		if vecLength(tangents[i]) == 0 {
+			tangents[i] = out
+		}
+		stretches[i] = 1
+		if c := vecDot(tangents[i], out); vecLength(in) > 0 && c > minMitreCos {
+			bends[i] = vecNormalise(vecSub(in, out))
+			stretches[i] = 1 / c
+		}
+	}
+
+	// Carry a frame along the path, turning it as little as possible at each point so the polygon doesn't twist.  The
+	// polygon's X follows the frame's normal, and its Y the binormal
+	normal := vecCross(tangents[0], Point{Y: 1})
+	if vecLength(normal) < 1e-6 {
+		normal = vecCross(tangents[0], Point{X: 1})
+	}
+	normal = vecNormalise(normal)
+	var rings [][]Point
+	for i, p := range path {
+		t := tangents[i]
+		if i > 0 {
+			normal = rotationMinimisingNormal(path[i-1], p, tangents[i-1], t, normal)
+		}
+		binormal := vecCross(t, normal)
+		ring := make([]Point, len(poly))
+		for k, q := range poly {
+			offset := vecAdd(vecScale(normal, q.X), vecScale(binormal, q.Y))
+			offset = vecAdd(offset, vecScale(bends[i], vecDot(offset, bends[i])*(stretches[i]-1)))
+			ring[k] = vecAdd(p, offset)
+			ring[k].C = q.C
+		}
+		rings = append(rings, ring)
+	}
+	return sweepRings(loops, rings, caps && !closed, closed)
+}
+
+// Returns the flat surfaces closing a ring of points which starts at index first in an object.  The ring is
+// triangulated looking down the Y axis, and the surfaces face up if up is true, otherwise down
+func capSurfaces(ring []Point, first int, up bool) (surfaces []Surface) {
+	flat := make([]Point, len(ring))
+	for i, p := range ring {
+		flat[i] = Point{X: p.X, Y: -p.Z}
+	}
+	for _, t := range triangulate(flat) {
+		s := Surface{first + t[0], first + t[1], first + t[2]}
+		if (polygonArea(flat) > 0) != up {
+			s[0], s[2] = s[2], s[0]
+		}
+		surfaces = append(surfaces, s)
+	}
+	return surfaces
+}
+
+// Returns the normal of a frame carried from point a to b along a path, where ta and tb are the path's directions at
+// them.  This uses the double reflection method, which unlike projecting the normal onto the new tangent's plane still
+// works where the path turns sharply: the frame is reflected in the plane halfway between the points, then in the
+// plane which lines its reflected tangent up with tb
+func rotationMinimisingNormal(a Point, b Point, ta Point, tb Point, normal Point) Point {
+	reflect := func(v Point, n Point, c float64) Point {
+		return vecSub(v, vecScale(n, 2*vecDot(n, v)/c))
+	}
+	v1 := vecSub(b, a)
+	c1 := vecDot(v1, v1)
+	if c1 == 0 {
+		return normal
+	}
+	normal, ta = reflect(normal, v1, c1), reflect(ta, v1, c1)
+	v2 := vecSub(tb, ta)
+	if c2 := vecDot(v2, v2); c2 > 1e-12 {
+		normal = reflect(normal, v2, c2)
+	}
+	return vecNormalise(normal)
+}
+
+// Returns copies of a polygon and its holes, flattened onto the X-Y plane.  The polygon goes anticlockwise if
+// anticlockwise is true, otherwise clockwise, and the holes go the other way.  Holes with less than 3 points are left
+// out
+func profileLoops(polygon []Point, holes [][]Point, anticlockwise bool) [][]Point {
+	var loops [][]Point
+	for i, l := range append([][]Point{polygon}, holes...) {
+		if i > 0 && len(l) < 3 {
+			continue
+		}
+		loop := make([]Point, len(l))
+		for k, p := range l {
+			loop[k] = Point{X: p.X, Y: p.Y, C: p.C}
+		}
+		if (polygonArea(loop) > 0) != (anticlockwise == (i == 0)) {
+			reversePoints(loop)
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
+
+// Reverses the order of a slice of points
+func reversePoints(pts []Point) {
+	for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+		pts[i], pts[j] = pts[j], pts[i]
+	}
+}
+
+// Reverses the order of a slice of ints
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// Returns the object joining each ring of points to the next, where each ring is the points of a polygon and its holes
+// (the loops) moved through space.  The polygon goes anticlockwise when looking back along the sweep, and the holes
+// clockwise.  If closed is true the last ring joins back to the first, otherwise if caps is true both ends are closed
+// with triangulated surfaces
+func sweepRings(loops [][]Point, rings [][]Point, caps bool, closed bool) Object {
+	n := len(rings[0])
+	var pts []Point
+	for _, ring := range rings {
+		pts = append(pts, ring...)
+	}
+	var surfaces []Surface
+	joins := len(rings) - 1
+	if closed {
+		joins = len(rings)
+	}
+	for r := 0; r < joins; r++ {
+		a, b := r*n, ((r+1)%len(rings))*n
+		for _, l := range loops {
+			for k := range l {
+				next := (k + 1) % len(l)
+				surfaces = append(surfaces, Surface{a + k, a + next, b + next, b + k})
+			}
+			a, b = a+len(l), b+len(l)
+		}
+	}
+	edges := surfaceEdges(surfaces)
+
+	// The caps are triangulated using the polygon, which is the same shape as every ring.  Only their outlines are
+	// edges, and they're already there from the sides
+	if caps && n >= 3 {
+		last := (len(rings) - 1) * n
+		for _, t := range triangulateHoles(loops[0], loops[1:]...) {
+			surfaces = append(surfaces, Surface{t[2], t[1], t[0]}, Surface{last + t[0], last + t[1], last + t[2]})
+		}
+	}
+	return Object{C: defaultColour, P: pts, E: edges, S: surfaces, Mid: midPoint(pts)}
+}
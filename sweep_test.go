@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLathe(t *testing.T) {
+	const segments = 64
+	// The volume of the polygonal solid, which is a little less than the round one
+	ringArea := float64(segments) / 2 * math.Sin(2*math.Pi/segments)
+	tests := []struct {
+		name    string
+		profile []Point
+		caps    bool
+		volume  float64
+	}{
+		{"cylinder going up", []Point{{X: 1, Y: -1}, {X: 1, Y: 1}}, true, 2 * ringArea},
+		{"cylinder going down", []Point{{X: 1, Y: 1}, {X: 1, Y: -1}}, true, 2 * ringArea},
+		{"cone going up", []Point{{X: 1}, {Y: 3}}, true, ringArea},
+		{"cone going down", []Point{{Y: 3}, {X: 1}}, true, ringArea},
+		{"closed going up", []Point{{Y: -1}, {X: 2, Y: -1}, {X: 2, Y: 1}, {Y: 1}}, false, 8 * ringArea},
+		{"closed going down", []Point{{Y: 1}, {X: 2, Y: 1}, {X: 2, Y: -1}, {Y: -1}}, false, 8 * ringArea},
+		{"tube going down", []Point{{X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: -1}, {X: 2, Y: -1}, {X: 2, Y: 1}}, false, 6 * ringArea},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := lathe(tc.profile, segments, tc.caps)
+			if v := checkSolid(t, o); !near(v, tc.volume, 1e-9) {
+				t.Errorf("volume is %v, wanted %v", v, tc.volume)
+			}
+		})
+	}
+
+	// Without caps, a profile off the axis is left open, so the sides round both ends aren't shared
+	sides := make(map[[2]int]bool)
+	for _, s := range lathe([]Point{{X: 1, Y: -1}, {X: 1, Y: 1}}, 8, false).S {
+		for i := range s {
+			sides[[2]int{s[i], s[(i+1)%len(s)]}] = true
+		}
+	}
+	unshared := 0
+	for side := range sides {
+		if !sides[[2]int{side[1], side[0]}] {
+			unshared++
+		}
+	}
+	if unshared != 16 {
+		t.Errorf("uncapped cylinder has %d unshared sides, wanted 16", unshared)
+	}
+}
+
+func TestExtrude(t *testing.T) {
+	square := []Point{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}}
+	notch := []Point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 2, Y: 1}, {X: 0, Y: 4}}
+	tests := []struct {
+		name      string
+		polygon   []Point
+		direction Point
+		volume    float64
+	}{
+		{"square", square, Point{Z: 3}, 12},
+		{"square backwards", square, Point{Z: -3}, 12},
+		{"clockwise square", []Point{square[3], square[2], square[1], square[0]}, Point{Z: 3}, 12},
+		{"slanted", square, Point{X: 2, Y: 1, Z: 0.5}, 2},
+		{"concave", notch, Point{Z: 2}, 2 * 10},
+		{"concave backwards", notch, Point{Z: -1}, 10},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := extrude(tc.polygon, nil, tc.direction, true)
+			if v := checkSolid(t, o); !near(v, tc.volume, 1e-9) {
+				t.Errorf("volume is %v, wanted %v", v, tc.volume)
+			}
+		})
+	}
+}
+
+func TestExtrudeHoles(t *testing.T) {
+	outer := []Point{{X: 0, Y: 0}, {X: 6, Y: 0}, {X: 6, Y: 4}, {X: 0, Y: 4}}
+	left := []Point{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 2, Y: 3}, {X: 1, Y: 3}}
+	right := []Point{{X: 4, Y: 1}, {X: 5, Y: 2}, {X: 4, Y: 3}}
+	tests := []struct {
+		name      string
+		holes     [][]Point
+		direction Point
+		volume    float64
+	}{
+		{"one hole", [][]Point{left}, Point{Z: 2}, (24 - 2) * 2},
+		{"two holes", [][]Point{left, right}, Point{Z: 2}, (24 - 2 - 1) * 2},
+		{"two holes backwards", [][]Point{right, left}, Point{Z: -1}, 24 - 2 - 1},
+		{"clockwise hole", [][]Point{{left[3], left[2], left[1], left[0]}}, Point{Z: 1}, 24 - 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := extrude(outer, tc.holes, tc.direction, true)
+			if v := checkSolid(t, o); !near(v, tc.volume, 1e-9) {
+				t.Errorf("volume is %v, wanted %v", v, tc.volume)
+			}
+		})
+	}
+
+	// A ring with a hole all the way round it
+	path := []Point{{}, {X: 10}, {X: 10, Z: 10}, {Z: 10}, {}}
+	o := extrudePath([]Point{{X: -2, Y: -2}, {X: 2, Y: -2}, {X: 2, Y: 2}, {X: -2, Y: 2}}, [][]Point{{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}}}, path, true)
+	if v := checkSolid(t, o); !near(v, (16-4)*40, 1e-9) {
+		t.Errorf("ring volume is %v, wanted %v", v, (16-4)*40)
+	}
+}
+
+func TestExtrudePath(t *testing.T) {
+	square := []Point{{X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.5, Y: 0.5}, {X: -0.5, Y: 0.5}}
+	tests := []struct {
+		name   string
+		path   []Point
+		caps   bool
+		closed bool
+	}{
+		{"straight", []Point{{}, {Z: 2}, {Z: 5}}, true, false},
+		{"right angle", []Point{{}, {X: 4}, {X: 4, Z: 4}}, true, false},
+		{"zigzag", []Point{{}, {X: 4}, {X: 4, Y: 4}, {X: 8, Y: 4, Z: 3}, {X: 8, Y: -2, Z: 3}}, true, false},
+		{"square loop", []Point{{}, {X: 4}, {X: 4, Z: 4}, {Z: 4}, {}}, false, true},
+		{"square loop with caps", []Point{{}, {X: 4}, {X: 4, Z: 4}, {Z: 4}, {}}, true, true},
+		{"tilted loop", []Point{{}, {X: 4, Y: 1}, {X: 4, Y: 3, Z: 4}, {Y: 1, Z: 4}, {}}, false, true},
+		{"up the y axis", []Point{{}, {Y: 3}, {X: 2, Y: 5}}, true, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := extrudePath(square, nil, tc.path, tc.caps)
+			if v := checkSolid(t, o); v <= 0 {
+				t.Errorf("volume is %v, wanted it above zero", v)
+			}
+			rings := len(tc.path)
+			if tc.closed {
+				rings--
+			}
+			if len(o.P) != rings*len(square) {
+				t.Errorf("object has %d points, wanted %d", len(o.P), rings*len(square))
+			}
+
+			// The corners of the polygon should stay the same distance from each line of the path, so the sides keep
+			// their width going round bends
+			for r := 0; r+1 < len(tc.path); r++ {
+				dir := vecNormalise(vecSub(tc.path[r+1], tc.path[r]))
+				ring := o.P[r*len(square) : (r+1)*len(square)]
+				for k, p := range ring {
+					offset := vecSub(p, tc.path[r])
+					offset = vecSub(offset, vecScale(dir, vecDot(offset, dir)))
+					if d := vecLength(offset); !near(d, math.Sqrt(0.5), 1e-9) {
+						t.Errorf("ring %d point %d is %v from the path, wanted %v", r, k, d, math.Sqrt(0.5))
+					}
+				}
+			}
+		})
+	}
+
+	// A straight path is the same as extruding along its direction
+	if v := meshVolume(extrudePath(square, nil, []Point{{}, {Z: 2}, {Z: 5}}, true)); !near(v, 5, 1e-9) {
+		t.Errorf("straight path has a volume of %v, wanted 5", v)
+	}
+}
+
+func TestSceneSweep(t *testing.T) {
+	tests := []struct {
+		name  string
+		sweep string
+		want  string
+	}{
+		{"lathe", `{"type": "lathe", "profile": [[0, -1], [1, -1], [1, 1], [0, 1]]}`, ""},
+		{"open lathe", `{"type": "lathe", "profile": [[1, -1], [1, 1]], "segments": 8, "open": true}`, ""},
+		{"extrude", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]]}`, ""},
+		{"extrude direction", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "direction": [0, 2, 1]}`, ""},
+		{"extrude path", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "path": [[0, 0, 0], [5, 0, 0], [5, 5, 0], [0, 0, 0]]}`, ""},
+		{"extrude with holes", `{"type": "extrude", "profile": [[0, 0], [4, 0], [4, 4], [0, 4]], "holes": [[[1, 1], [3, 1], [3, 3], [1, 3]]]}`, ""},
+		{"lathe with holes", `{"type": "lathe", "profile": [[1, 0], [2, 0], [2, 2]], "holes": [[[1.5, 0.1], [1.9, 0.1], [1.9, 1]]]}`, "only extrusions"},
+		{"short hole", `{"type": "extrude", "profile": [[0, 0], [4, 0], [4, 4], [0, 4]], "holes": [[[1, 1], [3, 1]]]}`, "hole 1 needs at least 3"},
+		{"hole outside", `{"type": "extrude", "profile": [[0, 0], [4, 0], [4, 4], [0, 4]], "holes": [[[1, 1], [5, 1], [3, 3]]]}`, "hole 1 isn't inside"},
+		{"unknown type", `{"type": "twist", "profile": [[0, 0], [1, 0], [0, 1]]}`, "unknown sweep type"},
+		{"short lathe", `{"type": "lathe", "profile": [[1, 0]]}`, "at least 2 points"},
+		{"negative lathe", `{"type": "lathe", "profile": [[-1, 0], [1, 1]]}`, "negative X"},
+		{"negative segments", `{"type": "lathe", "profile": [[1, 0], [1, 1]], "segments": -1}`, "negative"},
+		{"short extrude", `{"type": "extrude", "profile": [[0, 0], [1, 0]]}`, "at least 3 points"},
+		{"short direction", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "direction": [1, 1]}`, "X, Y, and Z"},
+		{"zero direction", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "direction": [0, 0, 0]}`, "can't be zero"},
+		{"short path", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "path": [[0, 0, 0]]}`, "at least 2 points"},
+		{"direction and path", `{"type": "extrude", "profile": [[0, 0], [1, 0], [0, 1]], "direction": [0, 0, 1], "path": [[0, 0, 0], [0, 0, 1]]}`, "one of"},
+		{"flat profile", `{"type": "extrude", "profile": [[0, 0], [0, 0], [0, 1]]}`, "in the same place"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := readScene(strings.NewReader(`{"version": 1, "objects": [{"name": "swept", "sweep": ` + tc.sweep + `}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			obs, err := s.objects()
+			checkError(t, err, tc.want)
+			if err == nil && !strings.Contains(tc.sweep, `"open": true`) {
+				checkSolid(t, obs["swept"])
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Splits a polygon into triangles by ear clipping, using the X and Y of its points.  The polygon can be concave, but
+// its sides mustn't cross.  Returned are the indices into the polygon of each triangle's corners, which go the same
+// way around as the polygon does
+func triangulate(poly []Point) [][3]int {
+	return triangulateHoles(poly)
+}
+
+// Splits a polygon with holes into triangles, using the X and Y of its points.  The holes need to be inside the
+// polygon, and not touch it or each other.  Returned are the indices of each triangle's corners, counting through the
+// polygon's points then each hole's in turn.  The triangles go the same way around as the outside of the polygon
+func triangulateHoles(outer []Point, holes ...[]Point) (tris [][3]int) {
+	if len(outer) < 3 {
+		return nil
+	}
+
+	// Put every point into one list, and work with the outside going anticlockwise and the holes clockwise
+	pts := append([]Point(nil), outer...)
+	ring := make([]int, len(outer))
+	for i := range ring {
+		ring[i] = i
+	}
+	clockwise := polygonArea(outer) < 0
+	if clockwise {
+		reverseInts(ring)
+	}
+	var holeRings [][]int
+	for _, h := range holes {
+		if len(h) < 3 {
+			continue
+		}
+		hr := make([]int, len(h))
+		for i := range hr {
+			hr[i] = len(pts) + i
+		}
+		pts = append(pts, h...)
+		if polygonArea(h) > 0 {
+			reverseInts(hr)
+		}
+		holeRings = append(holeRings, hr)
+	}
+
+	// Join each hole to the outside with a bridge, turning the polygon into a single ring which runs along both sides
+	// of each bridge.  Holes furthest to the right are done first, so later bridges can't cross earlier ones
+	sort.SliceStable(holeRings, func(i, j int) bool {
+		return maxX(pts, holeRings[i]) > maxX(pts, holeRings[j])
+	})
+	for _, hr := range holeRings {
+		ring = bridgeHole(pts, ring, hr)
+	}
+
+	tris = earClip(pts, ring)
+	if clockwise {
+		for i, t := range tris {
+			tris[i] = [3]int{t[2], t[1], t[0]}
+		}
+	}
+	return tris
+}
+
+// Returns the triangles making up a surface of an object, going the same way around as the surface does.  The surface
+// is triangulated as it looks when viewed straight on, so it can be concave, and doesn't need to be quite flat
+func triangulateSurface(pts []Point, s Surface) []Surface {
+	if len(s) == 3 {
+		return []Surface{s}
+	}
+
+	// Flatten the surface by dropping the axis it faces along the most
+	n := surfaceNormal(pts, s)
+	flat := make([]Point, len(s))
+	for i, k := range s {
+		p := pts[k]
+		switch {
+		case math.Abs(n.X) >= math.Abs(n.Y) && math.Abs(n.X) >= math.Abs(n.Z):
+			flat[i] = Point{X: p.Y, Y: p.Z}
+		case math.Abs(n.Y) >= math.Abs(n.Z):
+			flat[i] = Point{X: p.Z, Y: p.X}
+		default:
+			flat[i] = Point{X: p.X, Y: p.Y}
+		}
+	}
+	var tris []Surface
+	for _, t := range triangulate(flat) {
+		tris = append(tris, Surface{s[t[0]], s[t[1]], s[t[2]]})
+	}
+	return tris
+}
+
+// Returns a copy of an object with every surface split into triangles.  The edges are kept as they are, so the
+// triangles' inner sides aren't drawn
+func triangulateObject(ob Object) Object {
+	var surfaces []Surface
+	var colours []string
+	for i, s := range ob.S {
+		tris := triangulateSurface(ob.P, s)
+		surfaces = append(surfaces, tris...)
+		for range tris {
+			// Each triangle keeps the colour of the surface it came from
+			colours = append(colours, ob.surfaceColour(i))
+		}
+	}
+	ob.S = surfaces
+	ob.SC = trimColours(colours)
+	return ob
+}
+
+// Returns copies of the objects with every surface split into triangles, for exporting to programs which need them
+func triangulateWorld(world map[string]Object) map[string]Object {
+	tris := make(map[string]Object, len(world))
+	for name, o := range world {
+		tris[name] = triangulateObject(o)
+	}
+	return tris
+}
+
+// Joins a clockwise hole to an anticlockwise ring of points around it, using a bridge from the hole's rightmost point
+// to a point on the ring it can see.  This is the method from "Triangulation by Ear Clipping" by David Eberly
+func bridgeHole(pts []Point, ring []int, hole []int) []int {
+	// Start the hole at its rightmost point
+	start := 0
+	for i, k := range hole {
+		if pts[k].X > pts[hole[start]].X {
+			start = i
+		}
+	}
+	m := pts[hole[start]]
+
+	// Find the closest side of the ring hit by a line going right from that point
+	best := -1
+	bestX := math.Inf(1)
+	var hit Point
+	for i := range ring {
+		a, b := pts[ring[i]], pts[ring[(i+1)%len(ring)]]
+		if (a.Y > m.Y) == (b.Y > m.Y) && a.Y != m.Y && b.Y != m.Y {
+			continue
+		}
+		if a.Y == b.Y {
+			continue
+		}
+		x := a.X + (m.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+		if x < m.X || x >= bestX {
+			continue
+		}
+		bestX = x
+		hit = Point{X: x, Y: m.Y}
+		// The end of the side furthest right is the likely bridge point
+		best = i
+		if b.X > a.X {
+			best = (i + 1) % len(ring)
+		}
+		if x == a.X && a.Y == m.Y {
+			best = i
+		} else if x == b.X && b.Y == m.Y {
+			best = (i + 1) % len(ring)
+		}
+	}
+	if best < 0 {
+		// The hole isn't inside the ring, so leave it out
+		return ring
+	}
+
+	// If any corners of the ring which turn inwards are inside the triangle between the hole point, where the line
+	// hit, and the likely bridge point, one of them blocks the view.  Use the one closest in angle to the line instead
+	p := pts[ring[best]]
+	if hit.X != p.X || hit.Y != p.Y {
+		bestAngle := math.Inf(1)
+		for i, k := range ring {
+			r := pts[k]
+			prev, next := pts[ring[(i+len(ring)-1)%len(ring)]], pts[ring[(i+1)%len(ring)]]
+			if k == ring[best] || cross2D(prev, r, next) >= 0 || r.X < m.X {
+				continue
+			}
+			if !inTriangle(m, hit, p, r) {
+				continue
+			}
+			angle := math.Atan2(math.Abs(r.Y-m.Y), r.X-m.X)
+			if angle < bestAngle {
+				bestAngle = angle
+				best = i
+			}
+		}
+	}
+
+	// Go around the ring to the bridge point, across the bridge, around the hole, and back again
+	joined := make([]int, 0, len(ring)+len(hole)+2)
+	joined = append(joined, ring[:best+1]...)
+	for i := 0; i <= len(hole); i++ {
+		joined = append(joined, hole[(start+i)%len(hole)])
+	}
+	joined = append(joined, ring[best])
+	return append(joined, ring[best+1:]...)
+}
+
+// Returns twice the signed area of the triangle a, b, c using the X and Y of its points.  It's positive when the
+// corners go anticlockwise
+func cross2D(a Point, b Point, c Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// Cuts the ears off an anticlockwise ring of points until only triangles are left.  An ear is a corner whose triangle
+// has none of the other points inside it
+func earClip(pts []Point, ring []int) (tris [][3]int) {
+	remaining := append([]int(nil), ring...)
+	for len(remaining) > 3 {
+		found := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			if !isEar(pts, remaining, prev, cur, next) {
+				continue
+			}
+			tris = append(tris, [3]int{prev, cur, next})
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			found = true
+			break
+		}
+		if !found {
+			// The polygon is self intersecting or has collinear points left.  Fan out what's left so nothing is lost
+			for i := 1; i < len(remaining)-1; i++ {
+				tris = append(tris, [3]int{remaining[0], remaining[i], remaining[i+1]})
+			}
+			return tris
+		}
+	}
+	if len(remaining) == 3 {
+		tris = append(tris, [3]int{remaining[0], remaining[1], remaining[2]})
+	}
+	return tris
+}
+
+// Returns whether p is inside a polygon, using the X and Y of the points.  Points on the sides may count either way
+func inPolygon(poly []Point, p Point) bool {
+	inside := false
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+((p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Returns whether p is inside or on the sides of the anticlockwise triangle a, b, c
+func inTriangle(a Point, b Point, c Point, p Point) bool {
+	if cross2D(a, b, c) < 0 {
+		b, c = c, b
+	}
+	return cross2D(a, b, p) >= 0 && cross2D(b, c, p) >= 0 && cross2D(c, a, p) >= 0
+}
+
+// Returns whether the corner of an anticlockwise ring of points at cur is an ear: it turns left, and none of the other
+// remaining points are inside its triangle.  Points in the same place as the corner's, eg either end of a hole's
+// bridge, don't count
+func isEar(pts []Point, remaining []int, prev int, cur int, next int) bool {
+	a, b, c := pts[prev], pts[cur], pts[next]
+	if cross2D(a, b, c) <= 1e-12 {
+		// A right turn or a straight line
+		return false
+	}
+	for _, k := range remaining {
+		p := pts[k]
+		if (p.X == a.X && p.Y == a.Y) || (p.X == b.X && p.Y == b.Y) || (p.X == c.X && p.Y == c.Y) {
+			continue
+		}
+		if inTriangle(a, b, c, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the largest X of a ring of points
+func maxX(pts []Point, ring []int) float64 {
+	x := math.Inf(-1)
+	for _, k := range ring {
+		x = math.Max(x, pts[k].X)
+	}
+	return x
+}
+
+// Returns the signed area of a polygon using the X and Y of its points.  It's positive when the polygon goes
+// anticlockwise
+func polygonArea(poly []Point) (area float64) {
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		area += (a.X * b.Y) - (b.X * a.Y)
+	}
+	return area / 2
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTriangulateHoles(t *testing.T) {
+	square := func(x, y, size float64) []Point {
+		return []Point{{X: x, Y: y}, {X: x + size, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}}
+	}
+	clockwise := func(pts []Point) []Point {
+		r := append([]Point(nil), pts...)
+		reversePoints(r)
+		return r
+	}
+	circle := func(x, y, radius float64, n int) (pts []Point) {
+		for i := 0; i < n; i++ {
+			a := 2 * math.Pi * float64(i) / float64(n)
+			pts = append(pts, Point{X: x + radius*math.Cos(a), Y: y + radius*math.Sin(a)})
+		}
+		return pts
+	}
+	comb := []Point{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 3}, {X: 4, Y: 3}, {X: 4, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 3},
+		{X: 2, Y: 3}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 3}, {X: 0, Y: 3}}
+
+	tests := []struct {
+		name  string
+		outer []Point
+		holes [][]Point
+		area  float64
+	}{
+		{"triangle", square(0, 0, 1)[:3], nil, 0.5},
+		{"square", square(0, 0, 2), nil, 4},
+		{"clockwise square", clockwise(square(0, 0, 2)), nil, 4},
+		{"comb", comb, nil, 15 - 4},
+		{"clockwise comb", clockwise(comb), nil, 15 - 4},
+		{"one hole", square(0, 0, 4), [][]Point{square(1, 1, 2)}, 16 - 4},
+		{"hole going the same way", square(0, 0, 4), [][]Point{clockwise(square(1, 1, 2))}, 16 - 4},
+		{"clockwise with a hole", clockwise(square(0, 0, 4)), [][]Point{square(1, 1, 2)}, 16 - 4},
+		{"two holes side by side", square(0, 0, 6), [][]Point{square(1, 1, 1), square(4, 1, 1)}, 36 - 2},
+		{"two holes in a line", square(0, 0, 6), [][]Point{square(1, 1, 1), square(1, 4, 1)}, 36 - 2},
+		{"round hole", circle(0, 0, 5, 24), [][]Point{circle(0.5, 0, 2, 12)}, polygonArea(circle(0, 0, 5, 24)) - polygonArea(circle(0.5, 0, 2, 12))},
+		{"hole in a comb", comb, [][]Point{{{X: 0.5, Y: 0.2}, {X: 4.5, Y: 0.2}, {X: 4.5, Y: 0.8}}}, 11 - 1.2},
+		{"short hole left out", square(0, 0, 4), [][]Point{square(1, 1, 2)[:2]}, 16},
+		{"nothing", square(0, 0, 1)[:2], nil, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pts := append([]Point(nil), tc.outer...)
+			numHoles := 0
+			for _, h := range tc.holes {
+				if len(h) >= 3 {
+					pts = append(pts, h...)
+					numHoles++
+				}
+			}
+			tris := triangulateHoles(tc.outer, tc.holes...)
+
+			// Each hole adds two triangles, for the sides of its bridge
+			if len(tc.outer) >= 3 && len(tris) != len(pts)+(2*numHoles)-2 {
+				t.Errorf("got %d triangles, wanted %d", len(tris), len(pts)+(2*numHoles)-2)
+			}
+			outerWay := polygonArea(tc.outer) > 0
+			area := 0.0
+			for _, tri := range tris {
+				a := polygonArea([]Point{pts[tri[0]], pts[tri[1]], pts[tri[2]]})
+				if (a > 0) != outerWay || a == 0 {
+					t.Errorf("triangle %v has an area of %v, so doesn't go the same way round as the outside", tri, a)
+				}
+				area += math.Abs(a)
+			}
+			if !near(area, tc.area, 1e-9) {
+				t.Errorf("triangles cover %v, wanted %v", area, tc.area)
+			}
+
+			// The middle of each triangle should be inside the outside, and outside every hole
+			for _, tri := range tris {
+				mid := vecScale(vecAdd(vecAdd(pts[tri[0]], pts[tri[1]]), pts[tri[2]]), 1.0/3)
+				if !inPolygon(tc.outer, mid) {
+					t.Errorf("triangle %v is outside the polygon", tri)
+				}
+				for i, h := range tc.holes {
+					if len(h) >= 3 && inPolygon(h, mid) {
+						t.Errorf("triangle %v is inside hole %d", tri, i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTriangulateObject(t *testing.T) {
+	cube := testCube(t)
+	cube.SC = []string{"red", "", "blue"}
+	tris := triangulateObject(cube)
+	if len(tris.S) != 12 {
+		t.Fatalf("got %d surfaces, wanted 12", len(tris.S))
+	}
+	for i, s := range tris.S {
+		if len(s) != 3 {
+			t.Errorf("surface %d has %d points", i, len(s))
+		}
+	}
+	if v := checkSolid(t, tris); !near(v, 8, 1e-9) {
+		t.Errorf("volume is %v, wanted 8", v)
+	}
+
+	// The triangles keep the colours of the surfaces they came from
+	want := []string{"red", "red", "", "", "blue", "blue"}
+	for i, c := range want {
+		if tris.surfaceColour(i) != c {
+			t.Errorf("triangle %d has colour %q, wanted %q", i, tris.surfaceColour(i), c)
+		}
+	}
+
+	// Concave surfaces which aren't facing along an axis
+	pts := []Point{{X: 0, Y: 0, Z: 0}, {X: 2, Y: 1, Z: 1}, {X: 0, Y: 1, Z: 0.5}, {X: -2, Y: 1, Z: 1}}
+	s := triangulateSurface(pts, Surface{0, 1, 2, 3})
+	if len(s) != 2 {
+		t.Fatalf("got %d triangles, wanted 2", len(s))
+	}
+	for _, tri := range s {
+		if vecDot(surfaceNormal(pts, tri), surfaceNormal(pts, Surface{0, 1, 2, 3})) <= 0 {
+			t.Errorf("triangle %v faces the other way to the surface", tri)
+		}
+	}
+}
+
+func TestInPolygon(t *testing.T) {
+	comb := []Point{{X: 0, Y: 0}, {X: 3, Y: 0}, {X: 3, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 2}, {X: 0, Y: 2}}
+	for _, tc := range []struct {
+		p    Point
+		want bool
+	}{
+		{Point{X: 0.5, Y: 0.5}, true},
+		{Point{X: 0.5, Y: 1.5}, true},
+		{Point{X: 1.5, Y: 1.5}, false},
+		{Point{X: 1.5, Y: 0.5}, true},
+		{Point{X: -1, Y: 0.5}, false},
+		{Point{X: 4, Y: 1}, false},
+	} {
+		if got := inPolygon(comb, tc.p); got != tc.want {
+			t.Errorf("inPolygon(%v) is %v, wanted %v", tc.p, got, tc.want)
+		}
+	}
+}
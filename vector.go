@@ -0,0 +1,43 @@
+package main
+
+import "math"
+
+// Vector maths on the X, Y, and Z of points.  The point numbers and colours of the results aren't set
+
+// Returns a + b
+func vecAdd(a Point, b Point) Point {
+	return Point{X: a.X + b.X, Y: a.Y + b.Y, Z: a.Z + b.Z}
+}
+
+// Returns the cross product of a and b, which is at right angles to both
+func vecCross(a Point, b Point) Point {
+	return Point{X: (a.Y * b.Z) - (a.Z * b.Y), Y: (a.Z * b.X) - (a.X * b.Z), Z: (a.X * b.Y) - (a.Y * b.X)}
+}
+
+// Returns the dot product of a and b
+func vecDot(a Point, b Point) float64 {
+	return (a.X * b.X) + (a.Y * b.Y) + (a.Z * b.Z)
+}
+
+// Returns the length of a vector
+func vecLength(v Point) float64 {
+	return math.Sqrt(vecDot(v, v))
+}
+
+// Returns a vector of length 1 going the same way as v.  Vectors with no length are returned as they are
+func vecNormalise(v Point) Point {
+	if l := vecLength(v); l > 0 {
+		return vecScale(v, 1/l)
+	}
+	return v
+}
+
+// Returns v multiplied by f
+func vecScale(v Point, f float64) Point {
+	return Point{X: v.X * f, Y: v.Y * f, Z: v.Z * f}
+}
+
+// Returns a - b
+func vecSub(a Point, b Point) Point {
+	return Point{X: a.X - b.X, Y: a.Y - b.Y, Z: a.Z - b.Z}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The state of the view, as kept in the page URL hash so a link opens the same view.  The world is shown scaled by
+// zoom, then rotated around the X, Y, and Z axes, then moved
+type viewState struct {
+	zoom     float64
+	rotate   [3]float64 // Degrees around the X, Y, and Z axes
+	move     [3]float64
+	selected string     // Name of the selected object
+	spin     [3]float64 // Degrees around the X, Y, and Z axes of a running rotation
+	drift    [3]float64 // X, Y, and Z distance of a running translation
+	speed    float64    // Step size used by the movement keys
+}
+
+// Returns the view state for a transformation matrix made from a uniform scale, rotations, and a translation
+func viewFromMatrix(m matrix) (v viewState) {
+	det := m[0]*(m[5]*m[10]-m[6]*m[9]) - m[1]*(m[4]*m[10]-m[6]*m[8]) + m[2]*(m[4]*m[9]-m[5]*m[8])
+	v.zoom = math.Cbrt(det)
+	v.move = [3]float64{m[3], m[7], m[11]}
+	if v.zoom == 0 {
+		return
+	}
+
+	// The rotation matrix is Z * Y * X, so pull the angles back out of it
+	r := func(row, col int) float64 {
+		return m[row*4+col] / v.zoom
+	}
+	deg := 180 / math.Pi
+	y := math.Asin(math.Max(-1, math.Min(1, -r(2, 0))))
+	if math.Cos(y) > 1e-6 {
+		v.rotate = [3]float64{math.Atan2(r(2, 1), r(2, 2)) * deg, y * deg, math.Atan2(r(1, 0), r(0, 0)) * deg}
+	} else {
+		// Looking straight along the Y axis, so the X and Z rotations are the same thing
+		v.rotate = [3]float64{math.Atan2(-r(1, 2), r(1, 1)) * deg, y * deg, 0}
+	}
+	return
+}
+
+// Reads a view state from a page URL hash, as written by (viewState).hash()
+func parseViewHash(hash string) (v viewState, err error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(hash, "#"))
+	if err != nil {
+		return viewState{}, errors.New("view: " + err.Error())
+	}
+	zoom := []float64{1}
+	speed := []float64{0}
+	for _, f := range []struct {
+		key string
+		dst []float64
+	}{
+		{"z", zoom},
+		{"r", v.rotate[:]},
+		{"m", v.move[:]},
+		{"spin", v.spin[:]},
+		{"drift", v.drift[:]},
+		{"speed", speed},
+	} {
+		s := values.Get(f.key)
+		if s == "" {
+			continue
+		}
+		fields := strings.Split(s, ",")
+		if len(fields) != len(f.dst) {
+			return viewState{}, errors.New("view: '" + f.key + "' needs " + strconv.Itoa(len(f.dst)) + " values")
+		}
+		for i, field := range fields {
+			n, err := strconv.ParseFloat(field, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				return viewState{}, errors.New("view: bad '" + f.key + "' value '" + field + "'")
+			}
+			f.dst[i] = n
+		}
+	}
+	v.zoom = zoom[0]
+	v.speed = speed[0]
+	if v.zoom <= 0 {
+		return viewState{}, errors.New("view: zoom must be above 0")
+	}
+	if v.speed < 0 {
+		return viewState{}, errors.New("view: speed can't be below 0")
+	}
+	v.selected = values.Get("sel")
+	return v, nil
+}
+
+// Returns the view state in the compact form used for the page URL hash, eg "z=2&r=15,15,15&spin=15,15,15".  Values
+// which are the same as a freshly loaded world are left out
+func (v viewState) hash() string {
+	var parts []string
+	add := func(key string, nums ...float64) {
+		for _, n := range nums {
+			if n != 0 {
+				s := make([]string, len(nums))
+				for i, n := range nums {
+					s[i] = strconv.FormatFloat(math.Round(n*1000)/1000, 'f', -1, 64)
+				}
+				parts = append(parts, key+"="+strings.Join(s, ","))
+				return
+			}
+		}
+	}
+	if math.Round(v.zoom*1000) != 1000 {
+		add("z", v.zoom)
+	}
+	add("r", v.rotate[:]...)
+	add("m", v.move[:]...)
+	if v.selected != "" {
+		parts = append(parts, "sel="+url.QueryEscape(v.selected))
+	}
+	add("spin", v.spin[:]...)
+	add("drift", v.drift[:]...)
+	add("speed", v.speed)
+	return strings.Join(parts, "&")
+}
+
+// Returns the transformation matrix which places the world as the view state says
+func (v viewState) matrix() matrix {
+	m := identityMatrix
+	if v.rotate[0] != 0 {
+		m = rotateAroundX(m, v.rotate[0])
+	}
+	if v.rotate[1] != 0 {
+		m = rotateAroundY(m, v.rotate[1])
+	}
+	if v.rotate[2] != 0 {
+		m = rotateAroundZ(m, v.rotate[2])
+	}
+	if v.zoom != 0 && v.zoom != 1 {
+		m = scale(m, v.zoom, v.zoom, v.zoom)
+	}
+	return translate(m, v.move[0], v.move[1], v.move[2])
+}
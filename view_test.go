@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+// Checks hashes read back to the same state, and write out the same again
+func TestParseViewHash(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"z=2",
+		"r=15,-30,45&m=1,2,3",
+		"z=0.5&r=0,90,0&m=0,0,-4&sel=my+cube&spin=0,15,0&drift=0.25,0,0&speed=0.125",
+		"sel=a%26b%3Dc",
+	} {
+		v, err := parseViewHash("#" + hash)
+		if err != nil {
+			t.Errorf("%q: %v", hash, err)
+			continue
+		}
+		if again := v.hash(); again != hash {
+			t.Errorf("%q came back as %q", hash, again)
+		}
+	}
+
+	v, err := parseViewHash("z=2&r=1,2,3&sel=my+cube&speed=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.zoom != 2 || v.rotate != [3]float64{1, 2, 3} || v.selected != "my cube" || v.speed != 0.5 {
+		t.Errorf("view is %+v", v)
+	}
+	if v, _ := parseViewHash(""); v.zoom != 1 {
+		t.Errorf("zoom without a z is %v, wanted 1", v.zoom)
+	}
+
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{"z=%zz", "view: invalid URL escape"},
+		{"r=1,2", "'r' needs 3 values"},
+		{"m=1,2,3,4", "'m' needs 3 values"},
+		{"z=1,2", "'z' needs 1 values"},
+		{"z=big", "bad 'z' value 'big'"},
+		{"r=1,,3", "bad 'r' value ''"},
+		{"spin=0,NaN,0", "bad 'spin' value 'NaN'"},
+		{"drift=Inf,0,0", "bad 'drift' value 'Inf'"},
+		{"m=0,0,1e999", "bad 'm' value '1e999'"},
+		{"z=0", "zoom must be above 0"},
+		{"z=-2", "zoom must be above 0"},
+		{"speed=-1", "speed can't be below 0"},
+	}
+	for _, tc := range tests {
+		v, err := parseViewHash(tc.hash)
+		checkError(t, err, tc.want)
+		if v != (viewState{}) {
+			t.Errorf("%q gave view %+v along with its error", tc.hash, v)
+		}
+	}
+}
+
+// Checks the state pulled out of a view's matrix makes the same matrix again
+func TestViewFromMatrix(t *testing.T) {
+	tests := []struct {
+		name string
+		v    viewState
+		same bool // Whether the state itself should come back the same, rather than just its matrix
+	}{
+		{"identity", viewState{zoom: 1}, true},
+		{"zoomed and moved", viewState{zoom: 2.5, move: [3]float64{1, -2, 3}}, true},
+		{"rotated", viewState{zoom: 1, rotate: [3]float64{15, -30, 45}}, true},
+		{"everything", viewState{zoom: 0.5, rotate: [3]float64{-120, 60, 170}, move: [3]float64{0, 0, -4}}, true},
+		{"looking along y", viewState{zoom: 1, rotate: [3]float64{30, 90, 20}}, false},
+		{"looking back along y", viewState{zoom: 3, rotate: [3]float64{0, -90, 45}}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := tc.v.matrix()
+			v := viewFromMatrix(m)
+			again := v.matrix()
+			for i := range m {
+				if !near(again[i], m[i], 1e-9) {
+					t.Fatalf("matrix came back as %v, wanted %v", again, m)
+				}
+			}
+			if !near(v.zoom, tc.v.zoom, 1e-9) {
+				t.Errorf("zoom is %v, wanted %v", v.zoom, tc.v.zoom)
+			}
+			for i := 0; i < 3 && tc.same; i++ {
+				if !near(v.rotate[i], tc.v.rotate[i], 1e-9) || !near(v.move[i], tc.v.move[i], 1e-9) {
+					t.Errorf("view is %+v, wanted %+v", v, tc.v)
+					break
+				}
+			}
+		})
+	}
+
+	if v := viewFromMatrix(scale(identityMatrix, 0, 0, 0)); v.zoom != 0 || v.rotate != [3]float64{} {
+		t.Errorf("flattened matrix gave view %+v", v)
+	}
+}
@@ -1,32 +1,18 @@
+//go:build js && wasm
+// +build js,wasm
+
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"math"
-	"sort"
+	"path"
 	"strconv"
+	"strings"
 	"syscall/js"
 )
 
-type matrix []float64
-
-type Point struct {
-	Num int
-	X   float64
-	Y   float64
-	Z   float64
-}
-
-type Edge []int
-type Surface []int
-
-type Object struct {
-	C   string // Colour of the object
-	P   []Point
-	E   []Edge    // List of points to connect by edges
-	S   []Surface // List of points to connect in order, to create a surface
-	Mid Point     // The mid point of the object.  Used for calculating object draw order in a very simple way
-}
-
 const (
 	KEY_NONE int = iota
 	KEY_MOVE_LEFT
@@ -43,6 +29,19 @@ const (
 	KEY_END
 	KEY_MINUS
 	KEY_PLUS
+	KEY_EDGE_MODE
+	KEY_HULL
+	KEY_RENDER_MODE
+)
+
+// Model file formats which can be passed to loadModel()
+const (
+	MODEL_NONE int = iota
+	MODEL_OBJ
+	MODEL_STL
+	MODEL_PLY
+	MODEL_GLTF
+	MODEL_GLB
 )
 
 type OperationType int
@@ -54,35 +53,21 @@ const (
 	TRANSLATE
 )
 
-type paintOrder struct {
-	midZ float64 // Z depth of an object's mid point
-	name string
-}
+// The largest width or height of exported PNG images.  Browsers limit the size of canvases
+const maxExportSize = 16384
 
-type paintOrderSlice []paintOrder
+// The starting step size of the movement keys
+const defaultStepSize = 15
 
-func (p paintOrder) String() string {
-	return "Name: " + p.name + ", Mid point: " + strconv.FormatFloat(p.midZ, 'f', 1, 64)
-}
-
-func (p paintOrderSlice) Len() int {
-	return len(p)
-}
-
-func (p paintOrderSlice) Swap(i, j int) {
-	p[i], p[j] = p[j], p[i]
-}
+// The number of frames between checks of whether the page URL hash needs updating
+const hashFrameInterval = 30
 
-func (p paintOrderSlice) Less(i, j int) bool {
-	return p[i].midZ < p[j].midZ
-}
-
-const sourceURL = "https://github.com/justinclift/tinygo_canvas2"
+// Seconds between each call of applyTransformation() by the web page, used to animate function plots
+const plotTimeStep = 0.025
 
 var (
 	// The empty world space
-	worldSpace   map[string]Object
-	pointCounter = 1
+	worldSpace map[string]Object
 
 	// The point objects
 	object1 = Object{
@@ -152,13 +137,18 @@ var (
 		},
 	}
 
-	// The 4x4 identity matrix
-	identityMatrix = matrix{
-		1, 0, 0, 0,
-		0, 1, 0, 0,
-		0, 0, 1, 0,
-		0, 0, 0, 1,
+	// The scene shown when the page doesn't ask for a different one
+	defaultScene = Scene{
+		Version: sceneVersion,
+		Objects: []SceneObject{
+			{Name: "ob1", Object: object1, Transform: SceneTransform{Translate: [3]float64{5.0, 3.0, 0.0}}},
+			{Name: "ob1 copy", Object: object1, Transform: SceneTransform{Translate: [3]float64{-1.0, 3.0, 0.0}}},
+			{Name: "ob2", Object: object2, Transform: SceneTransform{Translate: [3]float64{5.0, -3.0, 1.0}}},
+			{Name: "ob3", Object: object3, Transform: SceneTransform{Translate: [3]float64{-1.0, 0.0, -1.0}}},
+		},
+		Camera: SceneCamera{Zoom: 2.0, Spin: [3]float64{15, 15, 15}},
 	}
+	sceneLights []SceneLight // Lights of the current scene, kept so they're saved again
 
 	// Initialise the transform matrix with the identity matrix
 	transformMatrix = identityMatrix
@@ -169,12 +159,34 @@ var (
 	width, height      float64
 	opText             string
 	highLightSource    bool
-	stepSize           = float64(15)
+	stepSize           = float64(defaultStepSize)
 
 	// Queue operations
-	prevKey    int
-	queueOp    OperationType
-	queueParts int32
+	prevKey     int
+	queueOp     OperationType
+	queueParts  int32
+	queueValues [3]float64 // The X, Y, and Z values of the operation
+
+	// The view of the world, which is kept in the page URL hash
+	viewMatrix = identityMatrix // Every transform applied to the world space since the scene was loaded
+	selected   string           // Name of the selected object
+	startView  *viewState       // View from the page URL hash, used once the first scene has loaded
+	viewHash   string           // The view last put in the page URL hash
+	hashFrames int              // Frames since the page URL hash was last checked
+
+	// Function plots in the world space, keyed by object name.  Those using t are sampled again as time passes
+	plots    map[string]*plot
+	plotTime float64 // Seconds since the plots started
+
+	// The edge mode last chosen with the edge mode key
+	edgeMode = EDGES_ALL
+
+	// How objects with the default render mode are drawn
+	renderMode = RENDER_SOLID_EDGES
+
+	// Counts of what went into drawing the last frame, shown when the page query string has "stats" in it
+	lastStats frameStats
+	showStats bool
 
 	debug = false
 )
@@ -189,21 +201,27 @@ func main() {
 	canvasEl.Set("tabIndex", 0) // Not sure if this is needed
 	ctx = canvasEl.Call("getContext", "2d")
 
-	// Add some objects to the world space
-	worldSpace = make(map[string]Object, 1)
-	worldSpace["ob1"] = importObject(object1, 5.0, 3.0, 0.0)
-	worldSpace["ob1 copy"] = importObject(object1, -1.0, 3.0, 0.0)
-	worldSpace["ob2"] = importObject(object2, 5.0, -3.0, 1.0)
-	worldSpace["ob3"] = importObject(object3, -1.0, 0.0, -1.0)
-
-	// Scale them up a bit
-	queueOp = SCALE
-	queueParts = 1
-	transformMatrix = scale(transformMatrix, 2.0, 2.0, 2.0)
-	applyTransformation()
+	// If the page URL hash holds a view (eg from a shared link), it's used once the scene has loaded
+	if hash := js.Global().Get("location").Get("hash").String(); len(hash) > 1 {
+		v, err := parseViewHash(hash)
+		if err != nil {
+			println("Ignoring the page URL hash: " + err.Error())
+		} else {
+			startView = &v
+		}
+	}
 
-	// Start a rotation going
-	setUpOperation(ROTATE, 50, 12, stepSize, stepSize, stepSize)
+	// If the page query string gives a scene file, the web page fetches it and passes it to loadScene().  Otherwise
+	// the default scene is used
+	worldSpace = make(map[string]Object, 1)
+	plots = make(map[string]*plot)
+	params := js.Global().Get("URLSearchParams").New(js.Global().Get("location").Get("search"))
+	showStats = params.Call("has", "stats").Bool()
+	if params.Call("has", "scene").Bool() {
+		opText = "Loading scene..."
+	} else {
+		useScene(defaultScene)
+	}
 
 	// Start the frame renderer
 	js.Global().Call("requestAnimationFrame", js.Global().Get("renderFrame"))
@@ -212,27 +230,13 @@ func main() {
 // Apply each transformation, one small part at a time (this gives the animation effect)
 //go:export applyTransformation
 func applyTransformation() {
+	animatePlots()
 	if (queueParts < 1 && queueOp == SCALE) || queueOp == NOTHING {
 		opText = "Complete."
 		return
 	}
 
-	for j, o := range worldSpace {
-		var newPoints []Point
-		// Transform each point in the object
-		for _, j := range o.P {
-			newPoints = append(newPoints, transform(transformMatrix, j))
-		}
-		o.P = newPoints
-
-		// Transform the mid point of the object.  In theory, this should mean the mid point can always be used
-		// for a simple (not-cpu-intensive) way to sort the objects in Z depth order
-		o.Mid = transform(transformMatrix, o.Mid)
-
-		// Update the object in world space
-		worldSpace[j] = o
-	}
-
+	transformWorld(transformMatrix)
 	queueParts--
 }
 
@@ -248,6 +252,11 @@ func clickHandler(cx int, cy int) {
 		}
 	}
 
+	// Clicking in the graph area selects the object shown nearest the mouse, or clears the selection if none are close
+	if clientX < graphWidth {
+		selected = objectAt(worldSpace, clientX, clientY, graphWidth, graphHeight, graphStep())
+	}
+
 	// If the user clicks the source code URL area, open the URL
 	if clientX > graphWidth && clientY > (float64(height)-40) {
 		w := js.Global().Call("open", sourceURL)
@@ -258,6 +267,135 @@ func clickHandler(cx int, cy int) {
 	}
 }
 
+// Adds a plot of the functions in the plotFunction global to the world space.  This is either a single function for a
+// z = f(x, y) surface, or three comma separated functions for the x, y, and z of a curve or parametric surface
+//go:export addPlot
+func addPlot() {
+	fn := strings.TrimSpace(js.Global().Get("plotFunction").String())
+	spec, err := plotSpec(fn)
+	var p *plot
+	if err == nil {
+		p, err = newPlot(spec)
+	}
+	if err != nil {
+		println("Plotting failed: " + err.Error())
+		opText = "Plotting failed."
+		return
+	}
+
+	// Scale the plot to a reasonable size, the same as loaded models.  It's placed so it isn't affected by the current
+	// view
+	name := "z = " + fn
+	if spec.Type != "" {
+		name = "(x, y, z) = (" + strings.Join(spec.Functions, ", ") + ")"
+	}
+	name = uniqueName(worldSpace, name)
+	fit, ok := fitMatrix(map[string]Object{name: p.object(plotTime)}, 10)
+	inv, ok2 := invertMatrix(viewMatrix)
+	if !ok || !ok2 {
+		opText = "Nothing to plot."
+		return
+	}
+	p.place = matrixMult(inv, fit)
+	o := plotObject(p, defaultColour)
+	if err = o.Validate(); err != nil {
+		println("Plotting " + name + " failed: " + err.Error())
+		opText = "Plotting failed."
+		return
+	}
+	worldSpace[name] = o
+	plots[name] = p
+	opText = "Plotted " + name + "."
+}
+
+// Exports the current view of the graph area as an SVG document, putting it in the svgData global for the web page to
+// download.  If labels isn't zero, the object names are included too
+//go:export exportSVG
+func exportSVG(labels int) {
+	var buf strings.Builder
+	cmds := drawList(worldSpace, graphWidth, graphHeight, graphStep(), labels != 0, "", renderMode)
+	if err := writeSVG(&buf, cmds, graphWidth, graphHeight); err != nil {
+		println("Exporting SVG failed: " + err.Error())
+		opText = "Exporting SVG failed."
+		return
+	}
+	js.Global().Set("svgData", buf.String())
+	opText = "SVG exported."
+}
+
+// Exports the world space as a Wavefront OBJ file, with a matching MTL file for the object colours.  These are put
+// in the objData and mtlData globals for the web page to download.  If triangles isn't zero, the surfaces are split
+// into triangles
+//go:export exportOBJ
+func exportOBJ(triangles int) {
+	world := worldSpace
+	if triangles != 0 {
+		world = triangulateWorld(worldSpace)
+	}
+	var objBuf, mtlBuf strings.Builder
+	err := writeOBJ(&objBuf, world, "world.mtl")
+	if err == nil {
+		err = writeMTL(&mtlBuf, worldSpace)
+	}
+	if err != nil {
+		println("Exporting OBJ failed: " + err.Error())
+		opText = "Exporting OBJ failed."
+		return
+	}
+	js.Global().Set("objData", objBuf.String())
+	js.Global().Set("mtlData", mtlBuf.String())
+	opText = "OBJ exported."
+}
+
+// Exports the graph area as a PNG image of the given size, independent of the window size.  The image is drawn on a
+// new canvas, which is put in the exportCanvas global for the web page to encode and download
+//go:export exportPNG
+func exportPNG(w int, h int) {
+	js.Global().Set("exportCanvas", js.Null())
+	if w < 1 || h < 1 || w > maxExportSize || h > maxExportSize {
+		opText = "PNG size must be 1 to " + strconv.Itoa(maxExportSize) + "."
+		return
+	}
+	if graphWidth == 0 || graphHeight == 0 {
+		// Nothing has been drawn yet
+		return
+	}
+	c := doc.Call("createElement", "canvas")
+	c.Set("width", w)
+	c.Set("height", h)
+	target := newCanvasRenderer(c.Call("getContext", "2d"))
+
+	// Draw the graph as it looks on screen, scaled to fill the image
+	k := math.Min(float64(w)/graphWidth, float64(h)/graphHeight)
+	target.SetFillStyle("white")
+	target.FillRect(0, 0, float64(w), float64(h))
+	target.Scale(k, k)
+	drawGraph(target, drawList(worldSpace, float64(w)/k, float64(h)/k, graphStep(), false, "", renderMode))
+	js.Global().Set("exportCanvas", c)
+	opText = "PNG exported."
+}
+
+// Changes the view when the page URL hash is changed, eg by pasting a link to the same page
+//go:export hashChangeHandler
+func hashChangeHandler() {
+	hash := strings.TrimPrefix(js.Global().Get("location").Get("hash").String(), "#")
+	if hash == viewHash {
+		return
+	}
+	v, err := parseViewHash(hash)
+	if err != nil {
+		println("Ignoring the page URL hash: " + err.Error())
+		return
+	}
+	viewHash = hash
+	if startView != nil {
+		// The scene hasn't loaded yet
+		startView = &v
+		return
+	}
+	useView(v)
+}
+
 // Simple keyboard handler for catching the arrow, WASD, and numpad keys
 // Key value info can be found here: https://developer.mozilla.org/en-US/docs/Web/API/KeyboardEvent/key/Key_Values
 //go:export keyPressHandler
@@ -272,6 +410,66 @@ func keyPressHandler(keyVal int) {
 		return
 	}
 
+	// The edge mode key finds the edges of the selected object again, or of every object if none are selected.  Each
+	// press moves on to the next way of finding them
+	if keyVal == KEY_EDGE_MODE {
+		edgeMode++
+		if edgeMode > EDGES_FEATURE {
+			edgeMode = EDGES_ALL
+		}
+		for name, o := range worldSpace {
+			if selected == "" || name == selected {
+				o.E = deriveEdges(o, edgeMode, defaultCreaseAngle)
+				worldSpace[name] = o
+			}
+		}
+		opText = "Edges: " + edgeMode.String() + "."
+		return
+	}
+
+	// The render mode key changes how the selected object is drawn, or how every object using the default render mode is
+	// drawn if none are selected.  Each press moves on to the next mode
+	if keyVal == KEY_RENDER_MODE {
+		if selected != "" {
+			o := worldSpace[selected]
+			o.M = (o.M + 1) % RenderMode(len(renderModeNames))
+			worldSpace[selected] = o
+			opText = "Render mode of " + selected + ": " + o.M.String() + "."
+			return
+		}
+		renderMode++
+		if int(renderMode) >= len(renderModeNames) {
+			renderMode = RENDER_SOLID_EDGES
+		}
+		opText = "Render mode: " + renderMode.String() + "."
+		return
+	}
+
+	// The hull key adds the convex hull of the selected object, and selects it
+	if keyVal == KEY_HULL {
+		if selected == "" {
+			opText = "Select an object to find its hull."
+			return
+		}
+		o := worldSpace[selected]
+		h, err := convexHull(o.P)
+		if err != nil {
+			println("Finding the hull of " + selected + " failed: " + err.Error())
+			opText = "Finding the hull failed."
+			return
+		}
+		h.C, h.A = o.C, o.A
+		name := uniqueName(worldSpace, "hull of "+selected)
+		if err = addObjects(map[string]Object{name: h}); err != nil {
+			println("Adding the hull of " + selected + " failed: " + err.Error())
+			opText = "Finding the hull failed."
+			return
+		}
+		selected = name
+		opText = "Added " + name + "."
+		return
+	}
+
 	// The the plus or minus keys were pressed, increase the step size then cause the current operation to be recalculated
 	switch keyVal {
 	case KEY_MINUS:
@@ -312,6 +510,108 @@ func keyPressHandler(keyVal int) {
 	prevKey = keyVal
 }
 
+// Loads the model file passed in from the web page, adding its objects to the world space.  The file contents are
+// read from the modelData global (base64 encoded for binary formats), its name from modelName, and any files it
+// references from modelMaterials and modelBuffers.  If the modelEdges global names an edge mode, the edges of the
+// objects are found that way instead of the format's usual way.  If the modelSimplify global holds a number of
+// faces, models with more are simplified down to it
+//go:export loadModel
+func loadModel(format int) {
+	fileName := js.Global().Get("modelName").String()
+	data := js.Global().Get("modelData").String()
+	name := strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	if debug {
+		println("Loading model: " + fileName + " format: " + strconv.Itoa(format))
+	}
+
+	// Binary formats are passed through base64 encoded
+	var obs map[string]Object
+	var ob Object
+	var raw []byte
+	var err error
+	switch format {
+	case MODEL_OBJ:
+		// Any material libraries referenced by the OBJ file are passed through in modelMaterials
+		var materials map[string]objMaterial
+		materials, err = parseMTL(strings.NewReader(js.Global().Get("modelMaterials").String()))
+		if err == nil {
+			obs, err = parseOBJ(strings.NewReader(data), name, materials)
+		}
+	case MODEL_GLB, MODEL_GLTF:
+		// External buffers referenced by glTF files are passed through base64 encoded in modelBuffers, keyed by URI
+		buffers := make(map[string][]byte)
+		modelBuffers := js.Global().Get("modelBuffers")
+		keys := js.Global().Get("Object").Call("keys", modelBuffers)
+		for i := 0; i < keys.Length() && err == nil; i++ {
+			uri := keys.Index(i).String()
+			buffers[uri], err = base64.StdEncoding.DecodeString(modelBuffers.Get(uri).String())
+		}
+		raw = []byte(data)
+		if err == nil && format == MODEL_GLB {
+			raw, err = base64.StdEncoding.DecodeString(data)
+		}
+		if err == nil {
+			obs, err = parseGLTF(raw, name, buffers)
+		}
+	case MODEL_PLY:
+		if raw, err = base64.StdEncoding.DecodeString(data); err == nil {
+			ob, err = parsePLY(raw)
+		}
+	case MODEL_STL:
+		if raw, err = base64.StdEncoding.DecodeString(data); err == nil {
+			ob, err = parseSTL(raw, stlCreaseAngle)
+		}
+	default:
+		err = errors.New("unknown model format")
+	}
+	if err == nil && obs == nil {
+		// Formats holding a single object
+		obs = map[string]Object{name: ob}
+	}
+	if faces := js.Global().Get("modelSimplify").String(); err == nil && faces != "" {
+		var maxFaces int
+		if maxFaces, err = strconv.Atoi(faces); err == nil && maxFaces > 0 {
+			simplifyObjects(obs, maxFaces)
+		}
+	}
+	if edges := js.Global().Get("modelEdges").String(); err == nil && edges != "" {
+		var mode EdgeMode
+		if mode, err = parseEdgeMode(edges); err == nil {
+			for n, o := range obs {
+				o.E = deriveEdges(o, mode, defaultCreaseAngle)
+				obs[n] = o
+			}
+		}
+	}
+	if err != nil {
+		println("Loading " + fileName + " failed: " + err.Error())
+		opText = "Loading " + name + " failed."
+		return
+	}
+
+	// Scale the new objects to a reasonable size, then add them to the world space
+	fitObjects(obs, 10)
+	if err = addObjects(obs); err != nil {
+		println("Loading " + fileName + " failed: " + err.Error())
+		opText = "Loading " + name + " failed."
+		return
+	}
+	opText = "Loaded " + name + "."
+}
+
+// Loads the scene file passed in from the web page in the sceneData global, replacing the world space
+//go:export loadScene
+func loadScene() {
+	s, err := readScene(strings.NewReader(js.Global().Get("sceneData").String()))
+	if err == nil {
+		err = useScene(s)
+	}
+	if err != nil {
+		println("Loading scene failed: " + err.Error())
+		opText = "Loading scene failed."
+	}
+}
+
 // Simple mouse handler watching for people moving the mouse over the source code link
 //go:export moveHandler
 func moveHandler(cx int, cy int) {
@@ -341,183 +641,77 @@ func renderFrame() {
 		canvasEl.Set("height", height)
 	}
 
-	// Setup useful variables
-	border := float64(2)
-	gap := float64(3)
-	left := border + gap
-	top := border + gap
+	// Draw the graph area and side panel
 	graphWidth = float64(width) * 0.75
 	graphHeight = float64(height) - 1
-	centerX := graphWidth / 2
-	centerY := graphHeight / 2
-
-	// Clear the background
-	ctx.Set("fillStyle", "white")
-	ctx.Call("fillRect", 0, 0, width, height)
-
-	// Save the current graphics state - no clip region currently defined - as the default
-	ctx.Call("save")
-
-	// Set the clip region so drawing only occurs in the display area
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", 0, 0)
-	ctx.Call("lineTo", graphWidth, 0)
-	ctx.Call("lineTo", graphWidth, height)
-	ctx.Call("lineTo", 0, height)
-	ctx.Call("clip")
-
-	// Draw grid lines
-	step := math.Min(float64(width), float64(height)) / float64(30)
-	ctx.Set("strokeStyle", "rgb(220, 220, 220)")
-	for i := left; i < graphWidth-step; i += step {
-		// Vertical dashed lines
-		ctx.Call("beginPath")
-		ctx.Call("moveTo", i+step, top)
-		ctx.Call("lineTo", i+step, graphHeight)
-		ctx.Call("stroke")
-	}
-	for i := top; i < graphHeight-step; i += step {
-		// Horizontal dashed lines
-		ctx.Call("beginPath")
-		ctx.Call("moveTo", left, i+step)
-		ctx.Call("lineTo", graphWidth-border, i+step)
-		ctx.Call("stroke")
-	}
-
-	// Sort the objects by mid point Z depth order
-	var order paintOrderSlice
-	for i, j := range worldSpace {
-		order = append(order, paintOrder{name: i, midZ: j.Mid.Z})
-	}
-	sort.Sort(paintOrderSlice(order))
-
-	// Draw the objects, in Z depth order
-	var pointX, pointY float64
-	numWld := len(worldSpace)
-	for i := 0; i < numWld; i++ {
-		o := worldSpace[order[i].name]
-
-		// Draw the surfaces
-		ctx.Set("fillStyle", o.C)
-		for _, l := range o.S {
-			for m, n := range l {
-				pointX = o.P[n].X
-				pointY = o.P[n].Y
-				if m == 0 {
-					ctx.Call("beginPath")
-					ctx.Call("moveTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
-				} else {
-					ctx.Call("lineTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
-				}
+	f := frameInfo{
+		world:           worldSpace,
+		width:           width,
+		height:          height,
+		graphWidth:      graphWidth,
+		graphHeight:     graphHeight,
+		step:            graphStep(),
+		selected:        selected,
+		mode:            renderMode,
+		opText:          opText,
+		highlightSource: highLightSource,
+	}
+	if showStats {
+		f.stats = &lastStats
+	}
+	cr := newCanvasRenderer(ctx)
+	lastStats = drawFrame(cr, f)
+	lastStats.calls = cr.calls
+
+	// Keep the page URL hash up to date with the view, so it can be shared as a link.  The history entry is replaced
+	// rather than added to, so the back button isn't filled up with every step of an animation
+	hashFrames++
+	if hashFrames >= hashFrameInterval && startView == nil {
+		hashFrames = 0
+		if h := currentView().hash(); h != viewHash {
+			viewHash = h
+			loc := js.Global().Get("location")
+			u := loc.Get("pathname").String() + loc.Get("search").String()
+			if h != "" {
+				u += "#" + h
 			}
-			ctx.Call("closePath")
-			ctx.Call("fill")
-		}
-
-		// Draw the edges
-		ctx.Set("strokeStyle", "black")
-		ctx.Set("fillStyle", "black")
-		ctx.Set("lineWidth", "1")
-		var point1X, point1Y, point2X, point2Y float64
-		for _, l := range o.E {
-			point1X = o.P[l[0]].X
-			point1Y = o.P[l[0]].Y
-			point2X = o.P[l[1]].X
-			point2Y = o.P[l[1]].Y
-			ctx.Call("beginPath")
-			ctx.Call("moveTo", centerX+(point1X*step), centerY+((point1Y*step)*-1))
-			ctx.Call("lineTo", centerX+(point2X*step), centerY+((point2Y*step)*-1))
-			ctx.Call("stroke")
-		}
-
-		// Draw the points on the graph
-		var px, py float64
-		for _, l := range o.P {
-			px = centerX + (l.X * step)
-			py = centerY + ((l.Y * step) * -1)
-			ctx.Call("beginPath")
-			ctx.Call("arc", px, py, 1, 0, 2*math.Pi)
-			ctx.Call("fill")
+			js.Global().Get("history").Call("replaceState", js.Null(), "", u)
 		}
 	}
 
-	// Set the clip region so drawing only occurs in the display area
-	ctx.Call("restore")
-	ctx.Call("save")
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", graphWidth, 0)
-	ctx.Call("lineTo", width, 0)
-	ctx.Call("lineTo", width, height)
-	ctx.Call("lineTo", graphWidth, height)
-	ctx.Call("clip")
-
-	// Draw the text describing the current operation
-	textY := top + 20
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
-	ctx.Call("fillText", "Operation:", graphWidth+20, textY)
-	textY += 20
-	ctx.Set("font", "14px sans-serif")
-	ctx.Call("fillText", opText, graphWidth+20, textY)
-	textY += 30
-
-	// Add the help text about control keys and mouse zoom
-	ctx.Set("fillStyle", "blue")
-	ctx.Set("font", "14px sans-serif")
-	ctx.Call("fillText", "Use wasd to move, numpad keys", graphWidth+20, textY)
-	textY += 20
-	ctx.Call("fillText", "to rotate, mouse wheel to zoom.", graphWidth+20, textY)
-	textY += 30
-	ctx.Call("fillText", "+ and - keys to change speed.", graphWidth+20, textY)
-	textY += 30
-	ctx.Call("fillText", "Press a key a 2nd time to", graphWidth+20, textY)
-	textY += 20
-	ctx.Call("fillText", "stop the current change.", graphWidth+20, textY)
-	textY += 40
-
-	// Clear the source code link area
-	ctx.Set("fillStyle", "white")
-	ctx.Call("fillRect", graphWidth+1, graphHeight-55, width, height)
-
-	// Add the URL to the source code
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
-	ctx.Call("fillText", "Source code:", graphWidth+20, graphHeight-35)
-	ctx.Set("fillStyle", "blue")
-	if highLightSource == true {
-		ctx.Set("font", "bold 12px sans-serif")
-	} else {
-		ctx.Set("font", "12px sans-serif")
-	}
-	ctx.Call("fillText", sourceURL, graphWidth+20, graphHeight-15)
-
-	// Draw a border around the graph area
-	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "white")
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", 0, 0)
-	ctx.Call("lineTo", width, 0)
-	ctx.Call("lineTo", width, height)
-	ctx.Call("lineTo", 0, height)
-	ctx.Call("closePath")
-	ctx.Call("stroke")
-	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "black")
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", border, border)
-	ctx.Call("lineTo", graphWidth, border)
-	ctx.Call("lineTo", graphWidth, graphHeight)
-	ctx.Call("lineTo", border, graphHeight)
-	ctx.Call("closePath")
-	ctx.Call("stroke")
-
-	// Restore the default graphics state (eg no clip region)
-	ctx.Call("restore")
-
 	// Keep the frame rendering going
 	js.Global().Call("requestAnimationFrame", js.Global().Get("renderFrame"))
 }
 
+// Saves the world space as a scene file, putting it in the sceneData global for the web page to download
+//go:export saveScene
+func saveScene() {
+	// The objects are saved as they currently look, so only the current rotation is needed for the camera
+	var cam SceneCamera
+	if queueOp == ROTATE {
+		cam.Spin = queueValues
+	}
+	s := sceneFromWorld(worldSpace, cam, sceneLights)
+	s.RenderMode = renderMode
+
+	// Function plots are saved as their functions, so they can still be animated
+	for i, so := range s.Objects {
+		if p, ok := plots[so.Name]; ok {
+			spec := p.spec
+			s.Objects[i] = SceneObject{Name: so.Name, Object: Object{C: so.C, A: so.A, M: so.M}, Plot: &spec,
+				Transform: sceneTransform(matrixMult(viewMatrix, p.place))}
+		}
+	}
+	var buf strings.Builder
+	if err := writeScene(&buf, s); err != nil {
+		println("Saving scene failed: " + err.Error())
+		opText = "Saving scene failed."
+		return
+	}
+	js.Global().Set("sceneData", buf.String())
+	opText = "Scene saved."
+}
+
 // Simple mouse handler watching for mouse wheel events
 // Reference info can be found here: https://developer.mozilla.org/en-US/docs/Web/Events/wheel
 //go:export wheelHandler
@@ -531,140 +725,84 @@ func wheelHandler(val int32) {
 	prevKey = KEY_NONE
 }
 
-// Returns an object whose points have been transformed into 3D world space XYZ co-ordinates.  Also assigns a number
-// to each point
-func importObject(ob Object, x float64, y float64, z float64) (translatedObject Object) {
-	// X and Y translation matrix.  Translates the objects into the world space at the given X and Y co-ordinates
-	translateMatrix := matrix{
-		1, 0, 0, x,
-		0, 1, 0, y,
-		0, 0, 1, z,
-		0, 0, 0, 1,
-	}
-
-	// Translate the points
-	var midX, midY, midZ float64
-	var pt Point
-	for _, j := range ob.P {
-		pt = Point{
-			Num: pointCounter,
-			X:   (translateMatrix[0] * j.X) + (translateMatrix[1] * j.Y) + (translateMatrix[2] * j.Z) + (translateMatrix[3] * 1),   // 1st col, top
-			Y:   (translateMatrix[4] * j.X) + (translateMatrix[5] * j.Y) + (translateMatrix[6] * j.Z) + (translateMatrix[7] * 1),   // 1st col, upper middle
-			Z:   (translateMatrix[8] * j.X) + (translateMatrix[9] * j.Y) + (translateMatrix[10] * j.Z) + (translateMatrix[11] * 1), // 1st col, lower middle
+// Adds objects to the world space, renaming any whose name is already taken.  If any of the objects aren't valid,
+// none of them are added
+func addObjects(obs map[string]Object) error {
+	for _, name := range sortedNames(obs) {
+		if err := obs[name].Validate(); err != nil {
+			return errors.New("object '" + name + "': " + err.Error())
 		}
-		translatedObject.P = append(translatedObject.P, pt)
-		midX += pt.X
-		midY += pt.Y
-		midZ += pt.Z
-		pointCounter++
 	}
-
-	// Determine the mid point for the object
-	numPts := float64(len(ob.P))
-	translatedObject.Mid.X = midX / numPts
-	translatedObject.Mid.Y = midY / numPts
-	translatedObject.Mid.Z = midZ / numPts
-
-	// Copy the colour, edge, and surface definitions across
-	translatedObject.C = ob.C
-	for _, j := range ob.E {
-		translatedObject.E = append(translatedObject.E, j)
+	for name, o := range obs {
+		worldSpace[uniqueName(worldSpace, name)] = importObject(o, 0, 0, 0)
 	}
-	for _, j := range ob.S {
-		translatedObject.S = append(translatedObject.S, j)
-	}
-
-	return translatedObject
+	return nil
 }
 
-// Multiplies one matrix by another
-func matrixMult(opMatrix matrix, m matrix) (resultMatrix matrix) {
-	top0 := m[0]
-	top1 := m[1]
-	top2 := m[2]
-	top3 := m[3]
-	upperMid0 := m[4]
-	upperMid1 := m[5]
-	upperMid2 := m[6]
-	upperMid3 := m[7]
-	lowerMid0 := m[8]
-	lowerMid1 := m[9]
-	lowerMid2 := m[10]
-	lowerMid3 := m[11]
-	bot0 := m[12]
-	bot1 := m[13]
-	bot2 := m[14]
-	bot3 := m[15]
-
-	resultMatrix = matrix{
-		(opMatrix[0] * top0) + (opMatrix[1] * upperMid0) + (opMatrix[2] * lowerMid0) + (opMatrix[3] * bot0), // 1st col, top
-		(opMatrix[0] * top1) + (opMatrix[1] * upperMid1) + (opMatrix[2] * lowerMid1) + (opMatrix[3] * bot1), // 2nd col, top
-		(opMatrix[0] * top2) + (opMatrix[1] * upperMid2) + (opMatrix[2] * lowerMid2) + (opMatrix[3] * bot2), // 3rd col, top
-		(opMatrix[0] * top3) + (opMatrix[1] * upperMid3) + (opMatrix[2] * lowerMid3) + (opMatrix[3] * bot3), // 4th col, top
-
-		(opMatrix[4] * top0) + (opMatrix[5] * upperMid0) + (opMatrix[6] * lowerMid0) + (opMatrix[7] * bot0), // 1st col, upper middle
-		(opMatrix[4] * top1) + (opMatrix[5] * upperMid1) + (opMatrix[6] * lowerMid1) + (opMatrix[7] * bot1), // 2nd col, upper middle
-		(opMatrix[4] * top2) + (opMatrix[5] * upperMid2) + (opMatrix[6] * lowerMid2) + (opMatrix[7] * bot2), // 3rd col, upper middle
-		(opMatrix[4] * top3) + (opMatrix[5] * upperMid3) + (opMatrix[6] * lowerMid3) + (opMatrix[7] * bot3), // 4th col, upper middle
-
-		(opMatrix[8] * top0) + (opMatrix[9] * upperMid0) + (opMatrix[10] * lowerMid0) + (opMatrix[11] * bot0), // 1st col, lower middle
-		(opMatrix[8] * top1) + (opMatrix[9] * upperMid1) + (opMatrix[10] * lowerMid1) + (opMatrix[11] * bot1), // 2nd col, lower middle
-		(opMatrix[8] * top2) + (opMatrix[9] * upperMid2) + (opMatrix[10] * lowerMid2) + (opMatrix[11] * bot2), // 3rd col, lower middle
-		(opMatrix[8] * top3) + (opMatrix[9] * upperMid3) + (opMatrix[10] * lowerMid3) + (opMatrix[11] * bot3), // 4th col, lower middle
-
-		(opMatrix[12] * top0) + (opMatrix[13] * upperMid0) + (opMatrix[14] * lowerMid0) + (opMatrix[15] * bot0), // 1st col, bottom
-		(opMatrix[12] * top1) + (opMatrix[13] * upperMid1) + (opMatrix[14] * lowerMid1) + (opMatrix[15] * bot1), // 2nd col, bottom
-		(opMatrix[12] * top2) + (opMatrix[13] * upperMid2) + (opMatrix[14] * lowerMid2) + (opMatrix[15] * bot2), // 3rd col, bottom
-		(opMatrix[12] * top3) + (opMatrix[13] * upperMid3) + (opMatrix[14] * lowerMid3) + (opMatrix[15] * bot3), // 4th col, bottom
-	}
-	return resultMatrix
+// Samples any function plots using t again, for the current time
+func animatePlots() {
+	plotTime += plotTimeStep
+	for name, p := range plots {
+		o, ok := worldSpace[name]
+		if ok && p.animated {
+			po := plotObject(p, o.C)
+			po.A, po.M = o.A, o.M
+			worldSpace[name] = po
+		}
+	}
 }
 
-// Rotates a transformation matrix around the X axis by the given degrees
-func rotateAroundX(m matrix, degrees float64) matrix {
-	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
-	rotateXMatrix := matrix{
-		1, 0, 0, 0,
-		0, math.Cos(rad), -math.Sin(rad), 0,
-		0, math.Sin(rad), math.Cos(rad), 0,
-		0, 0, 0, 1,
+// Returns the current view of the world, including any running animation
+func currentView() viewState {
+	v := viewFromMatrix(viewMatrix)
+	v.selected = selected
+	if stepSize != defaultStepSize {
+		v.speed = stepSize
 	}
-	return matrixMult(rotateXMatrix, m)
+	switch queueOp {
+	case ROTATE:
+		v.spin = queueValues
+	case TRANSLATE:
+		v.drift = queueValues
+	}
+	return v
 }
 
-// Rotates a transformation matrix around the Y axis by the given degrees
-func rotateAroundY(m matrix, degrees float64) matrix {
-	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
-	rotateYMatrix := matrix{
-		math.Cos(rad), 0, math.Sin(rad), 0,
-		0, 1, 0, 0,
-		-math.Sin(rad), 0, math.Cos(rad), 0,
-		0, 0, 0, 1,
-	}
-	return matrixMult(rotateYMatrix, m)
+// Returns the number of pixels per world space unit, which is also the spacing of the grid lines
+func graphStep() float64 {
+	return math.Min(float64(width), float64(height)) / float64(30)
 }
 
-// Rotates a transformation matrix around the Z axis by the given degrees
-func rotateAroundZ(m matrix, degrees float64) matrix {
-	rad := (math.Pi / 180) * degrees // The Go math functions use radians, so we convert degrees to radians
-	rotateZMatrix := matrix{
-		math.Cos(rad), -math.Sin(rad), 0, 0,
-		math.Sin(rad), math.Cos(rad), 0, 0,
-		0, 0, 1, 0,
-		0, 0, 0, 1,
+// Transforms every object in the world space, keeping track of the overall view
+func transformWorld(m matrix) {
+	for j, o := range worldSpace {
+		var newPoints []Point
+		// Transform each point in the object
+		for _, j := range o.P {
+			newPoints = append(newPoints, transform(m, j))
+		}
+		o.P = newPoints
+
+		// Transform the mid point of the object.  In theory, this should mean the mid point can always be used
+		// for a simple (not-cpu-intensive) way to sort the objects in Z depth order
+		o.Mid = transform(m, o.Mid)
+
+		// Update the object in world space
+		worldSpace[j] = o
 	}
-	return matrixMult(rotateZMatrix, m)
+	viewMatrix = matrixMult(m, viewMatrix)
 }
 
-// Scales a transformation matrix by the given X, Y, and Z values
-func scale(m matrix, x float64, y float64, z float64) matrix {
-	scaleMatrix := matrix{
-		x, 0, 0, 0,
-		0, y, 0, 0,
-		0, 0, z, 0,
-		0, 0, 0, 1,
+// Returns a function plot at the current time, placed in the world space with the current view
+func plotObject(p *plot, colour string) Object {
+	o := p.object(plotTime)
+	m := matrixMult(viewMatrix, p.place)
+	for i, pt := range o.P {
+		o.P[i] = transform(m, pt)
 	}
-	return matrixMult(scaleMatrix, m)
+	o.Mid = transform(m, o.Mid)
+	o.C = colour
+	return o
 }
 
 // Set up the details for the transformation operation
@@ -706,41 +844,75 @@ func setUpOperation(op OperationType, t int32, f int32, X float64, Y float64, Z
 		opText = "Translate. X: " + strconv.FormatFloat(X, 'f', 0, 64) + " Y: " + strconv.FormatFloat(Y, 'f', 0, 64) + " Z: " + strconv.FormatFloat(Z, 'f', 0, 64)
 	}
 	queueOp = op
+	queueValues = [3]float64{X, Y, Z}
 }
 
-// Transform the XYZ co-ordinates using the values from the transformation matrix
-func transform(m matrix, p Point) (t Point) {
-	top0 := m[0]
-	top1 := m[1]
-	top2 := m[2]
-	top3 := m[3]
-	upperMid0 := m[4]
-	upperMid1 := m[5]
-	upperMid2 := m[6]
-	upperMid3 := m[7]
-	lowerMid0 := m[8]
-	lowerMid1 := m[9]
-	lowerMid2 := m[10]
-	lowerMid3 := m[11]
-	//bot0 := m[12] // The fourth row values can be ignored for 3D matrices
-	//bot1 := m[13]
-	//bot2 := m[14]
-	//bot3 := m[15]
-
-	t.Num = p.Num
-	t.X = (top0 * p.X) + (top1 * p.Y) + (top2 * p.Z) + top3
-	t.Y = (upperMid0 * p.X) + (upperMid1 * p.Y) + (upperMid2 * p.Z) + upperMid3
-	t.Z = (lowerMid0 * p.X) + (lowerMid1 * p.Y) + (lowerMid2 * p.Z) + lowerMid3
-	return
+// Replaces the world space with the objects of a scene, then zooms and starts the world rotating as the scene's
+// camera says
+func useScene(s Scene) error {
+	obs, err := s.objects()
+	if err != nil {
+		return err
+	}
+	worldSpace = make(map[string]Object, len(obs))
+	if err = addObjects(obs); err != nil {
+		return err
+	}
+	sceneLights = s.Lights
+	renderMode = s.RenderMode.use(RENDER_SOLID_EDGES)
+	viewMatrix = identityMatrix
+	selected = ""
+	plots = s.plots()
+	plotTime = 0
+
+	// Scale the world
+	if zoom := s.Camera.Zoom; zoom != 0 && zoom != 1 {
+		queueOp = SCALE
+		queueParts = 1
+		transformMatrix = scale(identityMatrix, zoom, zoom, zoom)
+		applyTransformation()
+	}
+
+	// Start a rotation going, unless the page URL hash gave a view to use instead
+	if startView != nil {
+		useView(*startView)
+		startView = nil
+		return nil
+	}
+	spin := s.Camera.Spin
+	if spin == [3]float64{} {
+		queueOp = NOTHING
+		opText = "Complete."
+		return nil
+	}
+	setUpOperation(ROTATE, 50, 12, spin[0], spin[1], spin[2])
+	return nil
 }
 
-// Translates (moves) a transformation matrix by the given X, Y and Z values
-func translate(m matrix, translateX float64, translateY float64, translateZ float64) matrix {
-	translateMatrix := matrix{
-		1, 0, 0, translateX,
-		0, 1, 0, translateY,
-		0, 0, 1, translateZ,
-		0, 0, 0, 1,
+// Moves the world space to the given view, then selects its object and starts its animation
+func useView(v viewState) {
+	inv, ok := invertMatrix(viewMatrix)
+	if !ok {
+		return
 	}
-	return matrixMult(translateMatrix, m)
+	transformWorld(matrixMult(v.matrix(), inv))
+	viewMatrix = v.matrix()
+	selected = ""
+	if _, ok := worldSpace[v.selected]; ok {
+		selected = v.selected
+	}
+	stepSize = defaultStepSize
+	if v.speed != 0 {
+		stepSize = v.speed
+	}
+	switch {
+	case v.spin != [3]float64{}:
+		setUpOperation(ROTATE, 50, 12, v.spin[0], v.spin[1], v.spin[2])
+	case v.drift != [3]float64{}:
+		setUpOperation(TRANSLATE, 50, 12, v.drift[0], v.drift[1], v.drift[2])
+	default:
+		queueOp = NOTHING
+		opText = "Complete."
+	}
+	prevKey = KEY_NONE
 }